@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/datasource"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/repository"
+	"github.com/leonardchinonso/auth_service_cmp7174/service"
+)
+
+// generateAuditKey prints a new ed25519 seed for the AUDIT_SIGNING_KEY config and its public key
+func generateAuditKey(args []string) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s=%s\n", config.AuditSigningKey, base64.StdEncoding.EncodeToString(privateKey.Seed()))
+	fmt.Printf("public key: %s\n", base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+// verifyAudit walks the audit chain and checks its checkpoints, failing if there are any breaks
+func verifyAudit(args []string) error {
+	auditService, closeFn, err := initAuditService()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	result, err := auditService.Verify(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to verify the audit chain: %v", err)
+	}
+
+	log.Printf("Checked %d audit events and %d checkpoints\n", result.EventsChecked, result.CheckpointsChecked)
+	if result.PrunedUpTo > 0 {
+		log.Printf("Events up to sequence %d were pruned\n", result.PrunedUpTo)
+	}
+	for _, b := range result.Breaks {
+		log.Printf("Break at sequence %d: %s\n", b.Sequence, b.Reason)
+	}
+
+	if !result.Valid() {
+		return fmt.Errorf("the audit chain has %d breaks", len(result.Breaks))
+	}
+
+	log.Println("The audit chain is intact")
+	return nil
+}
+
+// checkpointAudit signs a checkpoint of the current end of the audit chain
+func checkpointAudit(args []string) error {
+	auditService, closeFn, err := initAuditService()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	cp, err := auditService.Checkpoint(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to create audit checkpoint: %v", err)
+	}
+
+	log.Printf("Signed audit checkpoint at sequence %d with hash %s\n", cp.Sequence, cp.Hash)
+	return nil
+}
+
+// exportAuditCheckpoints writes every signed audit checkpoint as a line of JSON
+func exportAuditCheckpoints(args []string) error {
+	fs := flag.NewFlagSet("export-audit-checkpoints", flag.ExitOnError)
+	out := fs.String("out", "", "path to write the checkpoints to, defaults to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	auditService, closeFn, err := initAuditService()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	checkpoints, err := auditService.Checkpoints(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to fetch audit checkpoints: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create checkpoint file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	for i := range checkpoints {
+		if err = encoder.Encode(&checkpoints[i]); err != nil {
+			return fmt.Errorf("failed to write audit checkpoint: %v", err)
+		}
+	}
+
+	log.Printf("Exported %d audit checkpoints\n", len(checkpoints))
+	return nil
+}
+
+// initAuditService connects to the database and returns the audit service with a function that disconnects it
+func initAuditService() (interfaces.AuditServiceInterface, func(), error) {
+	dataSource, err := datasource.InitDataSource()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize data sources: %v", err)
+	}
+
+	auditService, err := service.NewAuditService(
+		dataSource.Cfg,
+		repository.NewAuditRepository(dataSource.Database),
+		repository.NewAuditCheckpointRepository(dataSource.Database),
+	)
+	if err != nil {
+		dataSource.Close()
+		return nil, nil, fmt.Errorf("failed to initialize audit service: %v", err)
+	}
+
+	return auditService, dataSource.Close, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/datasource"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// command is a subcommand that can be run instead of the server
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+// commands are the subcommands available from the command line
+var commands = map[string]command{
+	"build-breach-filter": {
+		description: "build a breached password bloom filter from a HIBP SHA-1 dump",
+		run:         buildBreachFilter,
+	},
+	"generate-audit-key": {
+		description: "generate an ed25519 key for signing audit checkpoints",
+		run:         generateAuditKey,
+	},
+	"verify-audit": {
+		description: "walk the audit chain and report any breaks",
+		run:         verifyAudit,
+	},
+	"checkpoint-audit": {
+		description: "sign a checkpoint of the current end of the audit chain",
+		run:         checkpointAudit,
+	},
+	"migrate": {
+		description: "apply, roll back or list the database migrations: migrate up [-to version] | down [-steps n] | status",
+		run:         runMigrations,
+	},
+	"export-audit-checkpoints": {
+		description: "export the signed audit checkpoints as JSON lines for external anchoring",
+		run:         exportAuditCheckpoints,
+	},
+}
+
+// runCommand runs the subcommand named by the first argument
+func runCommand(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage := "available commands:\n"
+		for name, c := range commands {
+			usage += fmt.Sprintf("  %s\t%s\n", name, c.description)
+		}
+		return fmt.Errorf("unknown command: %s\n%s", args[0], usage)
+	}
+	return cmd.run(args[1:])
+}
+
+// buildBreachFilter reads a HIBP SHA-1 dump and writes a bloom filter of its hashes
+// the dump is either a single file of `HASH:COUNT` lines or a directory of
+// prefix-partitioned files holding `SUFFIX:COUNT` lines
+func buildBreachFilter(args []string) error {
+	fs := flag.NewFlagSet("build-breach-filter", flag.ExitOnError)
+	in := fs.String("in", "", "path to the HIBP SHA-1 dump file or prefix directory")
+	out := fs.String("out", "breached-passwords.bloom", "path to write the bloom filter to")
+	falsePositiveRate := fs.Float64("fp", 0.001, "false positive rate of the filter")
+	minCount := fs.Int("min-count", 1, "skip hashes seen in fewer breaches than this")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	// count the hashes first so the filter can be sized for them
+	var n uint64
+	if err := walkHIBPDump(*in, *minCount, func([]byte) { n++ }); err != nil {
+		return err
+	}
+	log.Printf("Building bloom filter for %d hashes...\n", n)
+
+	filter := utils.NewBloomFilter(n, *falsePositiveRate)
+	if err := walkHIBPDump(*in, *minCount, filter.Add); err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create bloom filter file: %v", err)
+	}
+	defer f.Close()
+
+	size, err := filter.WriteTo(f)
+	if err != nil {
+		return fmt.Errorf("failed to write bloom filter: %v", err)
+	}
+
+	log.Printf("Wrote %d bytes to %s\n", size, *out)
+	return nil
+}
+
+// walkHIBPDump calls fn with the raw SHA-1 hash of every entry in the dump
+func walkHIBPDump(path string, minCount int, fn func(hash []byte)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return readHIBPFile(path, "", minCount, fn)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		prefix := strings.TrimSuffix(e.Name(), ".txt")
+		if e.IsDir() || len(prefix) != datasource.HIBPPrefixLength {
+			continue
+		}
+		if err = readHIBPFile(filepath.Join(path, e.Name()), prefix, minCount, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readHIBPFile reads the `HASH:COUNT` lines of a HIBP file, prepending the prefix to each hash
+func readHIBPFile(path, prefix string, minCount int, fn func(hash []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hexHash, countStr, _ := strings.Cut(line, ":")
+		if count, err := strconv.Atoi(countStr); err == nil && count < minCount {
+			continue
+		}
+
+		hash, err := hex.DecodeString(prefix + hexHash)
+		if err != nil || len(hash) != 20 {
+			return fmt.Errorf("invalid SHA-1 hash in %s: %q", path, line)
+		}
+		fn(hash)
+	}
+
+	return scanner.Err()
+}
@@ -29,8 +29,348 @@ const (
 	Version = "VERSION"
 	// Environment is the global config name for the ENVIRONMENT variable
 	Environment = "ENVIRONMENT"
+
+	// MailFrom is the global config name for the MAIL_FROM variable
+	MailFrom = "MAIL_FROM"
+	// MailUsername is the global config name for the MAIL_USERNAME variable
+	MailUsername = "MAIL_USERNAME"
+	// MailPassword is the global config name for the MAIL_PASSWORD variable
+	MailPassword = "MAIL_PASSWORD"
+	// MailTransport is the global config name for the MAIL_TRANSPORT variable
+	// it is one of `smtp`, `outbox` to write mail to files or `log` to log it
+	MailTransport = "MAIL_TRANSPORT"
+	// MailSMTPHost is the global config name for the MAIL_SMTP_HOST variable
+	MailSMTPHost = "MAIL_SMTP_HOST"
+	// MailSMTPPort is the global config name for the MAIL_SMTP_PORT variable
+	MailSMTPPort = "MAIL_SMTP_PORT"
+	// MailSMTPTLS is the global config name for the MAIL_SMTP_TLS variable
+	// it is `starttls` to upgrade a plain connection or `tls` to connect over TLS from the start
+	MailSMTPTLS = "MAIL_SMTP_TLS"
+	// MailSMTPInsecureSkipVerify is the global config name for the MAIL_SMTP_INSECURE_SKIP_VERIFY variable
+	MailSMTPInsecureSkipVerify = "MAIL_SMTP_INSECURE_SKIP_VERIFY"
+	// MailOutboxDir is the global config name for the MAIL_OUTBOX_DIR variable
+	MailOutboxDir = "MAIL_OUTBOX_DIR"
+	// MailDefaultLocale is the global config name for the MAIL_DEFAULT_LOCALE variable
+	MailDefaultLocale = "MAIL_DEFAULT_LOCALE"
+
+	// SMSTransport is the global config name for the SMS_TRANSPORT variable
+	// it is `log` to log text messages
+	SMSTransport = "SMS_TRANSPORT"
+	// SMSFrom is the global config name for the SMS_FROM variable
+	SMSFrom = "SMS_FROM"
+
+	// PhoneCodeExpiresIn is the global config name for the PHONE_CODE_EXPIRES_IN variable, in seconds
+	PhoneCodeExpiresIn = "PHONE_CODE_EXPIRES_IN"
+	// PhoneCodeMaxAttempts is the global config name for the PHONE_CODE_MAX_ATTEMPTS variable
+	// it is how many wrong guesses a code takes before it stops working
+	PhoneCodeMaxAttempts = "PHONE_CODE_MAX_ATTEMPTS"
+	// PhoneCodeResendInterval is the global config name for the PHONE_CODE_RESEND_INTERVAL variable
+	// it is how many seconds a client waits before another code is sent to their phone
+	PhoneCodeResendInterval = "PHONE_CODE_RESEND_INTERVAL"
+	// PhoneCodeSendLimit is the global config name for the PHONE_CODE_SEND_LIMIT variable
+	// it is how many codes a client is sent in a send window, which caps how many codes they can guess at
+	PhoneCodeSendLimit = "PHONE_CODE_SEND_LIMIT"
+	// PhoneCodeSendWindow is the global config name for the PHONE_CODE_SEND_WINDOW variable, in seconds
+	PhoneCodeSendWindow = "PHONE_CODE_SEND_WINDOW"
+
+	// MagicLinkEnabled is the global config name for the MAGIC_LINK_ENABLED variable
+	MagicLinkEnabled = "MAGIC_LINK_ENABLED"
+	// MagicLinkSecretKey is the global config name for the MAGIC_LINK_SECRET_KEY variable
+	MagicLinkSecretKey = "MAGIC_LINK_SECRET_KEY"
+	// MagicLinkExpiresIn is the global config name for the MAGIC_LINK_EXPIRES_IN variable
+	MagicLinkExpiresIn = "MAGIC_LINK_EXPIRES_IN"
+	// MagicLinkURL is the global config name for the MAGIC_LINK_URL variable
+	MagicLinkURL = "MAGIC_LINK_URL"
+	// MagicLinkRateLimit is the global config name for the MAGIC_LINK_RATE_LIMIT variable
+	MagicLinkRateLimit = "MAGIC_LINK_RATE_LIMIT"
+	// MagicLinkRateWindow is the global config name for the MAGIC_LINK_RATE_WINDOW variable
+	MagicLinkRateWindow = "MAGIC_LINK_RATE_WINDOW"
+
+	// LoginAlertsEnabled is the global config name for the LOGIN_ALERTS_ENABLED variable
+	LoginAlertsEnabled = "LOGIN_ALERTS_ENABLED"
+	// LoginAlertSecretKey is the global config name for the LOGIN_ALERT_SECRET_KEY variable
+	LoginAlertSecretKey = "LOGIN_ALERT_SECRET_KEY"
+	// LoginAlertRevokeURL is the global config name for the LOGIN_ALERT_REVOKE_URL variable
+	LoginAlertRevokeURL = "LOGIN_ALERT_REVOKE_URL"
+	// LoginAlertLinkExpiresIn is the global config name for the LOGIN_ALERT_LINK_EXPIRES_IN variable
+	LoginAlertLinkExpiresIn = "LOGIN_ALERT_LINK_EXPIRES_IN"
+
+	// EmailChangeConfirmURL is the global config name for the EMAIL_CHANGE_CONFIRM_URL variable
+	EmailChangeConfirmURL = "EMAIL_CHANGE_CONFIRM_URL"
+	// EmailChangeCancelURL is the global config name for the EMAIL_CHANGE_CANCEL_URL variable
+	EmailChangeCancelURL = "EMAIL_CHANGE_CANCEL_URL"
+	// EmailChangeExpiresIn is the global config name for the EMAIL_CHANGE_EXPIRES_IN variable
+	// it is how many seconds the new address has to confirm a change and the old address has to cancel it
+	EmailChangeExpiresIn = "EMAIL_CHANGE_EXPIRES_IN"
+
+	// InvitationSecretKey is the global config name for the INVITATION_SECRET_KEY variable
+	// organizations can only invite members when it is set, since the invitation links are signed with it
+	InvitationSecretKey = "INVITATION_SECRET_KEY"
+	// InvitationURL is the global config name for the INVITATION_URL variable
+	InvitationURL = "INVITATION_URL"
+	// InvitationExpiresIn is the global config name for the INVITATION_EXPIRES_IN variable
+	// it is how many seconds an invitation can be accepted for after it is sent or resent
+	InvitationExpiresIn = "INVITATION_EXPIRES_IN"
+
+	// TenantsFile is the global config name for the TENANTS_FILE variable
+	// it is a JSON file holding the tenants, when it is not set there is only the default tenant
+	TenantsFile = "TENANTS_FILE"
+	// TenantHeader is the global config name for the TENANT_HEADER variable
+	// it is the request header that names the tenant of a request
+	TenantHeader = "TENANT_HEADER"
+
+	// BusinessTypeCacheTTL is the global config name for the BUSINESS_TYPE_CACHE_TTL variable
+	// it is how many seconds the business types are cached for before they are read again
+	BusinessTypeCacheTTL = "BUSINESS_TYPE_CACHE_TTL"
+
+	// LoginMaxAttempts is the global config name for the LOGIN_MAX_ATTEMPTS variable
+	LoginMaxAttempts = "LOGIN_MAX_ATTEMPTS"
+	// LoginIPMaxAttempts is the global config name for the LOGIN_IP_MAX_ATTEMPTS variable
+	LoginIPMaxAttempts = "LOGIN_IP_MAX_ATTEMPTS"
+	// LoginBackoffBase is the global config name for the LOGIN_BACKOFF_BASE variable
+	LoginBackoffBase = "LOGIN_BACKOFF_BASE"
+	// LoginBackoffMax is the global config name for the LOGIN_BACKOFF_MAX variable
+	LoginBackoffMax = "LOGIN_BACKOFF_MAX"
+	// LoginLockoutDuration is the global config name for the LOGIN_LOCKOUT_DURATION variable
+	LoginLockoutDuration = "LOGIN_LOCKOUT_DURATION"
+
+	// AdminApiKey is the global config name for the ADMIN_API_KEY variable
+	AdminApiKey = "ADMIN_API_KEY"
+
+	// RateLimitStore is the global config name for the RATE_LIMIT_STORE variable
+	RateLimitStore = "RATE_LIMIT_STORE"
+	// RateLimitSignup is the global config name for the RATE_LIMIT_SIGNUP variable
+	RateLimitSignup = "RATE_LIMIT_SIGNUP"
+	// RateLimitLogin is the global config name for the RATE_LIMIT_LOGIN variable
+	RateLimitLogin = "RATE_LIMIT_LOGIN"
+	// RateLimitMagicLink is the global config name for the RATE_LIMIT_MAGIC_LINK variable
+	RateLimitMagicLink = "RATE_LIMIT_MAGIC_LINK"
+	// RateLimitMagicLinkVerify is the global config name for the RATE_LIMIT_MAGIC_LINK_VERIFY variable
+	RateLimitMagicLinkVerify = "RATE_LIMIT_MAGIC_LINK_VERIFY"
+	// RateLimitRefreshToken is the global config name for the RATE_LIMIT_REFRESH_TOKEN variable
+	RateLimitRefreshToken = "RATE_LIMIT_REFRESH_TOKEN"
+	// RateLimitRevokeSessions is the global config name for the RATE_LIMIT_REVOKE_SESSIONS variable
+	RateLimitRevokeSessions = "RATE_LIMIT_REVOKE_SESSIONS"
+	// RateLimitEmailChangeConfirm is the global config name for the RATE_LIMIT_EMAIL_CHANGE_CONFIRM variable
+	RateLimitEmailChangeConfirm = "RATE_LIMIT_EMAIL_CHANGE_CONFIRM"
+	// RateLimitEmailChangeCancel is the global config name for the RATE_LIMIT_EMAIL_CHANGE_CANCEL variable
+	RateLimitEmailChangeCancel = "RATE_LIMIT_EMAIL_CHANGE_CANCEL"
+	// RateLimitInvitationAccept is the global config name for the RATE_LIMIT_INVITATION_ACCEPT variable
+	RateLimitInvitationAccept = "RATE_LIMIT_INVITATION_ACCEPT"
+	// RateLimitChangePassword is the global config name for the RATE_LIMIT_CHANGE_PASSWORD variable
+	RateLimitChangePassword = "RATE_LIMIT_CHANGE_PASSWORD"
+	// RateLimitPhoneSendCode is the global config name for the RATE_LIMIT_PHONE_SEND_CODE variable
+	RateLimitPhoneSendCode = "RATE_LIMIT_PHONE_SEND_CODE"
+
+	// PasswordMinLength is the global config name for the PASSWORD_MIN_LENGTH variable
+	PasswordMinLength = "PASSWORD_MIN_LENGTH"
+	// PasswordMaxLength is the global config name for the PASSWORD_MAX_LENGTH variable
+	PasswordMaxLength = "PASSWORD_MAX_LENGTH"
+	// PasswordRequireUpperCase is the global config name for the PASSWORD_REQUIRE_UPPER_CASE variable
+	PasswordRequireUpperCase = "PASSWORD_REQUIRE_UPPER_CASE"
+	// PasswordRequireLowerCase is the global config name for the PASSWORD_REQUIRE_LOWER_CASE variable
+	PasswordRequireLowerCase = "PASSWORD_REQUIRE_LOWER_CASE"
+	// PasswordRequireDigit is the global config name for the PASSWORD_REQUIRE_DIGIT variable
+	PasswordRequireDigit = "PASSWORD_REQUIRE_DIGIT"
+	// PasswordRequireSpecialChar is the global config name for the PASSWORD_REQUIRE_SPECIAL_CHAR variable
+	PasswordRequireSpecialChar = "PASSWORD_REQUIRE_SPECIAL_CHAR"
+	// PasswordMinStrength is the global config name for the PASSWORD_MIN_STRENGTH variable
+	PasswordMinStrength = "PASSWORD_MIN_STRENGTH"
+	// PasswordForbidPersonalInfo is the global config name for the PASSWORD_FORBID_PERSONAL_INFO variable
+	PasswordForbidPersonalInfo = "PASSWORD_FORBID_PERSONAL_INFO"
+	// PasswordHistorySize is the global config name for the PASSWORD_HISTORY_SIZE variable
+	PasswordHistorySize = "PASSWORD_HISTORY_SIZE"
+
+	// PasswordHashAlgorithm is the global config name for the PASSWORD_HASH_ALGORITHM variable
+	PasswordHashAlgorithm = "PASSWORD_HASH_ALGORITHM"
+	// PasswordBcryptCost is the global config name for the PASSWORD_BCRYPT_COST variable
+	PasswordBcryptCost = "PASSWORD_BCRYPT_COST"
+	// PasswordArgon2Memory is the global config name for the PASSWORD_ARGON2_MEMORY variable, in KiB
+	PasswordArgon2Memory = "PASSWORD_ARGON2_MEMORY"
+	// PasswordArgon2Time is the global config name for the PASSWORD_ARGON2_TIME variable
+	PasswordArgon2Time = "PASSWORD_ARGON2_TIME"
+	// PasswordArgon2Threads is the global config name for the PASSWORD_ARGON2_THREADS variable
+	PasswordArgon2Threads = "PASSWORD_ARGON2_THREADS"
+	// PasswordHashConcurrency is the global config name for the PASSWORD_HASH_CONCURRENCY variable
+	// zero uses one hashing slot per CPU
+	PasswordHashConcurrency = "PASSWORD_HASH_CONCURRENCY"
+	// PasswordHashQueueDepth is the global config name for the PASSWORD_HASH_QUEUE_DEPTH variable
+	PasswordHashQueueDepth = "PASSWORD_HASH_QUEUE_DEPTH"
+	// PasswordHashRetryAfter is the global config name for the PASSWORD_HASH_RETRY_AFTER variable
+	PasswordHashRetryAfter = "PASSWORD_HASH_RETRY_AFTER"
+
+	// BreachedPasswordsMode is the global config name for the BREACHED_PASSWORDS_MODE variable
+	// it is one of `off`, `hibp` for a prefix-partitioned HIBP dump or `bloom` for a bloom filter
+	BreachedPasswordsMode = "BREACHED_PASSWORDS_MODE"
+	// BreachedPasswordsPath is the global config name for the BREACHED_PASSWORDS_PATH variable
+	BreachedPasswordsPath = "BREACHED_PASSWORDS_PATH"
+
+	// JobWorkers is the global config name for the JOB_WORKERS variable
+	JobWorkers = "JOB_WORKERS"
+	// JobPollInterval is the global config name for the JOB_POLL_INTERVAL variable, in seconds
+	JobPollInterval = "JOB_POLL_INTERVAL"
+	// JobLease is the global config name for the JOB_LEASE variable
+	// it is how many seconds a worker holds a job before another worker may run it again
+	JobLease = "JOB_LEASE"
+	// JobMaxAttempts is the global config name for the JOB_MAX_ATTEMPTS variable
+	JobMaxAttempts = "JOB_MAX_ATTEMPTS"
+	// JobBackoffBase is the global config name for the JOB_BACKOFF_BASE variable, in seconds
+	JobBackoffBase = "JOB_BACKOFF_BASE"
+	// JobBackoffMax is the global config name for the JOB_BACKOFF_MAX variable, in seconds
+	JobBackoffMax = "JOB_BACKOFF_MAX"
+
+	// AuditSigningKey is the global config name for the AUDIT_SIGNING_KEY variable
+	// it is a base64 encoded ed25519 seed, and audit checkpoints are not signed without it
+	AuditSigningKey = "AUDIT_SIGNING_KEY"
+	// AuditCheckpointInterval is the global config name for the AUDIT_CHECKPOINT_INTERVAL variable
+	AuditCheckpointInterval = "AUDIT_CHECKPOINT_INTERVAL"
+	// AuditRetention is the global config name for the AUDIT_RETENTION variable
+	// it is how many seconds audit events are kept for, 0 keeps them forever
+	// events are only removed up to a signed checkpoint, so it needs AUDIT_SIGNING_KEY to be set
+	AuditRetention = "AUDIT_RETENTION"
+
+	// IdempotencyKeyExpiresIn is the global config name for the IDEMPOTENCY_KEY_EXPIRES_IN variable
+	// it is how many seconds the response to a request with an Idempotency-Key header is replayed for
+	IdempotencyKeyExpiresIn = "IDEMPOTENCY_KEY_EXPIRES_IN"
+
+	// MigrateOnStartup is the global config name for the MIGRATE_ON_STARTUP variable
+	// when it is off, the migrations have to be applied with the `migrate up` command, the server refuses to start while any are pending
+	MigrateOnStartup = "MIGRATE_ON_STARTUP"
+	// MigrationTimeout is the global config name for the MIGRATION_TIMEOUT variable
+	// it is how many seconds the server waits for the migrations, including for another replica to finish them
+	MigrationTimeout = "MIGRATION_TIMEOUT"
+
+	// MaintenanceInterval is the global config name for the MAINTENANCE_INTERVAL variable
+	// it is how many seconds the maintenance sweeps wait between runs, 0 turns them off
+	MaintenanceInterval = "MAINTENANCE_INTERVAL"
+	// LoginHistoryRetention is the global config name for the LOGIN_HISTORY_RETENTION variable, in seconds
+	LoginHistoryRetention = "LOGIN_HISTORY_RETENTION"
+	// JobRetention is the global config name for the JOB_RETENTION variable
+	// it is how many seconds finished jobs are kept for, whether they were done or dead
+	JobRetention = "JOB_RETENTION"
 )
 
+// RateLimitRoutes are the config names of the per-route rate limits
+// each value has the format `key:limit/window:algorithm`, e.g. `ip:10/1m:sliding_window`
+var RateLimitRoutes = []string{
+	RateLimitSignup, RateLimitLogin, RateLimitMagicLink, RateLimitMagicLinkVerify, RateLimitRefreshToken,
+	RateLimitRevokeSessions, RateLimitEmailChangeConfirm, RateLimitEmailChangeCancel, RateLimitInvitationAccept,
+	RateLimitChangePassword, RateLimitPhoneSendCode,
+}
+
+// optionalConfig holds the config variables that fall back to a default value when they are not set
+var optionalConfig = map[string]string{
+	MailFrom:     "",
+	MailUsername: "",
+	MailPassword: "",
+
+	MailTransport:              "smtp",
+	MailSMTPHost:               "smtp.gmail.com",
+	MailSMTPPort:               "587",
+	MailSMTPTLS:                "starttls",
+	MailSMTPInsecureSkipVerify: "false",
+	MailOutboxDir:              "./outbox",
+	MailDefaultLocale:          "en",
+
+	SMSTransport: "log",
+	SMSFrom:      "",
+
+	PhoneCodeExpiresIn:      "600",
+	PhoneCodeMaxAttempts:    "5",
+	PhoneCodeResendInterval: "60",
+	PhoneCodeSendLimit:      "5",
+	PhoneCodeSendWindow:     "3600",
+
+	MagicLinkEnabled:    "false",
+	MagicLinkSecretKey:  "",
+	MagicLinkExpiresIn:  "900",
+	MagicLinkURL:        "http://localhost:8080/magic-link",
+	MagicLinkRateLimit:  "3",
+	MagicLinkRateWindow: "3600",
+
+	LoginAlertsEnabled:      "false",
+	LoginAlertSecretKey:     "",
+	LoginAlertRevokeURL:     "http://localhost:8080/revoke-sessions",
+	LoginAlertLinkExpiresIn: "604800",
+
+	EmailChangeConfirmURL: "http://localhost:8080/confirm-email",
+	EmailChangeCancelURL:  "http://localhost:8080/cancel-email-change",
+	EmailChangeExpiresIn:  "86400",
+
+	InvitationSecretKey: "",
+	InvitationURL:       "http://localhost:8080/accept-invitation",
+	InvitationExpiresIn: "604800",
+
+	TenantsFile:  "",
+	TenantHeader: "X-Tenant-Id",
+
+	BusinessTypeCacheTTL: "60",
+
+	LoginMaxAttempts:     "5",
+	LoginIPMaxAttempts:   "50",
+	LoginBackoffBase:     "1",
+	LoginBackoffMax:      "300",
+	LoginLockoutDuration: "900",
+
+	AdminApiKey: "",
+
+	RateLimitStore:              "memory",
+	RateLimitSignup:             "ip:10/1h:sliding_window",
+	RateLimitLogin:              "ip:20/1m:token_bucket",
+	RateLimitMagicLink:          "email:5/1h:sliding_window",
+	RateLimitMagicLinkVerify:    "ip:20/1m:token_bucket",
+	RateLimitRefreshToken:       "ip:60/1m:token_bucket",
+	RateLimitRevokeSessions:     "ip:20/1m:token_bucket",
+	RateLimitEmailChangeConfirm: "ip:20/1m:token_bucket",
+	RateLimitEmailChangeCancel:  "ip:20/1m:token_bucket",
+	RateLimitInvitationAccept:   "ip:20/1h:sliding_window",
+	RateLimitChangePassword:     "client:10/1h:sliding_window",
+	RateLimitPhoneSendCode:      "client:10/1h:sliding_window",
+
+	PasswordMinLength:          "8",
+	PasswordMaxLength:          "128",
+	PasswordRequireUpperCase:   "true",
+	PasswordRequireLowerCase:   "true",
+	PasswordRequireDigit:       "true",
+	PasswordRequireSpecialChar: "true",
+	PasswordMinStrength:        "2",
+	PasswordForbidPersonalInfo: "true",
+	PasswordHistorySize:        "5",
+
+	PasswordHashAlgorithm: "argon2id",
+	PasswordBcryptCost:    "12",
+	PasswordArgon2Memory:  "65536",
+	PasswordArgon2Time:    "3",
+	PasswordArgon2Threads: "2",
+
+	PasswordHashConcurrency: "0",
+	PasswordHashQueueDepth:  "64",
+	PasswordHashRetryAfter:  "1",
+
+	BreachedPasswordsMode: "off",
+	BreachedPasswordsPath: "",
+
+	JobWorkers:      "4",
+	JobPollInterval: "1",
+	JobLease:        "60",
+	JobMaxAttempts:  "5",
+	JobBackoffBase:  "5",
+	JobBackoffMax:   "3600",
+
+	AuditSigningKey:         "",
+	AuditCheckpointInterval: "1000",
+	AuditRetention:          "0",
+
+	IdempotencyKeyExpiresIn: "86400",
+
+	MigrateOnStartup: "true",
+	MigrationTimeout: "300",
+
+	MaintenanceInterval:   "3600",
+	LoginHistoryRetention: "7776000",
+	JobRetention:          "604800",
+}
+
 // getEnv retrieves teh value of a given key from the environment variables set
 func getEnv(key string) (string, error) {
 	if value, exists := os.LookupEnv(key); exists {
@@ -60,5 +400,14 @@ func InitConfig() (*map[string]string, error) {
 		Map[c] = v
 	}
 
+	// iterate the optional config variables and use their defaults if they are not set
+	for c, def := range optionalConfig {
+		v, err := getEnv(c)
+		if err != nil {
+			v = def
+		}
+		Map[c] = v
+	}
+
 	return &Map, nil
 }
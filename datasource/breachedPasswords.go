@@ -0,0 +1,116 @@
+package datasource
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// HIBPPrefixLength is the length of the SHA-1 prefix that a HIBP range dump is partitioned by
+const HIBPPrefixLength = 5
+
+// hibpDirectoryChecker checks passwords against a HIBP dump partitioned into one file per SHA-1 prefix
+// each file holds `SUFFIX:COUNT` lines for the hashes that start with the prefix in its name
+type hibpDirectoryChecker struct {
+	dir string
+}
+
+// bloomFilterChecker checks passwords against a bloom filter built from the SHA-1 hashes of a HIBP dump
+type bloomFilterChecker struct {
+	filter *utils.BloomFilter
+}
+
+// InitBreachedPasswordChecker loads the breached password checker for the mode
+// it returns nil if breached password screening is turned off
+func InitBreachedPasswordChecker(mode, path string) (interfaces.BreachedPasswordCheckerInterface, error) {
+	switch mode {
+	case "", "off":
+		return nil, nil
+	case "hibp":
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached password directory: %v", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("breached password path is not a directory: %s", path)
+		}
+		log.Printf("Loaded breached password directory: %s\n", path)
+		return &hibpDirectoryChecker{dir: path}, nil
+	case "bloom":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached password filter: %v", err)
+		}
+		defer f.Close()
+
+		filter, err := utils.ReadBloomFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded breached password filter: %s\n", path)
+		return &bloomFilterChecker{filter: filter}, nil
+	default:
+		return nil, fmt.Errorf("invalid breached password mode: %s", mode)
+	}
+}
+
+// IsBreached checks if the password's hash is listed in the file for its prefix
+func (hc *hibpDirectoryChecker) IsBreached(password string) (bool, error) {
+	hash := PasswordSHA1(password)
+	prefix, suffix := hash[:HIBPPrefixLength], hash[HIBPPrefixLength:]
+
+	f, err := hc.openRange(prefix)
+	if err != nil {
+		// no file for the prefix means no breached password has it
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// openRange opens the file for a prefix, which the HIBP downloader names with or without a .txt extension
+func (hc *hibpDirectoryChecker) openRange(prefix string) (*os.File, error) {
+	f, err := os.Open(filepath.Join(hc.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.Open(filepath.Join(hc.dir, prefix))
+	}
+	return f, err
+}
+
+// IsBreached checks if the password's hash may be in the filter
+// a bloom filter can report a password that was never breached, but never misses one that was
+func (bc *bloomFilterChecker) IsBreached(password string) (bool, error) {
+	hash := sha1.Sum([]byte(password))
+	return bc.filter.Test(hash[:]), nil
+}
+
+// PasswordSHA1 returns the upper case hex SHA-1 hash of a password, as used in HIBP dumps
+func PasswordSHA1(password string) string {
+	hash := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(hash[:]))
+}
@@ -4,11 +4,15 @@ import (
     "log"
 
     "github.com/leonardchinonso/auth_service_cmp7174/config"
+    "github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
 )
 
 type DataSource struct {
     *DatabaseContext
     Cfg *map[string]string
+    BreachedPasswords interfaces.BreachedPasswordCheckerInterface
+    MailTransport interfaces.MailTransportInterface
+    SMSSender interfaces.SMSSenderInterface
 }
 
 // InitDataSource initializes the data source
@@ -27,9 +31,30 @@ func InitDataSource() (*DataSource, error) {
         return nil, err
     }
 
+    // load the breached password list if screening is turned on
+    breachedPasswords, err := InitBreachedPasswordChecker((*configMap)[config.BreachedPasswordsMode], (*configMap)[config.BreachedPasswordsPath])
+    if err != nil {
+        return nil, err
+    }
+
+    // set up the configured mail delivery
+    mailTransport, err := InitMailTransport(configMap)
+    if err != nil {
+        return nil, err
+    }
+
+    // set up the configured text message delivery
+    smsSender, err := InitSMSSender(configMap)
+    if err != nil {
+        return nil, err
+    }
+
     return &DataSource{
         DatabaseContext: dbCtx,
         Cfg: configMap,
+        BreachedPasswords: breachedPasswords,
+        MailTransport: mailTransport,
+        SMSSender: smsSender,
     }, nil
 }
 
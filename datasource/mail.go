@@ -0,0 +1,136 @@
+package datasource
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/gomail.v2"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// mail transports that can be configured
+const (
+	MailTransportSMTP   = "smtp"
+	MailTransportOutbox = "outbox"
+	MailTransportLog    = "log"
+)
+
+// smtpTransport delivers mail to an SMTP server
+type smtpTransport struct {
+	dialer *gomail.Dialer
+}
+
+// outboxTransport writes mail to a directory as .eml files so it can be read locally
+type outboxTransport struct {
+	dir string
+}
+
+// logTransport writes who mail is sent to to the application log
+// the bodies are left out as they hold login links and tokens, the outbox transport keeps them
+type logTransport struct{}
+
+// InitMailTransport creates the mail transport chosen in the config
+func InitMailTransport(cfg *map[string]string) (interfaces.MailTransportInterface, error) {
+	switch transport := (*cfg)[config.MailTransport]; transport {
+	case MailTransportSMTP:
+		return newSMTPTransport(cfg)
+	case MailTransportOutbox:
+		dir := (*cfg)[config.MailOutboxDir]
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create mail outbox: %v", err)
+		}
+		log.Printf("Writing mail to outbox: %s\n", dir)
+		return &outboxTransport{dir: dir}, nil
+	case MailTransportLog:
+		return &logTransport{}, nil
+	default:
+		return nil, fmt.Errorf("invalid mail transport: %s", transport)
+	}
+}
+
+// newSMTPTransport creates an SMTP transport for the configured host, port and TLS mode
+func newSMTPTransport(cfg *map[string]string) (*smtpTransport, error) {
+	host := (*cfg)[config.MailSMTPHost]
+	port, err := strconv.Atoi((*cfg)[config.MailSMTPPort])
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp port: %v", err)
+	}
+
+	insecureSkipVerify, err := strconv.ParseBool((*cfg)[config.MailSMTPInsecureSkipVerify])
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := gomail.NewDialer(host, port, (*cfg)[config.MailUsername], (*cfg)[config.MailPassword])
+	dialer.TLSConfig = &tls.Config{ServerName: host, InsecureSkipVerify: insecureSkipVerify}
+
+	switch mode := (*cfg)[config.MailSMTPTLS]; mode {
+	case "starttls":
+		dialer.SSL = false
+	case "tls":
+		dialer.SSL = true
+	default:
+		return nil, fmt.Errorf("invalid smtp tls mode: %s", mode)
+	}
+
+	return &smtpTransport{dialer: dialer}, nil
+}
+
+// Deliver sends the mail to the SMTP server
+func (st *smtpTransport) Deliver(ctx context.Context, mail *dto.Mail) error {
+	if err := st.dialer.DialAndSend(newMailMessage(mail)); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %v", mail.To, err)
+	}
+	return nil
+}
+
+// Deliver writes the mail to a new file in the outbox
+func (ot *outboxTransport) Deliver(ctx context.Context, mail *dto.Mail) error {
+	suffix, err := utils.RandomToken(4)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000Z"), suffix)
+	f, err := os.Create(filepath.Join(ot.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create outbox file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err = newMailMessage(mail).WriteTo(f); err != nil {
+		return fmt.Errorf("failed to write outbox file: %v", err)
+	}
+	return nil
+}
+
+// Deliver logs the mail without its body
+func (lt *logTransport) Deliver(ctx context.Context, mail *dto.Mail) error {
+	log.Printf("Mail from: %s to: %s subject: %q body: [redacted, %d bytes]\n", mail.From, mail.To, mail.Subject, len(mail.Text)+len(mail.HTML))
+	return nil
+}
+
+// newMailMessage builds a multipart message with the text and html versions of the mail
+func newMailMessage(mail *dto.Mail) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", mail.From)
+	m.SetHeader("To", mail.To)
+	m.SetHeader("Subject", mail.Subject)
+
+	m.SetBody("text/plain", mail.Text)
+	if mail.HTML != "" {
+		m.AddAlternative("text/html", mail.HTML)
+	}
+
+	return m
+}
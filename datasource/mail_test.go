@@ -0,0 +1,106 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+func testMail() *dto.Mail {
+	return &dto.Mail{
+		From:    "no-reply@example.com",
+		To:      "jane@example.com",
+		Subject: "Your login link",
+		Text:    "Hello Jane, log in at https://example.com/login?token=secret-text-token",
+		HTML:    `<p>Hello Jane, <a href="https://example.com/login?token=secret-html-token">log in</a></p>`,
+	}
+}
+
+func TestOutboxTransportCapturesMail(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	cfg := map[string]string{
+		config.MailTransport: MailTransportOutbox,
+		config.MailOutboxDir: dir,
+	}
+
+	transport, err := InitMailTransport(&cfg)
+	if err != nil {
+		t.Fatalf("InitMailTransport() error = %v", err)
+	}
+
+	mail := testMail()
+	for i := 0; i < 2; i++ {
+		if err := transport.Deliver(context.Background(), mail); err != nil {
+			t.Fatalf("Deliver() error = %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("outbox has %d .eml files, want 2", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// the bodies are quoted-printable, so they are decoded before they are searched
+	raw, err := io.ReadAll(quotedprintable.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eml := string(raw)
+	for _, want := range []string{
+		"From: " + mail.From,
+		"To: " + mail.To,
+		"Subject: " + mail.Subject,
+		"Content-Type: text/plain",
+		"Content-Type: text/html",
+		"secret-text-token",
+		"secret-html-token",
+	} {
+		if !strings.Contains(eml, want) {
+			t.Errorf("outbox mail does not contain %q:\n%s", want, eml)
+		}
+	}
+}
+
+func TestLogTransportRedactsBodies(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	mail := testMail()
+	if err := (&logTransport{}).Deliver(context.Background(), mail); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, mail.To) || !strings.Contains(logged, mail.Subject) {
+		t.Errorf("log does not name the recipient and subject: %s", logged)
+	}
+	if strings.Contains(logged, "secret-text-token") || strings.Contains(logged, "secret-html-token") {
+		t.Errorf("log contains the mail body: %s", logged)
+	}
+}
+
+func TestInitMailTransportRejectsUnknownTransport(t *testing.T) {
+	cfg := map[string]string{config.MailTransport: "pigeon"}
+	if _, err := InitMailTransport(&cfg); err == nil {
+		t.Fatal("InitMailTransport() error = nil, want an error for an unknown transport")
+	}
+}
@@ -0,0 +1,37 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// SMS transports that can be configured
+// a provider is added by implementing interfaces.SMSSenderInterface and choosing it here
+const (
+	SMSTransportLog = "log"
+)
+
+// logSMSSender writes text messages to the application log, for development and testing
+type logSMSSender struct {
+	from string
+}
+
+// InitSMSSender creates the SMS sender chosen in the config
+func InitSMSSender(cfg *map[string]string) (interfaces.SMSSenderInterface, error) {
+	switch transport := (*cfg)[config.SMSTransport]; transport {
+	case SMSTransportLog:
+		return &logSMSSender{from: (*cfg)[config.SMSFrom]}, nil
+	default:
+		return nil, fmt.Errorf("invalid sms transport: %s", transport)
+	}
+}
+
+// Send logs the text message
+func (ls *logSMSSender) Send(ctx context.Context, to, message string) error {
+	log.Printf("SMS from: %s to: %s\n%s\n", ls.from, to, message)
+	return nil
+}
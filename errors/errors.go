@@ -3,6 +3,7 @@ package errors
 import (
 	"errors"
 	"net/http"
+	"time"
 )
 
 const (
@@ -16,6 +17,8 @@ type RestError struct {
 	Message string      `json:"message"`
 	Err     string      `json:"error"`
 	Data    interface{} `json:"data"`
+	// RetryAfter is the number of seconds the caller should wait before retrying, if any
+	RetryAfter int `json:"retry_after,omitempty"`
 }
 
 // Error returns the message from RestError
@@ -64,6 +67,149 @@ func ErrUnauthorized(message string, data interface{}) *RestError {
 	}
 }
 
+// ErrForbidden returns a RestError for a request the caller is not allowed to make
+func ErrForbidden(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusForbidden,
+		Message: message,
+		Err:     "Forbidden",
+		Data:    data,
+	}
+}
+
+// ErrNotFound returns a RestError for a request for a resource that does not exist
+func ErrNotFound(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusNotFound,
+		Message: message,
+		Err:     "Not Found",
+		Data:    data,
+	}
+}
+
+// ErrConflict returns a RestError for a request that conflicts with the current state of a resource
+func ErrConflict(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusConflict,
+		Message: message,
+		Err:     "Conflict",
+		Data:    data,
+	}
+}
+
+// ErrPreconditionFailed returns a RestError for a request whose precondition, such as If-Match, does not hold
+func ErrPreconditionFailed(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusPreconditionFailed,
+		Message: message,
+		Err:     "Precondition Failed",
+		Data:    data,
+	}
+}
+
+// ErrPreconditionRequired returns a RestError for a request that has to be conditional, e.g. carry an If-Match header
+func ErrPreconditionRequired(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusPreconditionRequired,
+		Message: message,
+		Err:     "Precondition Required",
+		Data:    data,
+	}
+}
+
+// ErrUnsupportedMediaType returns a RestError for a request body sent in a format that is not accepted
+func ErrUnsupportedMediaType(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusUnsupportedMediaType,
+		Message: message,
+		Err:     "Unsupported Media Type",
+		Data:    data,
+	}
+}
+
+// ErrRequestEntityTooLarge returns a RestError for a request body that is larger than accepted
+func ErrRequestEntityTooLarge(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Message: message,
+		Err:     "Request Entity Too Large",
+		Data:    data,
+	}
+}
+
+// ErrUnprocessableEntity returns a RestError for a well formed request that cannot be processed
+func ErrUnprocessableEntity(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusUnprocessableEntity,
+		Message: message,
+		Err:     "Unprocessable Entity",
+		Data:    data,
+	}
+}
+
+// RetryAfter returns how long the caller should wait before retrying
+// it returns zero if the error does not carry a retry hint
+func RetryAfter(err error) time.Duration {
+	var re *RestError
+	if errors.As(err, &re) {
+		return time.Duration(re.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// ErrTooManyRequests returns a RestError for a request that has exceeded its rate limit
+// retryAfter is rounded up to whole seconds for the Retry-After hint
+func ErrTooManyRequests(message string, retryAfter time.Duration, data interface{}) *RestError {
+	return &RestError{
+		Status:     http.StatusTooManyRequests,
+		Message:    message,
+		Err:        "Too Many Requests",
+		Data:       data,
+		RetryAfter: retryAfterSeconds(retryAfter),
+	}
+}
+
+// ErrLocked returns a RestError for a resource that is temporarily locked
+// retryAfter is rounded up to whole seconds for the Retry-After hint
+func ErrLocked(message string, retryAfter time.Duration, data interface{}) *RestError {
+	return &RestError{
+		Status:     http.StatusLocked,
+		Message:    message,
+		Err:        "Locked",
+		Data:       data,
+		RetryAfter: retryAfterSeconds(retryAfter),
+	}
+}
+
+// ErrServiceUnavailable returns a RestError for a request the service is too busy to handle
+// retryAfter is rounded up to whole seconds for the Retry-After hint
+func ErrServiceUnavailable(message string, retryAfter time.Duration, data interface{}) *RestError {
+	return &RestError{
+		Status:     http.StatusServiceUnavailable,
+		Message:    message,
+		Err:        "Service Unavailable",
+		Data:       data,
+		RetryAfter: retryAfterSeconds(retryAfter),
+	}
+}
+
+// AsRestError returns the RestError wrapped in err, if there is one
+func AsRestError(err error) (*RestError, bool) {
+	var re *RestError
+	if errors.As(err, &re) {
+		return re, true
+	}
+	return nil, false
+}
+
+// retryAfterSeconds rounds a duration up to whole seconds
+func retryAfterSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
 // ErrorToStringSlice converts a slice of errors to a slice of string
 func ErrorToStringSlice(errs []error) []string {
 	var errStrings []string
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// AdminHandler handles requests that are reserved for administrators
+type AdminHandler struct {
+	loginGuardService interfaces.LoginGuardServiceInterface
+	auditService      interfaces.AuditServiceInterface
+}
+
+// InitAdminHandler initializes and sets up the admin handler
+func InitAdminHandler(router *gin.Engine, version, adminApiKey string, loginGuardService interfaces.LoginGuardServiceInterface, auditService interfaces.AuditServiceInterface) {
+	h := &AdminHandler{
+		loginGuardService: loginGuardService,
+		auditService:      auditService,
+	}
+
+	// group routes according to paths
+	path := fmt.Sprintf("%s%s", version, "/admin")
+	g := router.Group(path, middlewares.AuthorizeAdmin(adminApiKey))
+
+	// register endpoints
+	g.POST("/unlock-login", h.UnlockLogin)
+	g.GET("/metrics", gin.WrapH(expvar.Handler()))
+	g.GET("/audit-events", h.ListAuditEvents)
+}
+
+// UnlockLogin handles the request to lift a login lockout for an email and/or ip
+func (h *AdminHandler) UnlockLogin(c *gin.Context) {
+	var ur dto.UnlockRequest
+
+	// fill the unlock request from binding the JSON request
+	if err := c.ShouldBindJSON(&ur); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the unlock request for invalid fields
+	if errs := ur.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid unlock request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	if err := h.loginGuardService.Unlock(c, string(ur.Email), ur.IP); err != nil {
+		log.Printf("Failed to unlock login. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	h.auditService.Record(c, dao.NewAuditEvent(dao.AuditLoginUnlocked, dao.AuditTypeAdmin, "", "").
+		With("email", string(ur.Email)).
+		With("ip", ur.IP))
+
+	resp := utils.ResponseStatusOK("login unlocked successfully", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// ListAuditEvents handles the request to list the audit events that match the query, newest first
+func (h *AdminHandler) ListAuditEvents(c *gin.Context) {
+	var query dto.AuditEventQuery
+
+	// fill the audit event query from binding the query string
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the audit event query for invalid fields
+	if errs := query.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid audit event query", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	page, err := h.auditService.Query(c, &query)
+	if err != nil {
+		log.Printf("Failed to list audit events. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("audit events retrieved successfully", page)
+	c.JSON(resp.Status, resp)
+}
@@ -3,9 +3,12 @@ package handler
 import (
 	"fmt"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
 	"github.com/leonardchinonso/auth_service_cmp7174/errors"
 	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
@@ -18,13 +21,25 @@ import (
 type AuthHandler struct {
 	clientService  interfaces.ClientServiceInterface
 	tokenService interfaces.TokenServiceInterface
+	magicLinkService interfaces.MagicLinkServiceInterface
+	loginHistoryService interfaces.LoginHistoryServiceInterface
+	loginAlertService interfaces.LoginAlertServiceInterface
+	emailChangeService interfaces.EmailChangeServiceInterface
+	organizationService interfaces.OrganizationServiceInterface
+	invitationService interfaces.InvitationServiceInterface
 }
 
 // InitAuthHandler initializes and sets up the auth handler
-func InitAuthHandler(router *gin.Engine, version string, clientService interfaces.ClientServiceInterface, tokenService interfaces.TokenServiceInterface) {
+func InitAuthHandler(router *gin.Engine, version string, rateLimiter *middlewares.RateLimiter, clientService interfaces.ClientServiceInterface, tokenService interfaces.TokenServiceInterface, magicLinkService interfaces.MagicLinkServiceInterface, loginHistoryService interfaces.LoginHistoryServiceInterface, loginAlertService interfaces.LoginAlertServiceInterface, emailChangeService interfaces.EmailChangeServiceInterface, organizationService interfaces.OrganizationServiceInterface, invitationService interfaces.InvitationServiceInterface) {
 	h := &AuthHandler{
 		clientService:  clientService,
 		tokenService: tokenService,
+		magicLinkService: magicLinkService,
+		loginHistoryService: loginHistoryService,
+		loginAlertService: loginAlertService,
+		emailChangeService: emailChangeService,
+		organizationService: organizationService,
+		invitationService: invitationService,
 	}
 
 	// group routes according to paths
@@ -32,9 +47,30 @@ func InitAuthHandler(router *gin.Engine, version string, clientService interface
 	g := router.Group(path)
 
 	// register endpoints
-	g.POST("/signup", h.Signup)
-	g.POST("/login", h.Login)
+	g.POST("/signup", rateLimiter.For(config.RateLimitSignup), middlewares.ReplaysTokens(h.replaySignup), h.Signup)
+	g.POST("/login", rateLimiter.For(config.RateLimitLogin), middlewares.ReturnsTokens(), h.Login)
 	g.POST("/logout", middlewares.AuthorizeClient(h.tokenService), h.Logout)
+	g.POST("/refresh-token", rateLimiter.For(config.RateLimitRefreshToken), middlewares.ReturnsTokens(), h.RefreshToken)
+
+	// passwordless login is only exposed when it is enabled for the deployment
+	if h.magicLinkService.Enabled() {
+		g.POST("/magic-link", rateLimiter.For(config.RateLimitMagicLink), h.SendMagicLink)
+		g.POST("/magic-link/verify", rateLimiter.For(config.RateLimitMagicLinkVerify), middlewares.ReturnsTokens(), h.VerifyMagicLink)
+	}
+
+	// the "this wasn't me" link from login alerts is only exposed when they are enabled
+	if h.loginAlertService.Enabled() {
+		g.POST("/revoke-sessions", rateLimiter.For(config.RateLimitRevokeSessions), h.RevokeSessions)
+	}
+
+	// the links from email change mails are used without signing in, since the new address cannot sign in yet
+	g.POST("/email-change/confirm", rateLimiter.For(config.RateLimitEmailChangeConfirm), h.ConfirmEmailChange)
+	g.POST("/email-change/cancel", rateLimiter.For(config.RateLimitEmailChangeCancel), h.CancelEmailChange)
+
+	// invitations are accepted by signing up here, or by logging in and accepting them on the organizations endpoints
+	if h.invitationService.Enabled() {
+		g.POST("/invitations/accept", rateLimiter.For(config.RateLimitInvitationAccept), middlewares.ReturnsTokens(), h.AcceptInvitation)
+	}
 }
 
 // Signup handles the incoming signup request
@@ -57,12 +93,14 @@ func (ah *AuthHandler) Signup(c *gin.Context) {
 	}
 
 	// create ah new client object with the details
-	client := dao.NewClient(sr.Name, string(sr.Email), sr.Address, string(sr.Password), string(sr.BusinessType), sr.ApiKey)
+	client := dao.NewClient(sr.Name, string(sr.Email), string(sr.Password))
+	client.Locale = sr.Locale
 
 	// start the signup process
 	clientId, err := ah.clientService.Signup(c, client, sr.Password)
 	if err != nil {
 		log.Printf("Failed to sign client up. Error: %v\n", err.Error())
+		SetRetryAfter(c, err)
 		c.JSON(errors.Status(err), err)
 		return
 	}
@@ -77,6 +115,18 @@ func (ah *AuthHandler) Signup(c *gin.Context) {
 
 	fmt.Printf("Client retrieved: %+v\n", client)
 
+	// the business profile the client signed up with becomes an organization they own
+	// the client is removed again if it cannot be created, so the signup can be retried with the same email
+	organization := dao.NewOrganization(sr.Name, sr.Address.Normalized(), string(sr.BusinessType), sr.ApiKey)
+	if err = ah.organizationService.Create(c, client, organization); err != nil {
+		log.Printf("Failed to create organization for new client. Error: %v\n", err.Error())
+		if revertErr := ah.clientService.RevertSignup(c, client); revertErr != nil {
+			log.Printf("Failed to revert signup of client. Error: %v\n", revertErr.Error())
+		}
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
 	// create the access and refresh token pairs
 	at, rt, err := ah.tokenService.GenerateTokenPair(c, client)
 	if err != nil {
@@ -85,6 +135,9 @@ func (ah *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
+	// a retry of the signup with the same idempotency key is issued new tokens for this client
+	middlewares.TokensIssuedTo(c, client.Id)
+
 	// create signup (login) response and return it to the handler's caller
 	loginResp := dto.NewLoginResponse(*client, at, rt)
 	resp := utils.ResponseStatusCreated("signed up successfully", loginResp)
@@ -92,6 +145,28 @@ func (ah *AuthHandler) Signup(c *gin.Context) {
 	c.JSON(resp.Status, resp)
 }
 
+// replaySignup answers a retry of a signup that already succeeded with a new token pair for the client it created
+func (ah *AuthHandler) replaySignup(c *gin.Context, clientId primitive.ObjectID) {
+	client, err := ah.clientService.GetClientByID(c, clientId)
+	if err != nil {
+		log.Printf("Failed to get client from database. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	at, rt, err := ah.tokenService.GenerateTokenPair(c, client)
+	if err != nil {
+		log.Printf("Failed to generate client token pair. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	loginResp := dto.NewLoginResponse(*client, at, rt)
+	resp := utils.ResponseStatusCreated("signed up successfully", loginResp)
+
+	c.JSON(resp.Status, resp)
+}
+
 // Login handles the incoming login request
 func (ah *AuthHandler) Login(c *gin.Context) {
 	var lr dto.LoginRequest
@@ -118,6 +193,13 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 	err := ah.clientService.Login(c, client, lr.Password)
 	if err != nil {
 		log.Printf("Failed to login client. Error: %v\n", err.Error())
+
+		// the client is only known if the email was found, so failures for unknown emails are not recorded
+		if !client.Id.IsZero() {
+			ah.loginHistoryService.Record(c, dao.NewLoginHistoryEntry(client.Id, dao.LoginMethodPassword, false).Failed(loginFailureReason(err)))
+		}
+
+		SetRetryAfter(c, err)
 		c.JSON(errors.Status(err), err)
 		return
 	}
@@ -130,6 +212,10 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// alert the client about a login from a new device or ip before it becomes part of their history
+	ah.loginAlertService.CheckLogin(c, client)
+	ah.loginHistoryService.Record(c, dao.NewLoginHistoryEntry(client.Id, dao.LoginMethodPassword, true))
+
 	// create ah login response and return it to the handler's caller
 	loginResp := dto.NewLoginResponse(*client, at, rt)
 	resp := utils.ResponseStatusCreated("logged in successfully", loginResp)
@@ -158,3 +244,263 @@ func (ah *AuthHandler) Logout(c *gin.Context) {
 	resp := utils.ResponseStatusOK("logged out successfully", nil)
 	c.JSON(resp.Status, resp)
 }
+
+// SendMagicLink handles the request to email a passwordless login link
+func (ah *AuthHandler) SendMagicLink(c *gin.Context) {
+	var mlr dto.MagicLinkRequest
+
+	// fill the magic link request from binding the JSON request
+	if err := c.ShouldBindJSON(&mlr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the magic link request for invalid fields
+	if errs := mlr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid magic link request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	if err := ah.magicLinkService.SendLink(c, string(mlr.Email)); err != nil {
+		log.Printf("Failed to send magic link. Error: %v\n", err.Error())
+		SetRetryAfter(c, err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("if the email is registered, a login link has been sent to it", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// VerifyMagicLink handles the request to exchange a magic link for a login
+func (ah *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	var mlvr dto.MagicLinkVerifyRequest
+
+	// fill the magic link verification request from binding the JSON request
+	if err := c.ShouldBindJSON(&mlvr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the magic link verification request for invalid fields
+	if errs := mlvr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid magic link request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// exchange the link for the client it was sent to
+	client, err := ah.magicLinkService.VerifyLink(c, mlvr.Token)
+	if err != nil {
+		log.Printf("Failed to verify magic link. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	// create the access and refresh token pairs
+	at, rt, err := ah.tokenService.GenerateTokenPair(c, client)
+	if err != nil {
+		log.Printf("Failed to generate client token pair. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	ah.loginAlertService.CheckLogin(c, client)
+	ah.loginHistoryService.Record(c, dao.NewLoginHistoryEntry(client.Id, dao.LoginMethodMagicLink, true))
+
+	loginResp := dto.NewLoginResponse(*client, at, rt)
+	resp := utils.ResponseStatusCreated("logged in successfully", loginResp)
+
+	c.JSON(resp.Status, resp)
+}
+
+// RefreshToken handles the request to exchange a refresh token for a new token pair
+func (ah *AuthHandler) RefreshToken(c *gin.Context) {
+	var rtr dto.RefreshTokenRequest
+
+	// fill the refresh token request from binding the JSON request
+	if err := c.ShouldBindJSON(&rtr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the refresh token request for invalid fields
+	if errs := rtr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid refresh token request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// exchange the refresh token for a new token pair
+	tokenClient, at, rt, err := ah.tokenService.RefreshTokenPair(c, rtr.RefreshToken)
+	if err != nil {
+		log.Printf("Failed to refresh token pair. Error: %v\n", err.Error())
+		if tokenClient != nil {
+			ah.loginHistoryService.Record(c, dao.NewLoginHistoryEntry(tokenClient.Id, dao.LoginMethodRefreshToken, false).Failed(loginFailureReason(err)))
+		}
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	ah.loginHistoryService.Record(c, dao.NewLoginHistoryEntry(tokenClient.Id, dao.LoginMethodRefreshToken, true))
+
+	// the token carries the client as it was when the token was issued, so return their current details
+	client, err := ah.clientService.GetClientByID(c, tokenClient.Id)
+	if err != nil {
+		log.Printf("Failed to get client from database. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	loginResp := dto.NewLoginResponse(*client, at, rt)
+	resp := utils.ResponseStatusOK("token refreshed successfully", loginResp)
+
+	c.JSON(resp.Status, resp)
+}
+
+// RevokeSessions handles the request from a login alert's "this wasn't me" link to sign out of all sessions
+func (ah *AuthHandler) RevokeSessions(c *gin.Context) {
+	var rsr dto.RevokeSessionsRequest
+
+	// fill the revoke sessions request from binding the JSON request
+	if err := c.ShouldBindJSON(&rsr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the revoke sessions request for invalid fields
+	if errs := rsr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid revoke sessions request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	if err := ah.loginAlertService.RevokeSessions(c, rsr.Token); err != nil {
+		log.Printf("Failed to revoke sessions. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("signed out of all sessions, please change your password", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// ConfirmEmailChange handles the request from the confirm link sent to the new address of an email change
+func (ah *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	etr, ok := bindEmailChangeTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := ah.emailChangeService.Confirm(c, etr.Token); err != nil {
+		log.Printf("Failed to confirm email change. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("email changed successfully, sign in with the new email", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// CancelEmailChange handles the request from the cancel link sent to the old address of an email change
+func (ah *AuthHandler) CancelEmailChange(c *gin.Context) {
+	etr, ok := bindEmailChangeTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := ah.emailChangeService.Cancel(c, etr.Token); err != nil {
+		log.Printf("Failed to cancel email change. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("email change cancelled and signed out of all sessions, please change your password", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// bindEmailChangeTokenRequest reads and validates the token of an email change link, responding with the error if it is invalid
+func bindEmailChangeTokenRequest(c *gin.Context) (*dto.EmailChangeTokenRequest, bool) {
+	var etr dto.EmailChangeTokenRequest
+
+	// fill the email change token request from binding the JSON request
+	if err := c.ShouldBindJSON(&etr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return nil, false
+	}
+
+	// validate the email change token request for invalid fields
+	if errs := etr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid email change request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return nil, false
+	}
+
+	return &etr, true
+}
+
+// loginFailureReason returns the reason recorded in the login history for a failed sign-in
+func loginFailureReason(err error) string {
+	switch errors.Status(err) {
+	case http.StatusUnauthorized:
+		return "invalid_credentials"
+	case http.StatusLocked:
+		return "account_locked"
+	case http.StatusTooManyRequests:
+		return "too_many_attempts"
+	default:
+		return "error"
+	}
+}
+
+// AcceptInvitation handles the request to sign up with the email an invitation was sent to and accept it
+func (ah *AuthHandler) AcceptInvitation(c *gin.Context) {
+	var air dto.AcceptInvitationRequest
+
+	// fill the accept invitation request from binding the JSON request
+	if err := c.ShouldBindJSON(&air); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the accept invitation request for invalid fields
+	if errs := air.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid signup request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	client, organizationId, err := ah.invitationService.AcceptWithSignup(c, &air)
+	if err != nil {
+		log.Printf("Failed to accept invitation. Error: %v\n", err.Error())
+		SetRetryAfter(c, err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	// create the access and refresh token pairs for the organization the client joined
+	at, rt, err := ah.tokenService.SwitchOrganization(c, client, organizationId)
+	if err != nil {
+		log.Printf("Failed to generate client token pair. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	loginResp := dto.NewLoginResponse(*client, at, rt)
+	resp := utils.ResponseStatusCreated("signed up and joined the organization successfully", loginResp)
+
+	c.JSON(resp.Status, resp)
+}
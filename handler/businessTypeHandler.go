@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// BusinessTypeHandler handles requests for the business types clients choose from
+type BusinessTypeHandler struct {
+	businessTypeService interfaces.BusinessTypeServiceInterface
+}
+
+// InitBusinessTypeHandler initializes and sets up the business type handler
+func InitBusinessTypeHandler(router *gin.Engine, version, adminApiKey string, businessTypeService interfaces.BusinessTypeServiceInterface) {
+	h := &BusinessTypeHandler{
+		businessTypeService: businessTypeService,
+	}
+
+	// the business types that can be chosen are public so signup forms can list them
+	router.GET(fmt.Sprintf("%s%s", version, "/business-types"), h.ListBusinessTypes)
+
+	// group the admin routes according to paths
+	path := fmt.Sprintf("%s%s", version, "/admin/business-types")
+	g := router.Group(path, middlewares.AuthorizeAdmin(adminApiKey))
+
+	// register endpoints
+	g.GET("", h.ListAllBusinessTypes)
+	g.POST("", h.CreateBusinessType)
+	g.PATCH("/:id", h.UpdateBusinessType)
+	g.DELETE("/:id", h.DeleteBusinessType)
+}
+
+// businessTypeID reads the business type id from the request path
+func businessTypeID(c *gin.Context) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return primitive.NilObjectID, errors.ErrBadRequest("invalid business type id", nil)
+	}
+	return id, nil
+}
+
+// ListBusinessTypes handles the request to list the business types clients can choose, grouped by sector
+func (h *BusinessTypeHandler) ListBusinessTypes(c *gin.Context) {
+	businessTypes, err := h.businessTypeService.List(c)
+	if err != nil {
+		log.Printf("Failed to list business types. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("business types retrieved successfully", businessTypes)
+	c.JSON(resp.Status, resp)
+}
+
+// ListAllBusinessTypes handles the request to list every business type, including the inactive ones
+func (h *BusinessTypeHandler) ListAllBusinessTypes(c *gin.Context) {
+	businessTypes, err := h.businessTypeService.ListAll(c)
+	if err != nil {
+		log.Printf("Failed to list business types. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("business types retrieved successfully", businessTypes)
+	c.JSON(resp.Status, resp)
+}
+
+// CreateBusinessType handles the request to create a business type
+func (h *BusinessTypeHandler) CreateBusinessType(c *gin.Context) {
+	var br dto.BusinessTypeRequest
+
+	// fill the business type request from binding the JSON request
+	if err := c.ShouldBindJSON(&br); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the business type request for invalid fields
+	if errs := br.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid business type request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	businessType, err := h.businessTypeService.Create(c, &br)
+	if err != nil {
+		log.Printf("Failed to create business type. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("business type created successfully", businessType)
+	c.JSON(resp.Status, resp)
+}
+
+// UpdateBusinessType handles the request to rename, move, activate or deactivate a business type
+func (h *BusinessTypeHandler) UpdateBusinessType(c *gin.Context) {
+	id, err := businessTypeID(c)
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	var bu dto.BusinessTypeUpdate
+
+	// fill the business type update from binding the JSON request
+	if err = c.ShouldBindJSON(&bu); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the business type update for invalid fields
+	if errs := bu.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid business type update", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	businessType, err := h.businessTypeService.Update(c, id, &bu)
+	if err != nil {
+		log.Printf("Failed to update business type. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("business type updated successfully", businessType)
+	c.JSON(resp.Status, resp)
+}
+
+// DeleteBusinessType handles the request to delete a business type
+func (h *BusinessTypeHandler) DeleteBusinessType(c *gin.Context) {
+	id, err := businessTypeID(c)
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	if err = h.businessTypeService.Delete(c, id); err != nil {
+		log.Printf("Failed to delete business type. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("business type deleted successfully", nil)
+	c.JSON(resp.Status, resp)
+}
@@ -5,33 +5,47 @@ import (
 	"log"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
 	"github.com/leonardchinonso/auth_service_cmp7174/errors"
 	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
-	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
 	"github.com/leonardchinonso/auth_service_cmp7174/utils"
 )
 
+// mergePatchContentType is the media type of an RFC 7396 JSON merge patch
+const mergePatchContentType = "application/merge-patch+json"
+
 // ClientHandler represents the router handler object for the client requests
 type ClientHandler struct {
 	clientService  interfaces.ClientServiceInterface
 	tokenService interfaces.TokenServiceInterface
+	loginHistoryService interfaces.LoginHistoryServiceInterface
+	phoneVerificationService interfaces.PhoneVerificationServiceInterface
 }
 
 // InitClientHandler initializes the client handler
-func InitClientHandler(router *gin.Engine, version string, clientService interfaces.ClientServiceInterface, tokenService interfaces.TokenServiceInterface) {
+func InitClientHandler(router *gin.Engine, version string, rateLimiter *middlewares.RateLimiter, clientService interfaces.ClientServiceInterface, tokenService interfaces.TokenServiceInterface, loginHistoryService interfaces.LoginHistoryServiceInterface, phoneVerificationService interfaces.PhoneVerificationServiceInterface) {
 	h := &ClientHandler{
 		clientService:  clientService,
 		tokenService: tokenService,
+		loginHistoryService: loginHistoryService,
+		phoneVerificationService: phoneVerificationService,
 	}
 
 	// group routes according to paths
 	path := fmt.Sprintf("%s%s", version, "/client")
 	g := router.Group(path)
 
+	g.GET("/me", middlewares.AuthorizeClient(h.tokenService), h.GetProfile)
+	g.PATCH("/me", middlewares.AuthorizeClient(h.tokenService), h.PatchProfile)
 	g.PUT("/update-profile", middlewares.AuthorizeClient(h.tokenService), h.UpdateProfile)
+	g.PUT("/change-password", middlewares.AuthorizeClient(h.tokenService), rateLimiter.For(config.RateLimitChangePassword), h.ChangePassword)
+	g.GET("/login-history", middlewares.AuthorizeClient(h.tokenService), h.LoginHistory)
+	g.POST("/phone/send-code", middlewares.AuthorizeClient(h.tokenService), rateLimiter.For(config.RateLimitPhoneSendCode), h.SendPhoneCode)
+	g.POST("/phone/verify", middlewares.AuthorizeClient(h.tokenService), h.VerifyPhone)
 }
 
 // UpdateProfile handles the request to update client details
@@ -62,19 +76,241 @@ func (h *ClientHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// create a new client and set their details
-	client := dao.NewClient(epr.Name, string(epr.Email), epr.Address, "", string(epr.BusinessType), "")
-	client.Id = cl.Id
-	client.PhoneNumber = epr.PhoneNumber
+	h.editProfile(c, cl.Id, epr.Patch())
+}
+
+// GetProfile handles the request to read the logged-in client's profile
+func (h *ClientHandler) GetProfile(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	cl, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	// the client in the token may be out of date, so the profile is read from the database
+	client, err := h.clientService.GetClientByID(c, cl.Id)
+	if err != nil {
+		log.Printf("Failed to get client from database. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	SetETag(c, client.Version)
+	resp := utils.ResponseStatusOK("profile retrieved successfully", dto.NewClientProfile(*client))
+	c.JSON(resp.Status, resp)
+}
+
+// PatchProfile handles the request to change some of the client's details with a JSON merge patch
+func (h *ClientHandler) PatchProfile(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	cl, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	if contentType := c.ContentType(); contentType != mergePatchContentType && contentType != gin.MIMEJSON {
+		resErr := errors.ErrUnsupportedMediaType(fmt.Sprintf("the patch must be sent as %s", mergePatchContentType), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		log.Printf("Failed to read request body. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// read the patch and validate only the fields it changes
+	patch, errs := dto.ParseClientPatch(body)
+	if len(errs) == 0 {
+		errs = patch.Validate()
+	}
+	if len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid patch", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	h.editProfile(c, cl.Id, patch)
+}
+
+// editProfile applies the patch to the version of the profile named by the If-Match header and responds with the result
+func (h *ClientHandler) editProfile(c *gin.Context, clientId primitive.ObjectID, patch *dto.ClientPatch) {
+	// the update only applies to the version of the profile it was made against
+	version, err := VersionFromIfMatch(c)
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	client, err := h.clientService.EditClientProfile(c, clientId, version, patch)
+	if err != nil {
+		log.Printf("Failed to edit client profile. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	message := "profile edited successfully"
+	if patch.Email != nil && string(*patch.Email) != client.Email {
+		message = "profile edited successfully, confirm the new email with the link sent to it"
+	}
+
+	SetETag(c, client.Version)
+	resp := utils.ResponseStatusOK(message, dto.NewClientProfile(*client))
+	c.JSON(resp.Status, resp)
+}
+
+// ChangePassword handles the request to change a client's password
+func (h *ClientHandler) ChangePassword(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	cl, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	var cpr dto.ChangePasswordRequest
+	// fill the change password request from binding the JSON request
+	if err := c.ShouldBindJSON(&cpr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the change password request for invalid fields
+	if errs := cpr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// the password policy failures are returned in the error data
+	err := h.clientService.ChangePassword(c, cl.Id, cpr.CurrentPassword, cpr.NewPassword)
+	if err != nil {
+		log.Printf("Failed to change client password. Error: %v\n", err.Error())
+		SetRetryAfter(c, err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("password changed successfully", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// SendPhoneCode handles the request to text a code to the client's phone number to verify it
+func (h *ClientHandler) SendPhoneCode(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	cl, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	// the phone number in the token may be out of date, so the client is read from the database
+	client, err := h.clientService.GetClientByID(c, cl.Id)
+	if err != nil {
+		log.Printf("Failed to get client from database. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	if err = h.phoneVerificationService.SendCode(c, client); err != nil {
+		log.Printf("Failed to send phone code. Error: %v\n", err.Error())
+		SetRetryAfter(c, err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("a code was sent to your phone number", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// VerifyPhone handles the request to verify the client's phone number with the code texted to it
+func (h *ClientHandler) VerifyPhone(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	cl, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	var pcr dto.PhoneCodeRequest
+	// fill the phone code request from binding the JSON request
+	if err := c.ShouldBindJSON(&pcr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the phone code request for invalid fields
+	if errs := pcr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	client, err := h.phoneVerificationService.VerifyPhone(c, cl.Id, pcr.Code)
+	if err != nil {
+		log.Printf("Failed to verify phone number. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	SetETag(c, client.Version)
+	resp := utils.ResponseStatusOK("phone number verified successfully", dto.NewClientProfile(*client))
+	c.JSON(resp.Status, resp)
+}
+
+// LoginHistory handles the request to list the client's recent sign-ins, newest first
+func (h *ClientHandler) LoginHistory(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	cl, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	var pagination dto.Pagination
+	// fill the pagination from binding the query string
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the pagination for invalid fields
+	if errs := pagination.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid pagination", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
 
-	// start the signup process
-	err := h.clientService.EditClientProfile(c, client)
+	page, err := h.loginHistoryService.ListForClient(c, cl.Id, pagination)
 	if err != nil {
-		log.Printf("Failed to sign client up. Error: %v\n", err.Error())
+		log.Printf("Failed to list login history. Error: %v\n", err.Error())
 		c.JSON(errors.Status(err), err)
 		return
 	}
 
-	resp := utils.ResponseStatusOK("profile edited successfully", client)
+	resp := utils.ResponseStatusOK("login history retrieved successfully", page)
 	c.JSON(resp.Status, resp)
 }
@@ -0,0 +1,458 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// OrganizationHandler handles requests for the organizations clients are members of
+type OrganizationHandler struct {
+	organizationService interfaces.OrganizationServiceInterface
+	invitationService   interfaces.InvitationServiceInterface
+	tokenService        interfaces.TokenServiceInterface
+}
+
+// InitOrganizationHandler initializes and sets up the organization handler
+func InitOrganizationHandler(router *gin.Engine, version string, organizationService interfaces.OrganizationServiceInterface, invitationService interfaces.InvitationServiceInterface, tokenService interfaces.TokenServiceInterface) {
+	h := &OrganizationHandler{
+		organizationService: organizationService,
+		invitationService:   invitationService,
+		tokenService:        tokenService,
+	}
+
+	// the organizations the client is a member of
+	g := router.Group(fmt.Sprintf("%s%s", version, "/organizations"), middlewares.AuthorizeClient(h.tokenService))
+	g.GET("", h.ListOrganizations)
+	g.POST("", h.CreateOrganization)
+	g.POST("/:id/switch", middlewares.ReturnsTokens(), h.SwitchOrganization)
+
+	// the organization the access token is for
+	a := router.Group(fmt.Sprintf("%s%s", version, "/organization"), middlewares.AuthorizeClient(h.tokenService))
+	a.GET("", h.GetOrganization)
+	a.PATCH("", h.UpdateOrganization)
+	a.GET("/members", h.ListMembers)
+	a.PATCH("/members/:id", h.ChangeMemberRole)
+	a.DELETE("/members/:id", h.RemoveMember)
+
+	// invitations are only exposed when there is a key to sign their links with
+	if h.invitationService.Enabled() {
+		g.POST("/invitations/accept", middlewares.ReturnsTokens(), h.AcceptInvitation)
+		a.GET("/invitations", h.ListInvitations)
+		a.POST("/invitations", h.Invite)
+		a.POST("/invitations/:id/resend", h.ResendInvitation)
+		a.DELETE("/invitations/:id", h.RevokeInvitation)
+	}
+}
+
+// activeOrganization gets the logged-in client and the organization their access token is for
+// it writes the error response and returns false if either is missing
+func activeOrganization(c *gin.Context) (*dao.Client, primitive.ObjectID, bool) {
+	client, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return nil, primitive.NilObjectID, false
+	}
+
+	organizationId, ok := OrganizationFromRequest(c)
+	if !ok {
+		resErr := errors.ErrBadRequest("you are not acting for an organization, switch to one first", nil)
+		c.JSON(resErr.Status, resErr)
+		return nil, primitive.NilObjectID, false
+	}
+
+	return client, organizationId, true
+}
+
+// pathObjectID reads an object id from the request path
+func pathObjectID(c *gin.Context, name string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(c.Param(name))
+	if err != nil {
+		return primitive.NilObjectID, errors.ErrBadRequest(fmt.Sprintf("invalid %s", name), nil)
+	}
+	return id, nil
+}
+
+// ListOrganizations handles the request to list the organizations the client is a member of
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	client, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	organizations, err := h.organizationService.List(c, client.Id)
+	if err != nil {
+		log.Printf("Failed to list organizations. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("organizations retrieved successfully", organizations)
+	c.JSON(resp.Status, resp)
+}
+
+// CreateOrganization handles the request to create an organization with the client as its owner
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	client, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	var or dto.OrganizationRequest
+
+	// fill the organization request from binding the JSON request
+	if err := c.ShouldBindJSON(&or); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the organization request for invalid fields
+	if errs := or.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid organization request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	organization := dao.NewOrganization(or.Name, or.Address.Normalized(), string(or.BusinessType), or.ApiKey)
+	if err := h.organizationService.Create(c, client, organization); err != nil {
+		log.Printf("Failed to create organization. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusCreated("organization created successfully", dto.NewOrganizationProfile(*organization, dao.RoleOwner))
+	c.JSON(resp.Status, resp)
+}
+
+// SwitchOrganization handles the request for a token pair that is for another organization the client is a member of
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	client, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	organizationId, err := pathObjectID(c, "id")
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	at, rt, err := h.tokenService.SwitchOrganization(c, client, organizationId)
+	if err != nil {
+		log.Printf("Failed to switch organization. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	loginResp := dto.NewLoginResponse(*client, at, rt)
+	resp := utils.ResponseStatusOK("switched organization successfully", loginResp)
+	c.JSON(resp.Status, resp)
+}
+
+// GetOrganization handles the request to get the organization the client is acting for
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	organization, err := h.organizationService.Get(c, client.Id, organizationId)
+	if err != nil {
+		log.Printf("Failed to get organization. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("organization retrieved successfully", organization)
+	c.JSON(resp.Status, resp)
+}
+
+// UpdateOrganization handles the request to edit the business profile of the organization the client is acting for
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	var ou dto.OrganizationUpdate
+
+	// fill the organization update from binding the JSON request
+	if err := c.ShouldBindJSON(&ou); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the organization update for invalid fields
+	if errs := ou.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid organization update", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	organization, err := h.organizationService.Update(c, client, organizationId, &ou)
+	if err != nil {
+		log.Printf("Failed to update organization. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("organization updated successfully", organization)
+	c.JSON(resp.Status, resp)
+}
+
+// ListMembers handles the request to list the members of the organization the client is acting for
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationService.Members(c, client.Id, organizationId)
+	if err != nil {
+		log.Printf("Failed to list members. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("members retrieved successfully", members)
+	c.JSON(resp.Status, resp)
+}
+
+// ChangeMemberRole handles the request to give a member of the organization the client is acting for a new role
+func (h *OrganizationHandler) ChangeMemberRole(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	memberId, err := pathObjectID(c, "id")
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	var rr dto.RoleRequest
+
+	// fill the role request from binding the JSON request
+	if err = c.ShouldBindJSON(&rr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the role request for invalid fields
+	if errs := rr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid role request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	if err = h.organizationService.ChangeRole(c, client, organizationId, memberId, rr.Role); err != nil {
+		log.Printf("Failed to change member role. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("role changed successfully", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// RemoveMember handles the request to remove a member from the organization the client is acting for
+// members remove themselves to leave the organization
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	memberId, err := pathObjectID(c, "id")
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	if err = h.organizationService.RemoveMember(c, client, organizationId, memberId); err != nil {
+		log.Printf("Failed to remove member. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("member removed successfully", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// Invite handles the request to invite an email to the organization the client is acting for
+func (h *OrganizationHandler) Invite(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	var ir dto.InvitationRequest
+
+	// fill the invitation request from binding the JSON request
+	if err := c.ShouldBindJSON(&ir); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the invitation request for invalid fields
+	if errs := ir.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid invitation request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	invitation, err := h.invitationService.Invite(c, client, organizationId, &ir)
+	if err != nil {
+		log.Printf("Failed to send invitation. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusCreated("invitation sent successfully", invitation)
+	c.JSON(resp.Status, resp)
+}
+
+// ListInvitations handles the request to list the pending invitations of the organization the client is acting for
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitationService.List(c, client.Id, organizationId)
+	if err != nil {
+		log.Printf("Failed to list invitations. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("invitations retrieved successfully", invitations)
+	c.JSON(resp.Status, resp)
+}
+
+// ResendInvitation handles the request to send a pending invitation again with a new link
+func (h *OrganizationHandler) ResendInvitation(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	invitationId, err := pathObjectID(c, "id")
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	invitation, err := h.invitationService.Resend(c, client, organizationId, invitationId)
+	if err != nil {
+		log.Printf("Failed to resend invitation. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("invitation resent successfully", invitation)
+	c.JSON(resp.Status, resp)
+}
+
+// RevokeInvitation handles the request to withdraw a pending invitation
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	client, organizationId, ok := activeOrganization(c)
+	if !ok {
+		return
+	}
+
+	invitationId, err := pathObjectID(c, "id")
+	if err != nil {
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	if err = h.invitationService.Revoke(c, client, organizationId, invitationId); err != nil {
+		log.Printf("Failed to revoke invitation. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("invitation revoked successfully", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// AcceptInvitation handles the request to accept an invitation with the logged-in client's account
+// the client gets a token pair for the organization they joined
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	// retrieve the logged-in client from the authenticated request
+	client, ok := ClientFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve client from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, gin.H{"errors": resErr})
+		return
+	}
+
+	var itr dto.InvitationTokenRequest
+
+	// fill the invitation token request from binding the JSON request
+	if err := c.ShouldBindJSON(&itr); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the invitation token request for invalid fields
+	if errs := itr.Validate(); len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid invitation request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	organizationId, err := h.invitationService.Accept(c, client, itr.Token)
+	if err != nil {
+		log.Printf("Failed to accept invitation. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	at, rt, err := h.tokenService.SwitchOrganization(c, client, organizationId)
+	if err != nil {
+		log.Printf("Failed to switch organization. Error: %v\n", err.Error())
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	loginResp := dto.NewLoginResponse(*client, at, rt)
+	resp := utils.ResponseStatusOK("joined the organization successfully", loginResp)
+	c.JSON(resp.Status, resp)
+}
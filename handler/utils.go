@@ -1,8 +1,16 @@
 package handler
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
 )
 
 // ClientFromRequest gets a client set by the authentication middleware
@@ -16,3 +24,52 @@ func ClientFromRequest(c *gin.Context) (*dao.Client, bool) {
 	client := u.(*dao.Client)
 	return client, true
 }
+
+// OrganizationFromRequest gets the id of the organization the access token is for, set by the authentication middleware
+func OrganizationFromRequest(c *gin.Context) (primitive.ObjectID, bool) {
+	id, ok := c.Get("organization_id")
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	return id.(primitive.ObjectID), true
+}
+
+// SetRetryAfter sets the Retry-After header if the error carries a retry hint
+func SetRetryAfter(c *gin.Context, err error) {
+	if retryAfter := errors.RetryAfter(err); retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+}
+
+// SetETag sets the ETag header to the version of the resource in the response
+func SetETag(c *gin.Context, version int64) {
+	c.Header("ETag", fmt.Sprintf("%q", strconv.FormatInt(version, 10)))
+}
+
+// VersionFromIfMatch reads the version a request was made against from its If-Match header
+// it returns dto.AnyVersion if the header is `*`, which matches whatever the current version is
+func VersionFromIfMatch(c *gin.Context) (int64, error) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		return 0, errors.ErrPreconditionRequired("the If-Match header is required, set it to the ETag of the resource", nil)
+	}
+
+	if ifMatch == "*" {
+		return dto.AnyVersion, nil
+	}
+
+	// weak tags are compared like strong ones since the version covers the whole resource
+	tag := strings.TrimPrefix(ifMatch, "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, errors.ErrBadRequest("the If-Match header is not a valid ETag", nil)
+	}
+
+	// a tag that is not one of our versions can never match
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 0 {
+		return 0, errors.ErrPreconditionFailed("the profile was changed since it was read, fetch it again and retry", nil)
+	}
+
+	return version, nil
+}
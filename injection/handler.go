@@ -5,14 +5,18 @@ import (
 
 	"github.com/leonardchinonso/auth_service_cmp7174/config"
 	"github.com/leonardchinonso/auth_service_cmp7174/handler"
+	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
 )
 
 // injectHandlers initializes the dependencies and creates them as a config
-func injectHandlers(router *gin.Engine, cfg *map[string]string, handlerCfg *HandlerConfig) {
+func injectHandlers(router *gin.Engine, cfg *map[string]string, rateLimiter *middlewares.RateLimiter, handlerCfg *HandlerConfig) {
 	// get the current version number for correct routing
 	version := (*cfg)[config.Version]
 
 	// initialize the handlers
-	handler.InitAuthHandler(router, version, handlerCfg.ClientService, handlerCfg.TokenService)
-	handler.InitClientHandler(router, version, handlerCfg.ClientService, handlerCfg.TokenService)
+	handler.InitAuthHandler(router, version, rateLimiter, handlerCfg.ClientService, handlerCfg.TokenService, handlerCfg.MagicLinkService, handlerCfg.LoginHistoryService, handlerCfg.LoginAlertService, handlerCfg.EmailChangeService, handlerCfg.OrganizationService, handlerCfg.InvitationService)
+	handler.InitClientHandler(router, version, rateLimiter, handlerCfg.ClientService, handlerCfg.TokenService, handlerCfg.LoginHistoryService, handlerCfg.PhoneVerificationService)
+	handler.InitAdminHandler(router, version, (*cfg)[config.AdminApiKey], handlerCfg.LoginGuardService, handlerCfg.AuditService)
+	handler.InitOrganizationHandler(router, version, handlerCfg.OrganizationService, handlerCfg.InvitationService, handlerCfg.TokenService)
+	handler.InitBusinessTypeHandler(router, version, (*cfg)[config.AdminApiKey], handlerCfg.BusinessTypeService)
 }
@@ -6,30 +6,74 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
 	"github.com/leonardchinonso/auth_service_cmp7174/datasource"
+	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
 )
 
+// App holds the parts of the application that main runs
+type App struct {
+	Router      *gin.Engine
+	JobWorkers  interfaces.JobWorkerPoolInterface
+	Maintenance interfaces.MaintenanceSchedulerInterface
+}
+
 // Inject injects all the repos and services necessary
-func Inject(ds *datasource.DataSource) (*gin.Engine, error) {
+func Inject(ds *datasource.DataSource) (*App, error) {
 	log.Printf("Injecting Data Sources...\n")
 
 	// load repositories
 	servCfg := injectRepositories(ds.Database)
 
 	// load services
-	handCfg, err := injectServices(ds.Cfg, servCfg)
+	handCfg, err := injectServices(ds.Cfg, ds.BreachedPasswords, ds.SMSSender, servCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inject services: %v", err)
 	}
 
+	// load the background job workers
+	jobWorkers, err := injectJobWorkers(ds.Cfg, ds.MailTransport, servCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inject job workers: %v", err)
+	}
+
+	// load the maintenance sweeps
+	maintenance, err := injectMaintenanceScheduler(ds.Cfg, servCfg, handCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inject maintenance scheduler: %v", err)
+	}
+
+	// load the rate limiter
+	rateLimiter, err := injectRateLimiter(ds.Cfg, ds.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inject rate limiter: %v", err)
+	}
+
+	// load the idempotency key middleware
+	idempotency, err := injectIdempotency(ds.Cfg, servCfg.IdempotencyKeyRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inject idempotency keys: %v", err)
+	}
+
 	// load router
 	router := gin.Default()
 
+	// record where each request came from for the services
+	router.Use(middlewares.RequestMeta())
+
+	// find the tenant each request belongs to, which scopes the clients and tokens it can reach
+	router.Use(middlewares.ResolveTenant(handCfg.TenantRegistry, handCfg.TokenService, (*ds.Cfg)[config.TenantHeader]))
+
+	// replay the responses to retried POST requests that carry an idempotency key
+	router.Use(idempotency)
+
 	// load handlers
-	injectHandlers(router, ds.Cfg, handCfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to inject handlers: %v", err)
-	}
+	injectHandlers(router, ds.Cfg, rateLimiter, handCfg)
 
-	return router, nil
-}
\ No newline at end of file
+	return &App{
+		Router:      router,
+		JobWorkers:  jobWorkers,
+		Maintenance: maintenance,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package injection
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/middlewares"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/repository"
+)
+
+// injectIdempotency creates the middleware that replays the responses to retried requests with an Idempotency-Key header
+func injectIdempotency(cfg *map[string]string, repo interfaces.IdempotencyKeyRepositoryInterface) (gin.HandlerFunc, error) {
+	expiresIn, err := strconv.Atoi((*cfg)[config.IdempotencyKeyExpiresIn])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", config.IdempotencyKeyExpiresIn, err)
+	}
+
+	return middlewares.Idempotency(repo, time.Duration(expiresIn)*time.Second), nil
+}
+
+// injectRateLimiter creates the rate limiter with the store and the per-route rules from the config
+func injectRateLimiter(cfg *map[string]string, db *mongo.Database) (*middlewares.RateLimiter, error) {
+	var store interfaces.RateLimitStoreInterface
+	switch (*cfg)[config.RateLimitStore] {
+	case "memory":
+		store = repository.NewMemoryRateLimitStore()
+	case "mongo":
+		store = repository.NewMongoRateLimitStore(db)
+	default:
+		return nil, fmt.Errorf("invalid rate limit store: %s", (*cfg)[config.RateLimitStore])
+	}
+
+	rules := make(map[string]*dto.RateLimitRule)
+	for _, route := range config.RateLimitRoutes {
+		rule, err := dto.ParseRateLimitRule(route, (*cfg)[route])
+		if err != nil {
+			return nil, err
+		}
+		rules[route] = rule
+	}
+
+	return middlewares.NewRateLimiter(store, rules), nil
+}
@@ -11,6 +11,20 @@ import (
 type ServicesConfig struct {
 	ClientRepo             interfaces.ClientRepositoryInterface
 	TokenRepo            interfaces.TokenRepositoryInterface
+	MagicLinkRepo interfaces.MagicLinkRepositoryInterface
+	LoginAttemptRepo interfaces.LoginAttemptRepositoryInterface
+	AuditRepo interfaces.AuditRepositoryInterface
+	AuditCheckpointRepo interfaces.AuditCheckpointRepositoryInterface
+	LoginHistoryRepo interfaces.LoginHistoryRepositoryInterface
+	JobRepo interfaces.JobRepositoryInterface
+	IdempotencyKeyRepo interfaces.IdempotencyKeyRepositoryInterface
+	EmailChangeRepo interfaces.EmailChangeRepositoryInterface
+	PhoneCodeRepo interfaces.PhoneCodeRepositoryInterface
+	BusinessTypeRepo interfaces.BusinessTypeRepositoryInterface
+	OrganizationRepo interfaces.OrganizationRepositoryInterface
+	MembershipRepo interfaces.MembershipRepositoryInterface
+	InvitationRepo interfaces.InvitationRepositoryInterface
+	RevokeLinkRepo interfaces.RevokeLinkRepositoryInterface
 }
 
 // injectRepositories initializes the dependencies and creates them as a config for services injection
@@ -18,5 +32,19 @@ func injectRepositories(db *mongo.Database) *ServicesConfig {
 	return &ServicesConfig{
 		ClientRepo:             repository.NewClientRepository(db),
 		TokenRepo:            repository.NewTokenRepository(db),
+		MagicLinkRepo: repository.NewMagicLinkRepository(db),
+		LoginAttemptRepo: repository.NewLoginAttemptRepository(db),
+		AuditRepo: repository.NewAuditRepository(db),
+		AuditCheckpointRepo: repository.NewAuditCheckpointRepository(db),
+		LoginHistoryRepo: repository.NewLoginHistoryRepository(db),
+		JobRepo: repository.NewJobRepository(db),
+		IdempotencyKeyRepo: repository.NewIdempotencyKeyRepository(db),
+		EmailChangeRepo: repository.NewEmailChangeRepository(db),
+		PhoneCodeRepo: repository.NewPhoneCodeRepository(db),
+		BusinessTypeRepo: repository.NewBusinessTypeRepository(db),
+		OrganizationRepo: repository.NewOrganizationRepository(db),
+		MembershipRepo: repository.NewMembershipRepository(db),
+		InvitationRepo: repository.NewInvitationRepository(db),
+		RevokeLinkRepo: repository.NewRevokeLinkRepository(db),
 	}
 }
@@ -9,15 +9,110 @@ import (
 type HandlerConfig struct {
 	ClientService             interfaces.ClientServiceInterface
 	TokenService            interfaces.TokenServiceInterface
+	MagicLinkService interfaces.MagicLinkServiceInterface
+	LoginGuardService interfaces.LoginGuardServiceInterface
+	AuditService interfaces.AuditServiceInterface
+	LoginHistoryService interfaces.LoginHistoryServiceInterface
+	LoginAlertService interfaces.LoginAlertServiceInterface
+	EmailChangeService interfaces.EmailChangeServiceInterface
+	PhoneVerificationService interfaces.PhoneVerificationServiceInterface
+	BusinessTypeService interfaces.BusinessTypeServiceInterface
+	OrganizationService interfaces.OrganizationServiceInterface
+	InvitationService interfaces.InvitationServiceInterface
+	TenantRegistry interfaces.TenantRegistryInterface
 }
 
 // injectServices initializes the dependencies and creates them as a config for handler injection
-func injectServices(cfg *map[string]string, servCfg *ServicesConfig) (*HandlerConfig, error) {
+func injectServices(cfg *map[string]string, breachedPasswords interfaces.BreachedPasswordCheckerInterface, smsSender interfaces.SMSSenderInterface, servCfg *ServicesConfig) (*HandlerConfig, error) {
+	// load the tenants that share the deployment
+	tenantRegistry, err := service.NewTenantRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the job queue that background work is handed to
+	jobQueue, err := service.NewJobQueue(cfg, servCfg.JobRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the mailer that renders the mail templates and queues them for delivery
+	mailer, err := service.NewMailer(cfg, tenantRegistry, jobQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the audit service that the other services record security events with
+	auditService, err := service.NewAuditService(cfg, servCfg.AuditRepo, servCfg.AuditCheckpointRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the login guard service with the needed config
+	loginGuardService, err := service.NewLoginGuardService(cfg, servCfg.ClientRepo, servCfg.LoginAttemptRepo, auditService, mailer)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the password hasher with the needed config
+	passwordHasher, err := service.NewPasswordHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// run the password hashing on a bounded number of slots
+	passwordHasher, err = service.NewBoundedPasswordHasher(cfg, passwordHasher)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the password service with the needed config
+	passwordService, err := service.NewPasswordService(cfg, tenantRegistry, passwordHasher, breachedPasswords)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the email change service with the needed config
+	emailChangeService, err := service.NewEmailChangeService(cfg, servCfg.ClientRepo, servCfg.TokenRepo, servCfg.EmailChangeRepo, auditService, mailer)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the business type service that the business types organizations choose are checked against
+	businessTypeService, err := service.NewBusinessTypeService(cfg, servCfg.BusinessTypeRepo, servCfg.OrganizationRepo, auditService)
+	if err != nil {
+		return nil, err
+	}
+
 	// initialize the client service with the needed config
-	clientService := service.NewClientService(servCfg.ClientRepo, servCfg.TokenRepo)
+	clientService := service.NewClientService(servCfg.ClientRepo, servCfg.TokenRepo, loginGuardService, passwordService, passwordHasher, auditService, emailChangeService)
 
 	// initialize the token service with the needed config
-	tokenService, err := service.NewTokenService(cfg, servCfg.TokenRepo)
+	tokenService, err := service.NewTokenService(cfg, servCfg.ClientRepo, servCfg.TokenRepo, servCfg.MembershipRepo, auditService)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the magic link service with the needed config
+	magicLinkService, err := service.NewMagicLinkService(cfg, servCfg.ClientRepo, servCfg.MagicLinkRepo, auditService, mailer)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the login alert service with the needed config
+	loginAlertService, err := service.NewLoginAlertService(cfg, tenantRegistry, servCfg.ClientRepo, servCfg.TokenRepo, servCfg.LoginHistoryRepo, servCfg.RevokeLinkRepo, auditService, mailer)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the phone verification service that texts one-time codes to clients
+	phoneVerificationService, err := service.NewPhoneVerificationService(cfg, servCfg.ClientRepo, servCfg.PhoneCodeRepo, auditService, smsSender)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the invitation service that organizations invite members with
+	invitationService, err := service.NewInvitationService(cfg, servCfg.ClientRepo, clientService, servCfg.OrganizationRepo, servCfg.MembershipRepo, servCfg.InvitationRepo, auditService, mailer)
 	if err != nil {
 		return nil, err
 	}
@@ -25,5 +120,16 @@ func injectServices(cfg *map[string]string, servCfg *ServicesConfig) (*HandlerCo
 	return &HandlerConfig{
 		ClientService:             clientService,
 		TokenService:            tokenService,
+		MagicLinkService: magicLinkService,
+		LoginGuardService: loginGuardService,
+		AuditService: auditService,
+		LoginHistoryService: service.NewLoginHistoryService(servCfg.LoginHistoryRepo),
+		LoginAlertService: loginAlertService,
+		EmailChangeService: emailChangeService,
+		PhoneVerificationService: phoneVerificationService,
+		BusinessTypeService: businessTypeService,
+		OrganizationService: service.NewOrganizationService(servCfg.OrganizationRepo, servCfg.MembershipRepo, servCfg.ClientRepo, auditService, businessTypeService),
+		InvitationService: invitationService,
+		TenantRegistry: tenantRegistry,
 	}, nil
 }
@@ -0,0 +1,31 @@
+package injection
+
+import (
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/service"
+)
+
+// injectJobWorkers initializes the job worker pool and registers the handlers for every job type
+func injectJobWorkers(cfg *map[string]string, mailTransport interfaces.MailTransportInterface, servCfg *ServicesConfig) (interfaces.JobWorkerPoolInterface, error) {
+	workers, err := service.NewJobWorkerPool(cfg, servCfg.JobRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// register the job handlers
+	workers.Register(service.JobTypeDeliverMail, service.DeliverMailJob(mailTransport))
+
+	return workers, nil
+}
+
+// injectMaintenanceScheduler initializes the scheduler that sweeps expired data from the repositories
+func injectMaintenanceScheduler(cfg *map[string]string, servCfg *ServicesConfig, handCfg *HandlerConfig) (interfaces.MaintenanceSchedulerInterface, error) {
+	return service.NewMaintenanceScheduler(
+		cfg,
+		servCfg.MagicLinkRepo,
+		servCfg.LoginAttemptRepo,
+		servCfg.LoginHistoryRepo,
+		servCfg.JobRepo,
+		handCfg.AuditService,
+	)
+}
@@ -14,6 +14,14 @@ import (
 )
 
 func main() {
+    // run a subcommand instead of the server if one is given
+    if len(os.Args) > 1 {
+        if err := runCommand(os.Args[1:]); err != nil {
+            log.Fatalf("Failed to run command: %v", err)
+        }
+        return
+    }
+
     log.Println("Starting Server...")
 
     // initialize data sources
@@ -25,17 +33,26 @@ func main() {
     // release resources when main function returns
     defer dataSource.Close()
 
+    // bring the database schema up to date before anything uses it
+    if err = migrate(dataSource); err != nil {
+        log.Fatalf("Failed to migrate the database: %v", err)
+    }
+
     // initialize dependency injection
-    router, err := injection.Inject(dataSource)
+    app, err := injection.Inject(dataSource)
     if err != nil {
         log.Fatalf("Failed to inject data sources: %v", err)
     }
 
     srv := &http.Server{
         Addr: ":8080",
-        Handler: router,
+        Handler: app.Router,
     }
 
+    // start running background jobs and maintenance sweeps
+    app.JobWorkers.Start()
+    app.Maintenance.Start()
+
     // Graceful server shutdown - https://github.com/gin-gonic/examples/blob/master/graceful-shutdown/graceful-shutdown/server.go
     // listening to the server in a goroutine so it does not block the graceful
     // shutdown after
@@ -48,7 +65,7 @@ func main() {
     log.Printf("Listening on port %v\n", srv.Addr)
 
     // wait for kill signal in channel
-    quit := make(chan os.Signal)
+    quit := make(chan os.Signal, 1)
 
     signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
@@ -66,5 +83,17 @@ func main() {
         log.Fatalf("Failed to shutdown server. Error: %v\n", err)
     }
 
+    log.Println("Stopping maintenance sweeps...")
+    if err := app.Maintenance.Shutdown(ctx); err != nil {
+        log.Printf("Failed to stop maintenance sweeps. Error: %v\n", err)
+    }
+
+    // stop the job workers once no more requests can queue jobs,
+    // jobs they do not finish in time are picked up again after a restart
+    log.Println("Stopping job workers...")
+    if err := app.JobWorkers.Shutdown(ctx); err != nil {
+        log.Printf("Failed to stop job workers. Error: %v\n", err)
+    }
+
     log.Println("Server exiting")
 }
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+)
+
+// AuthorizeAdmin checks that the request carries the admin api key in the X-Admin-Key header
+// admin endpoints are closed to everyone when no admin api key is configured
+func AuthorizeAdmin(adminApiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Admin-Key")
+		if adminApiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminApiKey)) != 1 {
+			resErr := errors.ErrUnauthorized("sorry, you're not authorized for this request", nil)
+			c.JSON(resErr.Status, resErr)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
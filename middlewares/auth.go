@@ -31,7 +31,7 @@ func AuthorizeClient(ts interfaces.TokenServiceInterface) gin.HandlerFunc {
 		}
 
 		// get the client from the access token
-		client, err := ts.ClientFromAccessToken(splitTokenStr[1])
+		client, organizationId, err := ts.ClientFromAccessToken(c, splitTokenStr[1])
 		if err != nil {
 			resErr := errors.ErrUnauthorized("sorry, you're not authorized for this request", nil)
 			c.JSON(resErr.Status, resErr)
@@ -40,6 +40,9 @@ func AuthorizeClient(ts interfaces.TokenServiceInterface) gin.HandlerFunc {
 		}
 
 		c.Set("client", client)
+		if organizationId != nil {
+			c.Set("organization_id", *organizationId)
+		}
 
 		c.Next()
 	}
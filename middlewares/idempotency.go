@@ -0,0 +1,236 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// IdempotencyKeyHeader is the header that carries the idempotency key of a request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on a response that was replayed from an earlier request with the same key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength is the longest idempotency key accepted from a caller
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLock is how long a request holds its idempotency key before a retry may take it over,
+// in case the instance handling it died before it finished
+const idempotencyLock = time.Minute
+
+// returnsTokensKey is the context key a route that responds with tokens is marked with
+const returnsTokensKey = "idempotency_returns_tokens"
+
+// tokensClientKey is the context key holding the client a response with tokens was for
+const tokensClientKey = "idempotency_tokens_client"
+
+// replayClientKey is the context key holding the client a retry of a route that replays tokens issues new tokens to
+const replayClientKey = "idempotency_replay_client"
+
+// transientStatuses are the client error statuses that depend on when the request was made rather than on the request,
+// so they are not replayed to a retry, which may get a different answer
+var transientStatuses = map[int]bool{
+	http.StatusRequestTimeout:  true,
+	http.StatusLocked:          true,
+	http.StatusTooEarly:        true,
+	http.StatusTooManyRequests: true,
+}
+
+// replayedHeaders are the response headers stored with an idempotency key and replayed with its response
+var replayedHeaders = []string{"Content-Type", "Location", "Retry-After"}
+
+// Idempotency stores the response to a POST request made with an Idempotency-Key header,
+// and replays it to retries of the request with the same key until the key expires
+// the key is scoped to the tenant, the route and the caller's credentials, and reusing it for a different request is rejected
+// only successful responses and client errors that the same request always gets are stored, so a request that failed
+// on a server error, a rate limit or a lockout can be retried, and responses holding tokens are never stored,
+// only the client they were for on routes that replay tokens
+func Idempotency(repo interfaces.IdempotencyKeyRepositoryInterface, expiresIn time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			resErr := errors.ErrBadRequest("idempotency key is too long", nil)
+			c.AbortWithStatusJSON(resErr.Status, resErr)
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				resErr := errors.ErrBadRequest("failed to read request body", nil)
+				c.AbortWithStatusJSON(resErr.Status, resErr)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// the tenant is part of the key, so one tenant is never replayed another tenant's response
+		tenantId, _ := dto.TenantIdFromContext(c)
+		path := c.Request.URL.Path
+		id := hashParts(tenantId, path, idempotencyKey, c.GetHeader("Authorization"))
+		fingerprint := hashParts(path, string(body))
+
+		key := dao.NewIdempotencyKey(id, fingerprint, idempotencyLock, expiresIn)
+		reserved, err := repo.Reserve(c, key)
+		if err != nil {
+			// do not fail requests if the store is unavailable, they are just not protected from retries
+			log.Printf("Failed to reserve idempotency key. Error: %v\n", err)
+			c.Next()
+			return
+		}
+
+		if !reserved {
+			replayIdempotentResponse(c, key, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		returnsTokens := c.GetBool(returnsTokensKey)
+		tokensClient, replaysTokens := c.Get(tokensClientKey)
+		if !storableStatus(recorder.Status()) || (returnsTokens && !replaysTokens) {
+			if err = repo.Release(c, id); err != nil {
+				log.Printf("Failed to release idempotency key. Error: %v\n", err)
+			}
+			return
+		}
+
+		key.ResponseStatus = recorder.Status()
+		if replaysTokens {
+			clientId := tokensClient.(primitive.ObjectID)
+			key.ClientId = &clientId
+		} else {
+			key.ResponseBody = recorder.body.Bytes()
+			key.ResponseHeaders = make(map[string]string)
+			for _, h := range replayedHeaders {
+				if v := recorder.Header().Get(h); v != "" {
+					key.ResponseHeaders[h] = v
+				}
+			}
+		}
+		if err = repo.Complete(c, key); err != nil {
+			log.Printf("Failed to store idempotent response. Error: %v\n", err)
+		}
+	}
+}
+
+// ReturnsTokens marks a route as responding with tokens, so the idempotency middleware does not store its responses
+// a retry of the request is handled again instead, since a replayed response would keep a copy of the tokens in the store
+func ReturnsTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(returnsTokensKey, true)
+		c.Next()
+	}
+}
+
+// TokenReplay answers a retry of a request that responded with tokens by issuing new tokens to the client
+type TokenReplay func(c *gin.Context, clientId primitive.ObjectID)
+
+// ReplaysTokens marks a route as responding with tokens that a retry of the request is issued again,
+// for requests that cannot be handled twice, like a signup that would find its email already taken
+// the handler names the client the tokens are for with TokensIssuedTo, and only that client is stored with the key
+func ReplaysTokens(replay TokenReplay) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if clientId, ok := c.Get(replayClientKey); ok {
+			c.Header(IdempotentReplayedHeader, "true")
+			replay(c, clientId.(primitive.ObjectID))
+			c.Abort()
+			return
+		}
+		c.Set(returnsTokensKey, true)
+		c.Next()
+	}
+}
+
+// TokensIssuedTo records the client a successful response with tokens was for,
+// so the idempotency middleware can replay the request on a route that replays tokens
+func TokensIssuedTo(c *gin.Context, clientId primitive.ObjectID) {
+	c.Set(tokensClientKey, clientId)
+}
+
+// storableStatus reports whether a response with the status is stored and replayed to retries of the request
+func storableStatus(status int) bool {
+	if status >= http.StatusOK && status < http.StatusMultipleChoices {
+		return true
+	}
+	return status >= http.StatusBadRequest && status < http.StatusInternalServerError && !transientStatuses[status]
+}
+
+// replayIdempotentResponse answers a request whose idempotency key is already held or completed
+func replayIdempotentResponse(c *gin.Context, key *dao.IdempotencyKey, fingerprint string) {
+	if key.Fingerprint != fingerprint {
+		resErr := errors.ErrUnprocessableEntity("idempotency key was already used for a different request", nil)
+		c.AbortWithStatusJSON(resErr.Status, resErr)
+		return
+	}
+
+	if key.Status != dao.IdempotencyCompleted {
+		resErr := errors.ErrConflict("a request with this idempotency key is still being handled", nil)
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(resErr.Status, resErr)
+		return
+	}
+
+	// only the client of a response with tokens was stored, the route issues them new tokens
+	if key.ClientId != nil {
+		c.Set(replayClientKey, *key.ClientId)
+		c.Next()
+		return
+	}
+
+	for h, v := range key.ResponseHeaders {
+		c.Header(h, v)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(key.ResponseStatus, key.ResponseHeaders["Content-Type"], key.ResponseBody)
+	c.Abort()
+}
+
+// hashParts returns the hex encoded SHA-256 hash of the parts, separated so that they cannot run into each other
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body written by the handlers
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes the data to the response and keeps a copy of it
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// WriteString writes the string to the response and keeps a copy of it
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// memoryIdempotencyKeys keeps idempotency keys in memory
+type memoryIdempotencyKeys struct {
+	keys map[string]*dao.IdempotencyKey
+}
+
+func (m *memoryIdempotencyKeys) Reserve(ctx context.Context, key *dao.IdempotencyKey) (bool, error) {
+	if stored, ok := m.keys[key.Id]; ok {
+		*key = *stored
+		return false, nil
+	}
+	stored := *key
+	m.keys[key.Id] = &stored
+	return true, nil
+}
+
+func (m *memoryIdempotencyKeys) Complete(ctx context.Context, key *dao.IdempotencyKey) error {
+	stored := *key
+	stored.Status = dao.IdempotencyCompleted
+	m.keys[key.Id] = &stored
+	return nil
+}
+
+func (m *memoryIdempotencyKeys) Release(ctx context.Context, id string) error {
+	delete(m.keys, id)
+	return nil
+}
+
+// newIdempotentRouter returns a router whose POST /test responds with the next status each time it is handled
+func newIdempotentRouter(keys *memoryIdempotencyKeys, statuses []int, extra ...gin.HandlerFunc) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(dto.TenantKey, &dto.Tenant{Id: dto.DefaultTenantId})
+	})
+	router.Use(Idempotency(keys, time.Hour))
+
+	handled := 0
+	handlers := append(extra, func(c *gin.Context) {
+		status := statuses[handled]
+		handled++
+		c.JSON(status, gin.H{"handled": handled})
+	})
+	router.POST("/test", handlers...)
+	return router, &handled
+}
+
+func postWithKey(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyStoredStatuses(t *testing.T) {
+	tests := []struct {
+		status   int
+		replayed bool
+	}{
+		{status: http.StatusOK, replayed: true},
+		{status: http.StatusCreated, replayed: true},
+		{status: http.StatusBadRequest, replayed: true},
+		{status: http.StatusConflict, replayed: true},
+		{status: http.StatusLocked, replayed: false},
+		{status: http.StatusTooManyRequests, replayed: false},
+		{status: http.StatusInternalServerError, replayed: false},
+		{status: http.StatusServiceUnavailable, replayed: false},
+	}
+
+	for _, tt := range tests {
+		keys := &memoryIdempotencyKeys{keys: make(map[string]*dao.IdempotencyKey)}
+		router, handled := newIdempotentRouter(keys, []int{tt.status, http.StatusOK})
+
+		first := postWithKey(router, `{"a":1}`)
+		second := postWithKey(router, `{"a":1}`)
+
+		if tt.replayed {
+			if *handled != 1 || second.Header().Get(IdempotentReplayedHeader) != "true" {
+				t.Errorf("status %d: retry was handled again, want it replayed", tt.status)
+			}
+			if second.Code != tt.status || second.Body.String() != first.Body.String() {
+				t.Errorf("status %d: replayed %d %s, want %d %s", tt.status, second.Code, second.Body, first.Code, first.Body)
+			}
+		} else if *handled != 2 || second.Code != http.StatusOK {
+			t.Errorf("status %d: retry was replayed, want it handled again", tt.status)
+		}
+	}
+}
+
+func TestIdempotencyDoesNotStoreTokens(t *testing.T) {
+	keys := &memoryIdempotencyKeys{keys: make(map[string]*dao.IdempotencyKey)}
+	router, handled := newIdempotentRouter(keys, []int{http.StatusOK, http.StatusOK}, ReturnsTokens())
+
+	postWithKey(router, `{"a":1}`)
+	if len(keys.keys) != 0 {
+		t.Fatalf("stored %d idempotency keys for a route that returns tokens, want none", len(keys.keys))
+	}
+
+	second := postWithKey(router, `{"a":1}`)
+	if *handled != 2 || second.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry of a route that returns tokens was replayed, want it handled again")
+	}
+}
+
+func TestIdempotencyRejectsReusedKey(t *testing.T) {
+	keys := &memoryIdempotencyKeys{keys: make(map[string]*dao.IdempotencyKey)}
+	router, handled := newIdempotentRouter(keys, []int{http.StatusOK, http.StatusOK})
+
+	postWithKey(router, `{"a":1}`)
+	second := postWithKey(router, `{"a":2}`)
+	if *handled != 1 || second.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key for a different body got %d, want %d", second.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyReplaysSignupWithNewTokens(t *testing.T) {
+	keys := &memoryIdempotencyKeys{keys: make(map[string]*dao.IdempotencyKey)}
+	clientId := primitive.NewObjectID()
+
+	// the signup takes the email, so handling it again would find the email taken
+	signedUp, replayed := 0, 0
+	router, _ := newIdempotentRouter(keys, nil, ReplaysTokens(func(c *gin.Context, id primitive.ObjectID) {
+		replayed++
+		if id != clientId {
+			t.Errorf("replayed tokens for client %v, want %v", id, clientId)
+		}
+		c.JSON(http.StatusCreated, gin.H{"access_token": "at-2"})
+	}), func(c *gin.Context) {
+		signedUp++
+		if signedUp > 1 {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"message": "sorry, email is taken"})
+			return
+		}
+		TokensIssuedTo(c, clientId)
+		c.AbortWithStatusJSON(http.StatusCreated, gin.H{"access_token": "at-1"})
+	})
+
+	first := postWithKey(router, `{"email":"a@b.test"}`)
+	second := postWithKey(router, `{"email":"a@b.test"}`)
+
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("signup and its retry responded %d and %d, want %d both times", first.Code, second.Code, http.StatusCreated)
+	}
+	if signedUp != 1 || replayed != 1 || second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("signup was handled %d times and replayed %d times, want it handled and replayed once", signedUp, replayed)
+	}
+	for _, key := range keys.keys {
+		if strings.Contains(string(key.ResponseBody), "at-1") {
+			t.Errorf("stored the tokens of the signup with its idempotency key")
+		}
+	}
+}
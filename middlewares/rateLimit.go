@@ -0,0 +1,130 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// maxRateLimitBodySize is the largest request body read to find the email a request is counted by
+const maxRateLimitBodySize = 64 << 10
+
+// RateLimiter holds the rate limit store and the rules configured for each route
+type RateLimiter struct {
+	store interfaces.RateLimitStoreInterface
+	rules map[string]*dto.RateLimitRule
+}
+
+// NewRateLimiter creates a rate limiter with the store and the rules keyed by route
+func NewRateLimiter(store interfaces.RateLimitStoreInterface, rules map[string]*dto.RateLimitRule) *RateLimiter {
+	return &RateLimiter{
+		store: store,
+		rules: rules,
+	}
+}
+
+// For returns the rate limit middleware for the route
+// the middleware lets every request through if the route has no rule configured
+func (rl *RateLimiter) For(route string) gin.HandlerFunc {
+	rule, ok := rl.rules[route]
+	if !ok || rule == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return RateLimit(rl.store, rule)
+}
+
+// RateLimit counts each request against the rule and rejects it once the limit is reached
+func RateLimit(store interfaces.RateLimitStoreInterface, rule *dto.RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, err := rateLimitKey(c, rule.Key)
+		if err != nil {
+			resErr := errors.ErrRequestEntityTooLarge("request body is too large", nil)
+			c.JSON(resErr.Status, resErr)
+			c.Abort()
+			return
+		}
+		key := rule.Route + ":" + value
+
+		result, err := store.Allow(c, key, rule)
+		if err != nil {
+			// do not lock everyone out if the store is unavailable
+			log.Printf("Failed to check rate limit for key: %s. Error: %v\n", key, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(result.ResetAfter.Seconds()+0.5)))
+
+		if !result.Allowed {
+			resErr := errors.ErrTooManyRequests("too many requests, please try again later", result.RetryAfter, nil)
+			c.Header("Retry-After", strconv.Itoa(resErr.RetryAfter))
+			c.JSON(resErr.Status, resErr)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitKey returns the value a request is counted by
+// it falls back to the ip when the email or the logged-in client is not available
+// it fails when the body is too large to read the email from
+func rateLimitKey(c *gin.Context, key dto.RateLimitKey) (string, error) {
+	switch key {
+	case dto.RateLimitByEmail:
+		email, err := emailFromBody(c)
+		if err != nil {
+			return "", err
+		}
+		// an email belongs to a different client in each tenant, so it is counted within the tenant
+		if email != "" {
+			tenantId, _ := dto.TenantIdFromContext(c)
+			return string(key) + ":" + tenantId + ":" + email, nil
+		}
+	case dto.RateLimitByClient:
+		// client ids are unique across tenants, so they do not need the tenant
+		if client, ok := c.Get("client"); ok {
+			return string(key) + ":" + client.(*dao.Client).Id.Hex(), nil
+		}
+	}
+	return string(dto.RateLimitByIP) + ":" + c.ClientIP(), nil
+}
+
+// emailFromBody reads the email from a JSON request body of at most maxRateLimitBodySize bytes
+// the body is put back so the handler can still bind it
+func emailFromBody(c *gin.Context) (string, error) {
+	if c.Request.Body == nil {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRateLimitBodySize))
+	if err != nil {
+		return "", err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err = json.Unmarshal(body, &req); err != nil {
+		return "", nil
+	}
+
+	return strings.ToLower(strings.TrimSpace(req.Email)), nil
+}
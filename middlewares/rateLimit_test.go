@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// rateLimitContext returns the context of a POST request with the body in the tenant
+func rateLimitContext(tenant *dto.Tenant, body string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	c.Request.RemoteAddr = "192.0.2.1:1234"
+	c.Set(dto.TenantKey, tenant)
+	return c
+}
+
+// rateLimitKeyOf returns the key a POST request with the body is counted by in the tenant
+func rateLimitKeyOf(tenant *dto.Tenant, key dto.RateLimitKey, body string) string {
+	value, _ := rateLimitKey(rateLimitContext(tenant, body), key)
+	return value
+}
+
+func TestRateLimitKeyCountsEmailsWithinTenant(t *testing.T) {
+	body := `{"email":"Victim@x.test"}`
+	keyA := rateLimitKeyOf(&dto.Tenant{Id: "tenant-a"}, dto.RateLimitByEmail, body)
+	keyB := rateLimitKeyOf(&dto.Tenant{Id: "tenant-b"}, dto.RateLimitByEmail, body)
+
+	if keyA != "email:tenant-a:victim@x.test" {
+		t.Errorf("email key is %q, want %q", keyA, "email:tenant-a:victim@x.test")
+	}
+	if keyA == keyB {
+		t.Errorf("an email is counted by the same key %q in two tenants", keyA)
+	}
+}
+
+func TestRateLimitKeyCountsLoggedInClient(t *testing.T) {
+	client := &dao.Client{Id: primitive.NewObjectID()}
+	c := rateLimitContext(&dto.Tenant{Id: "tenant-a"}, `{}`)
+	c.Set("client", client)
+
+	if key, _ := rateLimitKey(c, dto.RateLimitByClient); key != "client:"+client.Id.Hex() {
+		t.Errorf("client key is %q, want %q", key, "client:"+client.Id.Hex())
+	}
+
+	// a route that does not authorize the client first is counted by ip
+	if key := rateLimitKeyOf(&dto.Tenant{Id: "tenant-a"}, dto.RateLimitByClient, `{}`); key != "ip:192.0.2.1" {
+		t.Errorf("client key without a logged-in client is %q, want %q", key, "ip:192.0.2.1")
+	}
+}
+
+func TestRateLimitKeyRefusesLargeBody(t *testing.T) {
+	body := `{"email":"a@x.test","padding":"` + strings.Repeat("a", maxRateLimitBodySize) + `"}`
+	if _, err := rateLimitKey(rateLimitContext(&dto.Tenant{Id: "tenant-a"}, body), dto.RateLimitByEmail); err == nil {
+		t.Errorf("read the email from a body larger than %d bytes", maxRateLimitBodySize)
+	}
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// RequestIdHeader is the header that carries the id of a request
+const RequestIdHeader = "X-Request-ID"
+
+// maxRequestIdLength is the longest request id accepted from a caller
+const maxRequestIdLength = 64
+
+// RequestMeta records where a request came from so services can read it from the request context
+// the request id is taken from the X-Request-ID header or generated, and echoed back in the response
+func RequestMeta() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(RequestIdHeader)
+		if requestId == "" || len(requestId) > maxRequestIdLength {
+			var err error
+			if requestId, err = utils.RandomToken(8); err != nil {
+				log.Printf("Failed to generate request id. Error: %v\n", err)
+			}
+		}
+		c.Header(RequestIdHeader, requestId)
+
+		c.Set(dto.RequestMetaKey, dto.RequestMeta{
+			RequestId: requestId,
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Set(dto.RequestDoneKey, c.Request.Context().Done())
+
+		c.Next()
+	}
+}
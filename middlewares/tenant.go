@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// ResolveTenant finds the tenant a request belongs to and stores it in the request context
+// the tenant is named by the tenant header, or else found from the request host, or else from the
+// tenant the access token was issued for, and requests that name none belong to the default tenant
+// the tenant in the access token is read without checking the signature, since the token is then
+// verified with that tenant's keys, and a header naming a different tenant than the host is refused
+func ResolveTenant(tenants interfaces.TenantRegistryInterface, ts interfaces.TokenServiceInterface, header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hostTenant, hostOk := tenants.FindByHost(c.Request.Host)
+
+		var tenant *dto.Tenant
+		if id := c.GetHeader(header); id != "" {
+			var ok bool
+			if tenant, ok = tenants.Find(id); !ok {
+				resErr := errors.ErrBadRequest("unknown tenant", nil)
+				c.JSON(resErr.Status, resErr)
+				c.Abort()
+				return
+			}
+			if hostOk && hostTenant != tenant {
+				resErr := errors.ErrBadRequest("the tenant does not match the request host", nil)
+				c.JSON(resErr.Status, resErr)
+				c.Abort()
+				return
+			}
+		} else if hostOk {
+			tenant = hostTenant
+		} else if id := ts.TenantIdFromToken(strings.TrimPrefix(c.GetHeader("Token"), "Bearer ")); id != "" {
+			tenant, _ = tenants.Find(id)
+		}
+
+		if tenant == nil {
+			tenant = tenants.Default()
+		}
+
+		c.Set(dto.TenantKey, tenant)
+
+		c.Next()
+	}
+}
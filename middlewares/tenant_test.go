@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// memoryTenants keeps tenants in memory by their id and host
+type memoryTenants struct {
+	tenants []*dto.Tenant
+}
+
+func (m *memoryTenants) Default() *dto.Tenant {
+	return m.tenants[0]
+}
+
+func (m *memoryTenants) Find(id string) (*dto.Tenant, bool) {
+	for _, tenant := range m.tenants {
+		if tenant.Id == id {
+			return tenant, true
+		}
+	}
+	return nil, false
+}
+
+func (m *memoryTenants) FindByHost(host string) (*dto.Tenant, bool) {
+	for _, tenant := range m.tenants {
+		for _, h := range tenant.Hosts {
+			if h == host {
+				return tenant, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (m *memoryTenants) All() []*dto.Tenant {
+	return m.tenants
+}
+
+// tokenTenants reads the tenant of a token as the token itself, the other token methods are left nil
+type tokenTenants struct {
+	interfaces.TokenServiceInterface
+}
+
+func (tokenTenants) TenantIdFromToken(tokenString string) string {
+	return tokenString
+}
+
+func TestResolveTenant(t *testing.T) {
+	tenants := &memoryTenants{tenants: []*dto.Tenant{
+		{Id: dto.DefaultTenantId},
+		{Id: "tenant-a", Hosts: []string{"a.test"}},
+		{Id: "tenant-b", Hosts: []string{"b.test"}},
+	}}
+
+	tests := []struct {
+		name   string
+		host   string
+		header string
+		token  string
+		status int
+		tenant string
+	}{
+		{name: "header matching the host", host: "a.test", header: "tenant-a", status: http.StatusOK, tenant: "tenant-a"},
+		{name: "header disagreeing with the host", host: "a.test", header: "tenant-b", status: http.StatusBadRequest},
+		{name: "unknown header", host: "other.test", header: "tenant-c", status: http.StatusBadRequest},
+		{name: "header without a tenant host", host: "other.test", header: "tenant-b", status: http.StatusOK, tenant: "tenant-b"},
+		{name: "host", host: "b.test", token: "tenant-a", status: http.StatusOK, tenant: "tenant-b"},
+		{name: "token", host: "other.test", token: "tenant-a", status: http.StatusOK, tenant: "tenant-a"},
+		{name: "default", host: "other.test", status: http.StatusOK, tenant: dto.DefaultTenantId},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		router := gin.New()
+		router.Use(ResolveTenant(tenants, tokenTenants{}, "X-Tenant-Id"))
+
+		resolved := ""
+		router.GET("/test", func(c *gin.Context) {
+			resolved = dto.TenantFromContext(c).Id
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Host = tt.host
+		if tt.header != "" {
+			req.Header.Set("X-Tenant-Id", tt.header)
+		}
+		if tt.token != "" {
+			req.Header.Set("Token", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status is %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if resolved != tt.tenant {
+			t.Errorf("%s: resolved tenant %q, want %q", tt.name, resolved, tt.tenant)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/datasource"
+	"github.com/leonardchinonso/auth_service_cmp7174/migrations"
+)
+
+// migrate applies the pending migrations when the server starts
+// when that is turned off the migrations are applied with the migrate command, and the server refuses to start
+// until they are, since the indexes it relies on for unique emails and expiring data are created by them
+func migrate(ds *datasource.DataSource) error {
+	timeout, err := strconv.Atoi((*ds.Cfg)[config.MigrationTimeout])
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", config.MigrationTimeout, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	migrator := migrations.NewMigrator(ds.Database, ds.Cfg)
+
+	if on, _ := strconv.ParseBool((*ds.Cfg)[config.MigrateOnStartup]); !on {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations are pending, from %d %s, run `migrate up` first", len(pending), pending[0].Version, pending[0].Name)
+		}
+
+		log.Println("Skipping migrations on startup, none are pending")
+		return nil
+	}
+
+	applied, err := migrator.Up(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Applied %d migrations\n", len(applied))
+	return nil
+}
+
+// runMigrations runs the migrate subcommand named by the first argument
+func runMigrations(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up [-to version] | down [-steps n] | status")
+	}
+
+	dataSource, err := datasource.InitDataSource()
+	if err != nil {
+		return fmt.Errorf("failed to initialize data sources: %v", err)
+	}
+	defer dataSource.Close()
+
+	migrator := migrations.NewMigrator(dataSource.Database, dataSource.Cfg)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		fs := flag.NewFlagSet("migrate up", flag.ExitOnError)
+		to := fs.Int("to", 0, "apply the migrations up to and including this version, all of them if 0")
+		if err = fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		applied, err := migrator.Up(ctx, *to)
+		for _, m := range applied {
+			log.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "how many of the most recently applied migrations to roll back")
+		if err = fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		rolledBack, err := migrator.Down(ctx, *steps)
+		for _, m := range rolledBack {
+			log.Printf("Rolled back migration %d: %s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Name, appliedAt)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+}
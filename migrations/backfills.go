@@ -0,0 +1,486 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// tokenExpiryBackfill gives the tokens stored before tokens had an expiry a full refresh token lifetime,
+// so the TTL index removes them too
+// it is not undone on the way down since the older code ignores the fields
+var tokenExpiryBackfill = Migration{
+	Version: 3,
+	Name:    "backfill_token_expiry",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		rtExpiresIn, err := strconv.Atoi((*cfg)[config.RTExpiresIn])
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", config.RTExpiresIn, err)
+		}
+
+		now := time.Now()
+		filter := bson.D{{Key: "expires_at", Value: bson.D{{Key: "$exists", Value: false}}}}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "created_at", Value: now},
+			{Key: "expires_at", Value: now.Add(time.Duration(rtExpiresIn) * time.Second)},
+		}}}
+		if _, err = db.Collection("tokens").UpdateMany(ctx, filter, update); err != nil {
+			return fmt.Errorf("failed to backfill token expiry: %w", err)
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return nil
+	},
+}
+
+// clientVersionBackfill starts the clients created before profile updates were versioned at version 1,
+// so the updates made against them can be compared and set
+// it is not undone on the way down since the older code ignores the version
+var clientVersionBackfill = Migration{
+	Version: 9,
+	Name:    "backfill_client_version",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		filter := bson.D{{Key: "version", Value: bson.D{{Key: "$exists", Value: false}}}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "version", Value: int64(1)}}}}
+		if _, err := db.Collection("clients").UpdateMany(ctx, filter, update); err != nil {
+			return fmt.Errorf("failed to backfill client version: %w", err)
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return nil
+	},
+}
+
+// phoneNumberBackfill stores the phone numbers saved before they were normalized in E.164 format
+// a number that cannot be normalized is left as it is, and cannot be verified until the client replaces it
+// it is not undone on the way down since the older code accepts any phone number
+var phoneNumberBackfill = Migration{
+	Version: 11,
+	Name:    "backfill_phone_number_e164",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		clients := db.Collection("clients")
+		filter := bson.D{{Key: "phone_number", Value: bson.D{{Key: "$nin", Value: bson.A{nil, ""}}}}}
+		opts := options.Find().SetProjection(bson.D{{Key: "phone_number", Value: 1}})
+
+		cursor, err := clients.Find(ctx, filter, opts)
+		if err != nil {
+			return fmt.Errorf("failed to find phone numbers: %w", err)
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var client struct {
+				Id          primitive.ObjectID `bson:"_id"`
+				PhoneNumber string             `bson:"phone_number"`
+			}
+			if err = cursor.Decode(&client); err != nil {
+				return fmt.Errorf("failed to decode phone number: %w", err)
+			}
+
+			normalized, err := utils.NormalizePhoneNumber(client.PhoneNumber)
+			if err != nil || normalized == client.PhoneNumber {
+				continue
+			}
+
+			update := bson.D{{Key: "$set", Value: bson.D{{Key: "phone_number", Value: normalized}}}}
+			if _, err = clients.UpdateByID(ctx, client.Id, update); err != nil {
+				return fmt.Errorf("failed to backfill phone number: %w", err)
+			}
+		}
+		return cursor.Err()
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return nil
+	},
+}
+
+// structuredAddressBackfill parses the addresses stored as a single line of text into structured addresses
+// on a best-effort basis, an address that cannot be parsed is kept as its lines
+// on the way down the structured addresses are written back as a single line of text
+var structuredAddressBackfill = Migration{
+	Version: 13,
+	Name:    "backfill_structured_address",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return rewriteAddresses(ctx, db, "string", func(raw bson.RawValue) (interface{}, error) {
+			text, ok := raw.StringValueOK()
+			if !ok {
+				return nil, fmt.Errorf("address is not a string")
+			}
+			return dao.ParseAddress(text), nil
+		})
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return rewriteAddresses(ctx, db, "object", func(raw bson.RawValue) (interface{}, error) {
+			var address dao.Address
+			if err := raw.Unmarshal(&address); err != nil {
+				return nil, err
+			}
+			return address.String(), nil
+		})
+	},
+}
+
+// rewriteAddresses replaces every client address stored as the bson type with what rewrite returns for it
+func rewriteAddresses(ctx context.Context, db *mongo.Database, bsonType string, rewrite func(raw bson.RawValue) (interface{}, error)) error {
+	clients := db.Collection("clients")
+	filter := bson.D{{Key: "address", Value: bson.D{{Key: "$type", Value: bsonType}}}}
+	opts := options.Find().SetProjection(bson.D{{Key: "address", Value: 1}})
+
+	cursor, err := clients.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("failed to find addresses: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		id, ok := cursor.Current.Lookup("_id").ObjectIDOK()
+		if !ok {
+			return fmt.Errorf("client has no object id")
+		}
+
+		address, err := rewrite(cursor.Current.Lookup("address"))
+		if err != nil {
+			return fmt.Errorf("failed to rewrite address of client with id: %v: %w", id.Hex(), err)
+		}
+
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "address", Value: address}}}}
+		if _, err = clients.UpdateByID(ctx, id, update); err != nil {
+			return fmt.Errorf("failed to backfill address: %w", err)
+		}
+	}
+	return cursor.Err()
+}
+
+// seededBusinessTypes are the business types that were hard-coded before they were stored
+var seededBusinessTypes = []string{"Software Engineering", "Construction", "Information Technology"}
+
+// businessTypeSeed creates the business type indexes and stores the business types that were hard-coded as sectors,
+// so the clients that chose them keep a valid business type
+var businessTypeSeed = Migration{
+	Version: 14,
+	Name:    "seed_business_types",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "business_types",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "name", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "parent_id", Value: 1}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		c := db.Collection("business_types")
+		for _, name := range seededBusinessTypes {
+			businessType := dao.NewBusinessType(name, nil)
+			filter := bson.D{{Key: "name", Value: name}}
+			update := bson.D{{Key: "$setOnInsert", Value: businessType}}
+			if _, err = c.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+				return fmt.Errorf("failed to seed business type %q: %w", name, err)
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "business_types", "name_1", "parent_id_1")
+	},
+}
+
+// businessProfileClient is a client as it was stored while clients had their own business profile
+type businessProfileClient struct {
+	Id           primitive.ObjectID `bson:"_id"`
+	Name         string             `bson:"name"`
+	Address      dao.Address        `bson:"address"`
+	BusinessType string             `bson:"business_type"`
+	ApiKey       string             `bson:"api_key"`
+	CreatedAt    time.Time          `bson:"created_at"`
+}
+
+// organizationBackfill creates the organization and membership indexes, and makes every client that signed up
+// before organizations existed the owner of an organization with their business profile
+// the organizations are kept on the way down since members may have been added to them
+var organizationBackfill = Migration{
+	Version: 15,
+	Name:    "backfill_organizations",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "memberships",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "client_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: 1}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		memberships := db.Collection("memberships")
+		organizations := db.Collection("organizations")
+
+		cursor, err := db.Collection("clients").Find(ctx, bson.D{})
+		if err != nil {
+			return fmt.Errorf("failed to find clients: %w", err)
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var client businessProfileClient
+			if err = cursor.Decode(&client); err != nil {
+				return fmt.Errorf("failed to decode client: %w", err)
+			}
+
+			// a client that is already a member of an organization was backfilled or signed up after organizations existed
+			count, err := memberships.CountDocuments(ctx, bson.D{{Key: "client_id", Value: client.Id}}, options.Count().SetLimit(1))
+			if err != nil {
+				return fmt.Errorf("failed to count memberships: %w", err)
+			}
+			if count > 0 {
+				continue
+			}
+
+			organization := dao.NewOrganization(client.Name, client.Address, client.BusinessType, client.ApiKey)
+			organization.CreatedAt = client.CreatedAt
+			result, err := organizations.InsertOne(ctx, organization)
+			if err != nil {
+				return fmt.Errorf("failed to backfill organization: %w", err)
+			}
+
+			membership := dao.NewMembership(result.InsertedID.(primitive.ObjectID), client.Id, dao.RoleOwner)
+			membership.CreatedAt = client.CreatedAt
+			if _, err = memberships.InsertOne(ctx, membership); err != nil {
+				return fmt.Errorf("failed to backfill membership: %w", err)
+			}
+		}
+		return cursor.Err()
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "memberships", "organization_id_1_client_id_1", "client_id_1_created_at_1")
+	},
+}
+
+// tenantBackfill puts the clients and tokens stored before there were tenants in the default tenant,
+// and makes client emails unique within a tenant instead of across the deployment
+// the tenant ids are kept on the way down since the older code ignores them, but the global email
+// index cannot be restored once two tenants have a client with the same email
+var tenantBackfill = Migration{
+	Version: 17,
+	Name:    "backfill_tenants",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		filter := bson.D{{Key: "tenant_id", Value: bson.D{{Key: "$exists", Value: false}}}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "tenant_id", Value: dto.DefaultTenantId}}}}
+		for _, collection := range []string{"clients", "tokens"} {
+			if _, err := db.Collection(collection).UpdateMany(ctx, filter, update); err != nil {
+				return fmt.Errorf("failed to backfill %s tenant: %w", collection, err)
+			}
+		}
+
+		err := createIndexes(ctx, db, "clients", mongo.IndexModel{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "clients", "email_1")
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "clients", mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("more than one tenant has a client with the same email, remove them before migrating down: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "clients", "tenant_id_1_email_1")
+	},
+}
+
+// clientBusinessProfileRemoval removes the business profile from the clients, since the organizations own it
+// and organizationBackfill gave every client that had one an organization with it
+// on the way down every client gets the business profile of the oldest organization they own back
+var clientBusinessProfileRemoval = Migration{
+	Version: 20,
+	Name:    "remove_client_business_profile",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		update := bson.D{{Key: "$unset", Value: bson.D{
+			{Key: "address", Value: ""},
+			{Key: "business_type", Value: ""},
+			{Key: "api_key", Value: ""},
+		}}}
+		if _, err := db.Collection("clients").UpdateMany(ctx, bson.D{}, update); err != nil {
+			return fmt.Errorf("failed to remove client business profile: %w", err)
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		clients := db.Collection("clients")
+		organizations := db.Collection("organizations")
+
+		filter := bson.D{{Key: "role", Value: dao.RoleOwner}}
+		opts := options.Find().SetSort(bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: 1}})
+		cursor, err := db.Collection("memberships").Find(ctx, filter, opts)
+		if err != nil {
+			return fmt.Errorf("failed to find owners: %w", err)
+		}
+		defer cursor.Close(ctx)
+
+		var previous primitive.ObjectID
+		for cursor.Next(ctx) {
+			var membership dao.Membership
+			if err = cursor.Decode(&membership); err != nil {
+				return fmt.Errorf("failed to decode membership: %w", err)
+			}
+
+			// only the oldest organization a client owns is restored
+			if membership.ClientId == previous {
+				continue
+			}
+			previous = membership.ClientId
+
+			var organization dao.Organization
+			err = organizations.FindOne(ctx, bson.D{{Key: "_id", Value: membership.OrganizationId}}).Decode(&organization)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to find organization: %w", err)
+			}
+
+			update := bson.D{{Key: "$set", Value: bson.D{
+				{Key: "address", Value: organization.Address},
+				{Key: "business_type", Value: organization.BusinessType},
+				{Key: "api_key", Value: organization.ApiKey},
+			}}}
+			if _, err = clients.UpdateByID(ctx, membership.ClientId, update); err != nil {
+				return fmt.Errorf("failed to restore client business profile: %w", err)
+			}
+		}
+		return cursor.Err()
+	},
+}
+
+// tenantOwnedBackfill puts the organizations, memberships, invitations, email changes and phone codes stored before
+// they were scoped to tenants in the tenant of the client they belong to, and the organizations in the tenant of
+// their members, so they can only be found through that tenant
+// documents whose client or members no longer exist are put in the default tenant
+// the tenant ids are kept on the way down since the older code ignores them
+var tenantOwnedBackfill = Migration{
+	Version: 21,
+	Name:    "backfill_tenant_owned",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		clients := db.Collection("clients")
+		for _, owned := range []struct{ collection, field string }{
+			{"memberships", "client_id"},
+			{"invitations", "invited_by"},
+			{"email_changes", "client_id"},
+			{"phone_codes", "client_id"},
+		} {
+			if err := backfillTenant(ctx, db.Collection(owned.collection), owned.field, clients, "_id"); err != nil {
+				return err
+			}
+		}
+
+		// the memberships are backfilled first, so the organizations can take the tenant of their members
+		return backfillTenant(ctx, db.Collection("organizations"), "_id", db.Collection("memberships"), "organization_id")
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return nil
+	},
+}
+
+// backfillTenant sets the tenant of the documents in the collection that have none to the tenant of the document
+// in the owners collection whose ownerField matches their field
+func backfillTenant(ctx context.Context, c *mongo.Collection, field string, owners *mongo.Collection, ownerField string) error {
+	filter := bson.D{{Key: "tenant_id", Value: bson.D{{Key: "$exists", Value: false}}}}
+	cursor, err := c.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: field, Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("failed to find %s without a tenant: %w", c.Name(), err)
+	}
+	defer cursor.Close(ctx)
+
+	tenants := make(map[primitive.ObjectID]string)
+	for cursor.Next(ctx) {
+		id, ok := cursor.Current.Lookup("_id").ObjectIDOK()
+		if !ok {
+			return fmt.Errorf("%s document has no object id", c.Name())
+		}
+		ownerId, _ := cursor.Current.Lookup(field).ObjectIDOK()
+
+		tenantId, ok := tenants[ownerId]
+		if !ok {
+			var owner struct {
+				TenantId string `bson:"tenant_id"`
+			}
+			err = owners.FindOne(ctx, bson.D{{Key: ownerField, Value: ownerId}}).Decode(&owner)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return fmt.Errorf("failed to find the tenant of %s with id: %v: %w", c.Name(), id.Hex(), err)
+			}
+			tenantId = owner.TenantId
+			if tenantId == "" {
+				tenantId = dto.DefaultTenantId
+			}
+			tenants[ownerId] = tenantId
+		}
+
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "tenant_id", Value: tenantId}}}}
+		if _, err = c.UpdateByID(ctx, id, update); err != nil {
+			return fmt.Errorf("failed to backfill %s tenant: %w", c.Name(), err)
+		}
+	}
+	return cursor.Err()
+}
+
+// magicLinkTenantBackfill puts the magic links stored before they were scoped to tenants in the default tenant,
+// since a link does not record the client it was requested for, and counts the requests for an email within a tenant
+// the links expire within minutes, so a link of another tenant that stops working can just be requested again
+// the tenant ids are kept on the way down since the older code ignores them
+var magicLinkTenantBackfill = Migration{
+	Version: 22,
+	Name:    "backfill_magic_link_tenants",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		filter := bson.D{{Key: "tenant_id", Value: bson.D{{Key: "$exists", Value: false}}}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "tenant_id", Value: dto.DefaultTenantId}}}}
+		if _, err := db.Collection("magic_links").UpdateMany(ctx, filter, update); err != nil {
+			return fmt.Errorf("failed to backfill magic_links tenant: %w", err)
+		}
+
+		err := createIndexes(ctx, db, "magic_links", mongo.IndexModel{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}, {Key: "created_at", Value: 1}},
+		})
+		if err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "magic_links", "email_1_created_at_1")
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "magic_links", mongo.IndexModel{
+			Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: 1}},
+		})
+		if err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "magic_links", "tenant_id_1_email_1_created_at_1")
+	},
+}
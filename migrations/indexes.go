@@ -0,0 +1,315 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the indexes keep their default names, so migrating a database where the
+// services already created them does not fail on a name conflict
+
+// clientEmailIndex makes client emails unique, so two signups racing with the same email cannot both succeed
+var clientEmailIndex = Migration{
+	Version: 1,
+	Name:    "create_client_email_unique_index",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "clients", mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("more than one client has the same email, merge them before migrating: %w", err)
+		}
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "clients", "email_1")
+	},
+}
+
+// tokenIndexes keeps a single token pair per client and removes a pair once its refresh token expires
+// clients that ended up with more than one pair from racing logins are logged out so the index can be created
+var tokenIndexes = Migration{
+	Version: 2,
+	Name:    "create_token_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		tokens := db.Collection("tokens")
+		cursor, err := tokens.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$client_id"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+			{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find duplicate tokens: %w", err)
+		}
+
+		var duplicates []struct {
+			ClientId interface{} `bson:"_id"`
+		}
+		if err = cursor.All(ctx, &duplicates); err != nil {
+			return fmt.Errorf("failed to decode duplicate tokens: %w", err)
+		}
+
+		if len(duplicates) > 0 {
+			clientIds := bson.A{}
+			for _, d := range duplicates {
+				clientIds = append(clientIds, d.ClientId)
+			}
+			if _, err = tokens.DeleteMany(ctx, bson.D{{Key: "client_id", Value: bson.D{{Key: "$in", Value: clientIds}}}}); err != nil {
+				return fmt.Errorf("failed to delete duplicate tokens: %w", err)
+			}
+		}
+
+		return createIndexes(ctx, db, "tokens",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "client_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		)
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "tokens", "client_id_1", "expires_at_1")
+	},
+}
+
+// auditIndexes keeps the audit chain linear, two events that race for the same sequence number cannot both be inserted
+var auditIndexes = Migration{
+	Version: 4,
+	Name:    "create_audit_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "audit_events", mongo.IndexModel{
+			Keys:    bson.D{{Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+
+		return createIndexes(ctx, db, "audit_checkpoints", mongo.IndexModel{
+			Keys: bson.D{{Key: "sequence", Value: 1}},
+		})
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		if err := dropIndexes(ctx, db, "audit_events", "sequence_1"); err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "audit_checkpoints", "sequence_1")
+	},
+}
+
+// jobIndexes lets workers find the next job to claim
+var jobIndexes = Migration{
+	Version: 5,
+	Name:    "create_job_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "jobs", mongo.IndexModel{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}},
+		})
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "jobs", "status_1_run_at_1")
+	},
+}
+
+// loginIndexes covers the lookups made on every login
+var loginIndexes = Migration{
+	Version: 6,
+	Name:    "create_login_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "login_attempts", mongo.IndexModel{
+			Keys: bson.D{{Key: "key", Value: 1}},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = createIndexes(ctx, db, "login_history", mongo.IndexModel{
+			Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "created_at", Value: -1}},
+		})
+		if err != nil {
+			return err
+		}
+
+		return createIndexes(ctx, db, "magic_links",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "token_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: 1}},
+			},
+		)
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		if err := dropIndexes(ctx, db, "login_attempts", "key_1"); err != nil {
+			return err
+		}
+		if err := dropIndexes(ctx, db, "login_history", "client_id_1_created_at_-1"); err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "magic_links", "token_id_1", "email_1_created_at_1")
+	},
+}
+
+// rateLimitIndexes removes the stored rate limit counters once they expire
+var rateLimitIndexes = Migration{
+	Version: 7,
+	Name:    "create_rate_limit_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "rate_limits", mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "rate_limits", "expires_at_1")
+	},
+}
+
+// idempotencyKeyIndexes removes the stored responses to requests with an Idempotency-Key header once they expire
+var idempotencyKeyIndexes = Migration{
+	Version: 8,
+	Name:    "create_idempotency_key_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "idempotency_keys", mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "idempotency_keys", "expires_at_1")
+	},
+}
+
+// emailChangeIndexes looks up email changes by the hashes of their link tokens,
+// and removes them once they can no longer be confirmed or cancelled
+var emailChangeIndexes = Migration{
+	Version: 10,
+	Name:    "create_email_change_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "email_changes",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "confirm_token_hash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "cancel_token_hash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "status", Value: 1}},
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		)
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "email_changes", "confirm_token_hash_1", "cancel_token_hash_1", "client_id_1_status_1", "expires_at_1")
+	},
+}
+
+// phoneCodeIndexes keeps one phone code per client and purpose, and removes the codes once they expire
+var phoneCodeIndexes = Migration{
+	Version: 12,
+	Name:    "create_phone_code_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "phone_codes",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "client_id", Value: 1}, {Key: "purpose", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		)
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "phone_codes", "client_id_1_purpose_1", "expires_at_1")
+	},
+}
+
+// invitationIndexes looks up invitations by the token id in their links, and lists the pending ones of an organization
+var invitationIndexes = Migration{
+	Version: 16,
+	Name:    "create_invitation_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "invitations",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "token_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "organization_id", Value: 1}, {Key: "status", Value: 1}, {Key: "email", Value: 1}},
+			},
+		)
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "invitations", "token_id_1", "organization_id_1_status_1_email_1")
+	},
+}
+
+// revokeLinkIndexes looks up the revoke links from login alerts by the token id in them,
+// and removes them once they expire since a used or expired link is never looked up again
+var revokeLinkIndexes = Migration{
+	Version: 18,
+	Name:    "create_revoke_link_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return createIndexes(ctx, db, "revoke_links",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "token_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		)
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		return dropIndexes(ctx, db, "revoke_links", "token_id_1", "expires_at_1")
+	},
+}
+
+// phoneCodeSendWindowIndexes keeps a phone code until its send window ends instead of only until it expires,
+// so the codes sent in the window are still counted after the last one expires
+// the codes stored before there were send windows are removed when they expire, as they were before
+var phoneCodeSendWindowIndexes = Migration{
+	Version: 19,
+	Name:    "create_phone_code_send_window_indexes",
+	Up: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		filter := bson.D{{Key: "purge_at", Value: bson.D{{Key: "$exists", Value: false}}}}
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "purge_at", Value: "$expires_at"}}}}}
+		if _, err := db.Collection("phone_codes").UpdateMany(ctx, filter, update); err != nil {
+			return fmt.Errorf("failed to backfill phone code purge time: %w", err)
+		}
+
+		err := createIndexes(ctx, db, "phone_codes", mongo.IndexModel{
+			Keys:    bson.D{{Key: "purge_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "phone_codes", "expires_at_1")
+	},
+	Down: func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error {
+		err := createIndexes(ctx, db, "phone_codes", mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+		return dropIndexes(ctx, db, "phone_codes", "purge_at_1")
+	},
+}
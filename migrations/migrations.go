@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration is a versioned change to the database schema, such as creating an index or backfilling a field
+// migrations are applied in version order and each one is only ever applied once, with the service config
+// a migration whose change the older code works with, such as a backfill of fields it ignores, has a Down that does nothing,
+// and a migration that cannot be undone has no Down, so rolling back stops at it
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error
+	Down    func(ctx context.Context, db *mongo.Database, cfg *map[string]string) error
+}
+
+// all holds every migration in version order
+// a released migration must never be changed, new changes go in a new migration at the end
+var all = []Migration{
+	clientEmailIndex,
+	tokenIndexes,
+	tokenExpiryBackfill,
+	auditIndexes,
+	jobIndexes,
+	loginIndexes,
+	rateLimitIndexes,
+	idempotencyKeyIndexes,
+	clientVersionBackfill,
+	emailChangeIndexes,
+	phoneNumberBackfill,
+	phoneCodeIndexes,
+	structuredAddressBackfill,
+	businessTypeSeed,
+	organizationBackfill,
+	invitationIndexes,
+	tenantBackfill,
+	revokeLinkIndexes,
+	phoneCodeSendWindowIndexes,
+	clientBusinessProfileRemoval,
+	tenantOwnedBackfill,
+	magicLinkTenantBackfill,
+}
+
+// All returns every migration in version order
+func All() []Migration {
+	return all
+}
+
+// indexNotFoundCode is the error code the database returns when dropping an index that does not exist
+const indexNotFoundCode = 27
+
+// createIndexes creates the indexes on the collection
+// creating an index that already exists with the same keys and options does nothing
+func createIndexes(ctx context.Context, db *mongo.Database, collection string, models ...mongo.IndexModel) error {
+	if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("failed to create %s indexes: %w", collection, err)
+	}
+	return nil
+}
+
+// dropIndexes drops the named indexes from the collection, ignoring the ones that do not exist
+func dropIndexes(ctx context.Context, db *mongo.Database, collection string, names ...string) error {
+	for _, name := range names {
+		_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Code == indexNotFoundCode {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to drop %s index %s: %w", collection, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const schemaMigrationCollectionName = "schema_migrations"
+
+// lockId is the id of the schema_migrations document that only one replica can hold at a time
+const lockId = "lock"
+
+// lockLease is how long the lock is held for without being renewed, so a replica that died
+// while migrating does not keep the others waiting forever
+const lockLease = 10 * time.Minute
+
+// lockPollInterval is how long a replica waits before trying to take a held lock again
+const lockPollInterval = time.Second
+
+// appliedMigration is the record of an applied migration in the schema_migrations collection
+type appliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// migrationLock is the lock document in the schema_migrations collection
+type migrationLock struct {
+	Id          string    `bson:"_id"`
+	Owner       string    `bson:"owner"`
+	LockedUntil time.Time `bson:"locked_until"`
+}
+
+// Status is whether a migration has been applied
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back the migrations, recording which ones are applied in the schema_migrations collection
+type Migrator struct {
+	db         *mongo.Database
+	cfg        *map[string]string
+	c          *mongo.Collection
+	migrations []Migration
+	owner      string
+}
+
+// NewMigrator returns a migrator for every migration, which are run with the config
+func NewMigrator(db *mongo.Database, cfg *map[string]string) *Migrator {
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		cfg:        cfg,
+		c:          db.Collection(schemaMigrationCollectionName),
+		migrations: All(),
+		owner:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// Up applies every pending migration up to and including the target version, or all of them if the target is 0
+// it returns the migrations it applied
+// replicas that start at the same time wait for the one that holds the lock, then find nothing left to apply
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %d: %s\n", migration.Version, migration.Name)
+		if err = migration.Up(ctx, m.db, m.cfg); err != nil {
+			return done, fmt.Errorf("failed to apply migration %d %s: %v", migration.Version, migration.Name, err)
+		}
+
+		record := &appliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+		if _, err = m.c.InsertOne(ctx, record); err != nil {
+			return done, fmt.Errorf("failed to record migration %d %s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+
+		if err = m.lock(ctx); err != nil {
+			return done, err
+		}
+	}
+
+	return done, nil
+}
+
+// Down rolls back the given number of the most recently applied migrations, newest first
+// it returns the migrations it rolled back, and stops at a migration that cannot be undone
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if migration.Down == nil {
+			return done, fmt.Errorf("migration %d %s cannot be rolled back", migration.Version, migration.Name)
+		}
+
+		log.Printf("Rolling back migration %d: %s\n", migration.Version, migration.Name)
+		if err = migration.Down(ctx, m.db, m.cfg); err != nil {
+			return done, fmt.Errorf("failed to roll back migration %d %s: %v", migration.Version, migration.Name, err)
+		}
+
+		if _, err = m.c.DeleteOne(ctx, bson.D{{Key: "_id", Value: migration.Version}}); err != nil {
+			return done, fmt.Errorf("failed to remove the record of migration %d %s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+
+		if err = m.lock(ctx); err != nil {
+			return done, err
+		}
+	}
+
+	return done, nil
+}
+
+// Status returns whether each migration has been applied, in version order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet, in version order
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// applied returns the records of the applied migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	cursor, err := m.c.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$ne", Value: lockId}}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find applied migrations: %w", err)
+	}
+
+	var records []appliedMigration
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode applied migrations: %w", err)
+	}
+
+	applied := make(map[int]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// lock takes or renews the migration lock, waiting until the replica that holds it finishes or its lease runs out
+func (m *Migrator) lock(ctx context.Context) error {
+	for {
+		now := time.Now()
+		filter := bson.D{
+			{Key: "_id", Value: lockId},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "owner", Value: m.owner}},
+				bson.D{{Key: "locked_until", Value: bson.D{{Key: "$lt", Value: now}}}},
+			}},
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "owner", Value: m.owner},
+			{Key: "locked_until", Value: now.Add(lockLease)},
+		}}}
+
+		// the upsert fails on the lock's id when another replica holds it
+		_, err := m.c.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+
+		holder := &migrationLock{}
+		if err = m.c.FindOne(ctx, bson.D{{Key: "_id", Value: lockId}}).Decode(holder); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("failed to find the migration lock: %w", err)
+		}
+		log.Printf("Waiting for %s to finish migrating...\n", holder.Owner)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the migration lock: %w", ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// unlock releases the migration lock if this migrator still holds it
+func (m *Migrator) unlock() {
+	_, err := m.c.DeleteOne(context.Background(), bson.D{{Key: "_id", Value: lockId}, {Key: "owner", Value: m.owner}})
+	if err != nil {
+		log.Printf("Error releasing the migration lock. Error: %v\n", err)
+	}
+}
@@ -0,0 +1,145 @@
+package dao
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// Address is a postal address
+// the country is an ISO 3166-1 alpha-2 code, e.g. `GB`, and the postal code follows the format of the country
+// an address that was parsed from a single line of text on a best-effort basis may only have lines
+type Address struct {
+	Lines      []string `json:"lines" bson:"lines"`
+	Locality   string   `json:"locality" bson:"locality"`
+	Region     string   `json:"region,omitempty" bson:"region,omitempty"`
+	PostalCode string   `json:"postal_code,omitempty" bson:"postal_code,omitempty"`
+	Country    string   `json:"country" bson:"country"`
+}
+
+// ParseAddress reads an address written as a single line of text, e.g. `1 High Street, London SW1A 1AA, UK`
+// the parts are separated by commas or new lines, and are read from the end: the country, the postal code,
+// the region and the locality, whatever is left is the address lines
+// parsing stops at the first part it does not recognise, and without a country the whole text is kept as lines
+func ParseAddress(text string) Address {
+	var parts []string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	var address Address
+	if len(parts) < 2 {
+		address.Lines = parts
+		return address
+	}
+
+	country, ok := utils.CountryFromName(parts[len(parts)-1])
+	if !ok {
+		address.Lines = parts
+		return address
+	}
+	address.Country = country
+	parts = parts[:len(parts)-1]
+
+	// the postal code is either a part of its own, the end of a part, e.g. `London SW1A 1AA` or `IL 62704`,
+	// or the start of a part, e.g. `75001 Paris`
+	last := parts[len(parts)-1]
+	words := strings.Fields(last)
+	if utils.MatchesPostalCode(country, last) {
+		address.PostalCode = utils.NormalizePostalCode(last)
+		parts = parts[:len(parts)-1]
+	} else {
+		for n := 1; n <= 2 && n < len(words); n++ {
+			postalCode := strings.Join(words[:n], " ")
+			if !utils.MatchesPostalCode(country, postalCode) {
+				continue
+			}
+
+			address.PostalCode = utils.NormalizePostalCode(postalCode)
+			address.Locality = strings.Join(words[n:], " ")
+			parts = parts[:len(parts)-1]
+			break
+		}
+
+		for n := 1; n <= 2 && n < len(words) && address.PostalCode == ""; n++ {
+			postalCode := strings.Join(words[len(words)-n:], " ")
+			if !utils.MatchesPostalCode(country, postalCode) {
+				continue
+			}
+
+			address.PostalCode = utils.NormalizePostalCode(postalCode)
+			rest := strings.Join(words[:len(words)-n], " ")
+			// a short upper case code before the postal code is a region, e.g. a US state
+			if len(rest) <= 3 && rest == strings.ToUpper(rest) {
+				address.Region = rest
+			} else {
+				address.Locality = rest
+			}
+			parts = parts[:len(parts)-1]
+			break
+		}
+	}
+
+	if address.Locality == "" && len(parts) > 1 {
+		address.Locality = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	address.Lines = parts
+
+	return address
+}
+
+// String writes the address as a single line of text
+func (a Address) String() string {
+	parts := append([]string{}, a.Lines...)
+	if a.Locality != "" {
+		parts = append(parts, a.Locality)
+	}
+	if region := strings.TrimSpace(a.Region + " " + a.PostalCode); region != "" {
+		parts = append(parts, region)
+	}
+	if a.Country != "" {
+		parts = append(parts, a.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Equal reports whether the addresses are the same
+func (a Address) Equal(other Address) bool {
+	if len(a.Lines) != len(other.Lines) {
+		return false
+	}
+	for i := range a.Lines {
+		if a.Lines[i] != other.Lines[i] {
+			return false
+		}
+	}
+	return a.Locality == other.Locality && a.Region == other.Region &&
+		a.PostalCode == other.PostalCode && a.Country == other.Country
+}
+
+// UnmarshalBSONValue reads an address stored as a document,
+// or as the single line of text addresses were stored as before they were structured
+func (a *Address) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.Null {
+		*a = Address{}
+		return nil
+	}
+
+	if t == bsontype.String {
+		var text string
+		if err := bson.UnmarshalValue(t, data, &text); err != nil {
+			return err
+		}
+		*a = ParseAddress(text)
+		return nil
+	}
+
+	type address Address
+	return bson.Unmarshal(data, (*address)(a))
+}
@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditCheckpoint is the audit checkpoint data access object
+// it is a signed statement of the hash of the audit chain at a sequence number,
+// so the chain up to that point can be checked against a copy kept outside the database
+type AuditCheckpoint struct {
+	Id        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Sequence  int64              `json:"sequence" bson:"sequence"`
+	Hash      string             `json:"hash" bson:"hash"`
+	PublicKey string             `json:"public_key" bson:"public_key"`
+	Signature string             `json:"signature" bson:"signature"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// NewAuditCheckpoint creates a new unsigned checkpoint of the audit event
+func NewAuditCheckpoint(event *AuditEvent) *AuditCheckpoint {
+	return &AuditCheckpoint{
+		Sequence:  event.Sequence,
+		Hash:      event.Hash,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+}
+
+// Message returns the bytes that the checkpoint signature covers
+func (ac *AuditCheckpoint) Message() []byte {
+	return []byte(fmt.Sprintf("audit-checkpoint:%d:%s:%s", ac.Sequence, ac.Hash, ac.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
@@ -0,0 +1,155 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// audit event types
+const (
+	AuditSignup               = "client.signup"
+	AuditSignupReverted       = "client.signup_reverted"
+	AuditLoginSuccess         = "login.success"
+	AuditLoginFailure         = "login.failure"
+	AuditLoginLocked          = "login.locked"
+	AuditLoginUnlocked        = "login.unlocked"
+	AuditLoginAlertSent       = "login.alert_sent"
+	AuditMagicLinkSent        = "magic_link.sent"
+	AuditProfileUpdated       = "profile.updated"
+	AuditEmailChangeRequested = "email_change.requested"
+	AuditEmailChangeConfirmed = "email_change.confirmed"
+	AuditEmailChangeCancelled = "email_change.cancelled"
+	AuditPhoneCodeSent        = "phone.code_sent"
+	AuditPhoneVerified        = "phone.verified"
+	AuditPasswordChanged      = "password.changed"
+	AuditPasswordRehashed     = "password.rehashed"
+	AuditTokenIssued          = "token.issued"
+	AuditTokenRevoked         = "token.revoked"
+	AuditBusinessTypeCreated  = "business_type.created"
+	AuditBusinessTypeUpdated  = "business_type.updated"
+	AuditBusinessTypeDeleted  = "business_type.deleted"
+	AuditOrganizationCreated  = "organization.created"
+	AuditOrganizationUpdated  = "organization.updated"
+	AuditOrganizationSwitched = "organization.switched"
+	AuditMemberRoleChanged    = "member.role_changed"
+	AuditMemberRemoved        = "member.removed"
+	AuditInvitationSent       = "invitation.sent"
+	AuditInvitationResent     = "invitation.resent"
+	AuditInvitationRevoked    = "invitation.revoked"
+	AuditInvitationAccepted   = "invitation.accepted"
+	AuditEventsPruned         = "audit.pruned"
+)
+
+// audit actor and target types
+const (
+	AuditTypeClient       = "client"
+	AuditTypeAdmin        = "admin"
+	AuditTypeAnonymous    = "anonymous"
+	AuditTypeToken        = "token"
+	AuditTypeSystem       = "system"
+	AuditTypeBusinessType = "business_type"
+	AuditTypeOrganization = "organization"
+	AuditTypeInvitation   = "invitation"
+)
+
+// AuditEvent is the audit event data access object
+// it records who did what to whom, and where the request came from
+// events form a hash chain: each one holds the hash of the event before it,
+// so an edited or deleted event breaks the chain from that point on
+type AuditEvent struct {
+	Id         primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Sequence   int64                  `json:"sequence" bson:"sequence"`
+	PrevHash   string                 `json:"prev_hash" bson:"prev_hash"`
+	Hash       string                 `json:"hash" bson:"hash"`
+	Type       string                 `json:"type" bson:"type"`
+	ActorType  string                 `json:"actor_type" bson:"actor_type"`
+	ActorId    string                 `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	ActorEmail string                 `json:"actor_email,omitempty" bson:"actor_email,omitempty"`
+	TargetType string                 `json:"target_type,omitempty" bson:"target_type,omitempty"`
+	TargetId   string                 `json:"target_id,omitempty" bson:"target_id,omitempty"`
+	IP         string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	RequestId  string                 `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	CreatedAt  time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// NewAuditEvent creates a new audit event of the type for the actor
+func NewAuditEvent(eventType, actorType, actorId, actorEmail string) *AuditEvent {
+	return &AuditEvent{
+		Type:       eventType,
+		ActorType:  actorType,
+		ActorId:    actorId,
+		ActorEmail: actorEmail,
+	}
+}
+
+// ClientAuditEvent creates a new audit event of the type where the client is both the actor and the target
+func ClientAuditEvent(eventType string, client *Client) *AuditEvent {
+	event := NewAuditEvent(eventType, AuditTypeClient, client.Id.Hex(), client.Email)
+	return event.WithTarget(AuditTypeClient, client.Id.Hex())
+}
+
+// WithTarget sets what the audit event was done to
+func (ae *AuditEvent) WithTarget(targetType, targetId string) *AuditEvent {
+	ae.TargetType = targetType
+	ae.TargetId = targetId
+	return ae
+}
+
+// With adds a metadata value to the audit event
+// values should be strings, numbers or booleans so the event hashes the same after it is read back
+func (ae *AuditEvent) With(key string, value interface{}) *AuditEvent {
+	if ae.Metadata == nil {
+		ae.Metadata = make(map[string]interface{})
+	}
+	ae.Metadata[key] = value
+	return ae
+}
+
+// auditEventContent is the part of an audit event that its hash covers
+type auditEventContent struct {
+	Sequence   int64                  `json:"sequence"`
+	PrevHash   string                 `json:"prev_hash"`
+	Type       string                 `json:"type"`
+	ActorType  string                 `json:"actor_type"`
+	ActorId    string                 `json:"actor_id"`
+	ActorEmail string                 `json:"actor_email"`
+	TargetType string                 `json:"target_type"`
+	TargetId   string                 `json:"target_id"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	RequestId  string                 `json:"request_id"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	CreatedAt  string                 `json:"created_at"`
+}
+
+// ComputeHash returns the hex SHA-256 hash of everything in the audit event except its id and hash
+// the created at time is hashed in UTC to the millisecond since that is all the database keeps
+func (ae *AuditEvent) ComputeHash() (string, error) {
+	content, err := json.Marshal(auditEventContent{
+		Sequence:   ae.Sequence,
+		PrevHash:   ae.PrevHash,
+		Type:       ae.Type,
+		ActorType:  ae.ActorType,
+		ActorId:    ae.ActorId,
+		ActorEmail: ae.ActorEmail,
+		TargetType: ae.TargetType,
+		TargetId:   ae.TargetId,
+		IP:         ae.IP,
+		UserAgent:  ae.UserAgent,
+		RequestId:  ae.RequestId,
+		Metadata:   ae.Metadata,
+		CreatedAt:  ae.CreatedAt.UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
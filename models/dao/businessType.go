@@ -0,0 +1,31 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BusinessType is the business type data access object
+// a business type is a sector, or a subcategory of a sector when it has a parent
+// clients store the name of their business type, and an inactive business type can no longer be chosen
+type BusinessType struct {
+	Id        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Name      string              `json:"name" bson:"name"`
+	ParentId  *primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Active    bool                `json:"active" bson:"active"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// NewBusinessType creates a new active business type under the parent, or a sector if the parent is nil
+func NewBusinessType(name string, parentId *primitive.ObjectID) *BusinessType {
+	now := time.Now()
+	return &BusinessType{
+		Name:      name,
+		ParentId:  parentId,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
@@ -9,34 +9,68 @@ import (
 )
 
 // Client is the client data access object
+// it only holds the client's own account, the business profile belongs to the organizations they are members of
 type Client struct {
 	Id          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	// TenantId is the tenant the client signed up with, they cannot be found or log in through another tenant
+	TenantId    string              `json:"tenant_id" bson:"tenant_id"`
 	Name    string              `json:"name" binding:"required" bson:"name"`
 	Email       string              `json:"email" binding:"required" bson:"email"`
-	Address       string              `json:"address" binding:"required" bson:"address"`
+	// PhoneNumber is stored in E.164 format, e.g. `+442079460958`
 	PhoneNumber string              `json:"phone_number" bson:"phone_number"`
+	// PhoneVerified is set once the client enters a code sent to their phone number, and cleared when it changes
+	PhoneVerified bool              `json:"phone_verified" bson:"phone_verified"`
 	Password    string              `json:"password,omitempty" binding:"required" bson:"password"`
-	BusinessType       string              `json:"business_type" binding:"required" bson:"business_type"`
-	ApiKey       string              `json:"api_key" binding:"required" bson:"api_key"`
+	PasswordHistory []string        `json:"-" bson:"password_history,omitempty"`
 	AccountActive bool              `json:"account_active" binding:"required" bson:"account_active"`
+	// Locale is the language tag that emails to the client are written in, e.g. `en` or `fr-CA`
+	Locale        string            `json:"locale,omitempty" bson:"locale,omitempty"`
+	// SessionsRevokedAt is when the client last signed out of every session, access tokens issued until then are refused
+	SessionsRevokedAt *time.Time `json:"-" bson:"sessions_revoked_at,omitempty"`
+	// Version is incremented on every profile update, updates are only applied to the version they were made against
+	Version     int64     `json:"version" bson:"version"`
 	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
 }
 
-// NewClient formats the client details and creates a new client
-func NewClient(name, email, address, password, businessType, apiKey string) *Client {
-	caser := cases.Title(language.English)
-	name = caser.String(name)
+// ClientChanges are the changed fields of a client keyed by their stored field names
+type ClientChanges map[string]interface{}
 
+// NewClient formats the client details and creates a new client
+func NewClient(name, email, password string) *Client {
 	return &Client{
-		Name: name,
+		Name: FormatName(name),
 		Email:       email,
-		Address: address,
 		Password:    password,
-		BusinessType: businessType,
-		ApiKey: apiKey,
 		AccountActive: true,
+		Version: 1,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 }
+
+// FormatName formats a client name the way it is stored
+func FormatName(name string) string {
+	return cases.Title(language.English).String(name)
+}
+
+// Changes returns the profile fields that differ in the updated client, keyed by their stored field names
+func (c *Client) Changes(updated *Client) ClientChanges {
+	changes := ClientChanges{}
+	if updated.Name != c.Name {
+		changes["name"] = updated.Name
+	}
+	if updated.Email != c.Email {
+		changes["email"] = updated.Email
+	}
+	if updated.PhoneNumber != c.PhoneNumber {
+		changes["phone_number"] = updated.PhoneNumber
+	}
+	if updated.PhoneVerified != c.PhoneVerified {
+		changes["phone_verified"] = updated.PhoneVerified
+	}
+	if updated.Locale != c.Locale {
+		changes["locale"] = updated.Locale
+	}
+	return changes
+}
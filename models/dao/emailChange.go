@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// email change statuses
+const (
+	EmailChangePending    = "pending"
+	EmailChangeConfirmed  = "confirmed"
+	EmailChangeCancelled  = "cancelled"
+	EmailChangeSuperseded = "superseded"
+	EmailChangeFailed     = "failed"
+	EmailChangeReverted   = "reverted"
+)
+
+// EmailChange is the email change data access object
+// it records a change of a client's email that waits for confirmation from the new address,
+// and that the old address can cancel until it expires, even after it was confirmed
+// only the hashes of the confirm and cancel tokens are stored, so the stored change cannot be used to act on it
+// it is removed by a TTL index once it expires, and its links only work through the tenant of the client
+type EmailChange struct {
+	Id               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantId         string             `json:"tenant_id" bson:"tenant_id"`
+	ClientId         primitive.ObjectID `json:"client_id" bson:"client_id"`
+	OldEmail         string             `json:"old_email" bson:"old_email"`
+	NewEmail         string             `json:"new_email" bson:"new_email"`
+	ClientVersion    int64              `json:"client_version" bson:"client_version"`
+	ConfirmTokenHash string             `json:"-" bson:"confirm_token_hash"`
+	CancelTokenHash  string             `json:"-" bson:"cancel_token_hash"`
+	Status           string             `json:"status" bson:"status"`
+	ExpiresAt        time.Time          `json:"expires_at" bson:"expires_at"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// NewEmailChange creates a new pending change of the client's email that expires after the given duration
+// the change is only made if the client is still at its current version when it is confirmed
+func NewEmailChange(client *Client, newEmail, confirmToken, cancelToken string, expiresIn time.Duration) *EmailChange {
+	now := time.Now()
+	return &EmailChange{
+		ClientId:         client.Id,
+		OldEmail:         client.Email,
+		NewEmail:         newEmail,
+		ClientVersion:    client.Version,
+		ConfirmTokenHash: HashEmailChangeToken(confirmToken),
+		CancelTokenHash:  HashEmailChangeToken(cancelToken),
+		Status:           EmailChangePending,
+		ExpiresAt:        now.Add(expiresIn),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+}
+
+// HashEmailChangeToken returns the hex encoded SHA-256 hash an email change token is stored and looked up by
+func HashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// idempotency key statuses
+const (
+	// IdempotencyProcessing is a request that is still being handled
+	IdempotencyProcessing = "processing"
+	// IdempotencyCompleted is a request whose response is stored for replaying
+	IdempotencyCompleted = "completed"
+)
+
+// IdempotencyKey is the idempotency key data access object
+// it holds the response to the first request made with an Idempotency-Key header,
+// so retries of the request get the same response instead of repeating it
+// a response holding tokens is not stored, only the client it was for, and retries issue that client new tokens
+type IdempotencyKey struct {
+	Id              string              `json:"id" bson:"_id"`
+	Fingerprint     string              `json:"fingerprint" bson:"fingerprint"`
+	Status          string              `json:"status" bson:"status"`
+	ResponseStatus  int                 `json:"response_status,omitempty" bson:"response_status,omitempty"`
+	ResponseBody    []byte              `json:"response_body,omitempty" bson:"response_body,omitempty"`
+	ResponseHeaders map[string]string   `json:"response_headers,omitempty" bson:"response_headers,omitempty"`
+	ClientId        *primitive.ObjectID `json:"client_id,omitempty" bson:"client_id,omitempty"`
+	LockedUntil     time.Time           `json:"locked_until" bson:"locked_until"`
+	ExpiresAt       time.Time           `json:"expires_at" bson:"expires_at"`
+	CreatedAt       time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// NewIdempotencyKey creates a new idempotency key for a request that is being handled
+// the request holds the key until lockedFor passes, and the key is kept until expiresIn passes
+func NewIdempotencyKey(id, fingerprint string, lockedFor, expiresIn time.Duration) *IdempotencyKey {
+	now := time.Now()
+	return &IdempotencyKey{
+		Id:          id,
+		Fingerprint: fingerprint,
+		Status:      IdempotencyProcessing,
+		LockedUntil: now.Add(lockedFor),
+		ExpiresAt:   now.Add(expiresIn),
+		CreatedAt:   now,
+	}
+}
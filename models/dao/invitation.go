@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// invitation statuses
+const (
+	// InvitationPending is an invitation that has been sent and not accepted or revoked yet
+	InvitationPending = "pending"
+	// InvitationAccepted is an invitation that made the invitee a member of the organization
+	InvitationAccepted = "accepted"
+	// InvitationRevoked is an invitation that was withdrawn before it was accepted
+	InvitationRevoked = "revoked"
+)
+
+// Invitation is the invitation data access object
+// it records an invitation for an email to join an organization with a role
+// the link sent for it carries the token id, which changes when the invitation is resent so older links stop working
+// it belongs to the tenant of the organization, and can only be found and accepted through it
+type Invitation struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantId       string             `json:"tenant_id" bson:"tenant_id"`
+	OrganizationId primitive.ObjectID `json:"organization_id" bson:"organization_id"`
+	Email          string             `json:"email" bson:"email"`
+	Role           Role               `json:"role" bson:"role"`
+	InvitedBy      primitive.ObjectID `json:"invited_by" bson:"invited_by"`
+	TokenId        string             `json:"-" bson:"token_id"`
+	Status         string             `json:"status" bson:"status"`
+	ExpiresAt      time.Time          `json:"expires_at" bson:"expires_at"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// NewInvitation creates a new pending invitation for the email that expires after the given duration
+func NewInvitation(organizationId primitive.ObjectID, email string, role Role, invitedBy primitive.ObjectID, tokenId string, expiresIn time.Duration) *Invitation {
+	now := time.Now()
+	return &Invitation{
+		OrganizationId: organizationId,
+		Email:          email,
+		Role:           role,
+		InvitedBy:      invitedBy,
+		TokenId:        tokenId,
+		Status:         InvitationPending,
+		ExpiresAt:      now.Add(expiresIn),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Expired reports whether the invitation can no longer be accepted because it is too old
+func (i *Invitation) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// job statuses
+const (
+	// JobPending is a job waiting for its run time or for a retry
+	JobPending = "pending"
+	// JobRunning is a job claimed by a worker, which it holds until its lease runs out
+	JobRunning = "running"
+	// JobDone is a job that ran successfully
+	JobDone = "done"
+	// JobDead is a job that failed on every attempt and will not be run again
+	JobDead = "dead"
+)
+
+// Job is the job data access object
+// it is a unit of background work that is run at least once by a worker
+type Job struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Type        string             `json:"type" bson:"type"`
+	Payload     bson.Raw           `json:"-" bson:"payload"`
+	Status      string             `json:"status" bson:"status"`
+	Attempts    int                `json:"attempts" bson:"attempts"`
+	MaxAttempts int                `json:"max_attempts" bson:"max_attempts"`
+	RunAt       time.Time          `json:"run_at" bson:"run_at"`
+	LockedBy    string             `json:"locked_by,omitempty" bson:"locked_by,omitempty"`
+	LockedUntil time.Time          `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	LastError   string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// NewJob creates a new pending job of the type that runs at the given time
+func NewJob(jobType string, payload interface{}, runAt time.Time, maxAttempts int) (*Job, error) {
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Job{
+		Type:        jobType,
+		Payload:     raw,
+		Status:      JobPending,
+		MaxAttempts: maxAttempts,
+		RunAt:       runAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// DecodePayload decodes the job's payload into v
+func (j *Job) DecodePayload(v interface{}) error {
+	return bson.Unmarshal(j.Payload, v)
+}
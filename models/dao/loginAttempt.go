@@ -0,0 +1,28 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt is the login attempt data access object
+// it tracks failed logins for a single key, such as an email or a source IP
+type LoginAttempt struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Key           string             `json:"key" bson:"key"`
+	Failures      int                `json:"failures" bson:"failures"`
+	LastFailureAt time.Time          `json:"last_failure_at" bson:"last_failure_at"`
+	LockedUntil   time.Time          `json:"locked_until" bson:"locked_until"`
+}
+
+// EmailAttemptKey returns the login attempt key for an email in a tenant
+// the same email can belong to a client of each tenant, and failures in one tenant do not lock the others out
+func EmailAttemptKey(tenantId, email string) string {
+	return "email:" + tenantId + ":" + email
+}
+
+// IPAttemptKey returns the login attempt key for a source IP
+func IPAttemptKey(ip string) string {
+	return "ip:" + ip
+}
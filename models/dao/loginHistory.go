@@ -0,0 +1,48 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// login methods recorded in the login history
+const (
+	LoginMethodPassword     = "password"
+	LoginMethodMagicLink    = "magic_link"
+	LoginMethodRefreshToken = "refresh_token"
+)
+
+// MFAMethodNone is the mfa method recorded for a sign-in that did not use a second factor
+const MFAMethodNone = "none"
+
+// LoginHistoryEntry is the login history data access object
+// it records a single sign-in, or failed sign-in, to a client's account
+type LoginHistoryEntry struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ClientId      primitive.ObjectID `json:"-" bson:"client_id"`
+	Method        string             `json:"method" bson:"method"`
+	MFAMethod     string             `json:"mfa_method" bson:"mfa_method"`
+	Success       bool               `json:"success" bson:"success"`
+	FailureReason string             `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	IP            string             `json:"ip" bson:"ip"`
+	UserAgent     string             `json:"user_agent" bson:"user_agent"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// NewLoginHistoryEntry creates a new login history entry for the client
+func NewLoginHistoryEntry(clientId primitive.ObjectID, method string, success bool) *LoginHistoryEntry {
+	return &LoginHistoryEntry{
+		ClientId:  clientId,
+		Method:    method,
+		MFAMethod: MFAMethodNone,
+		Success:   success,
+	}
+}
+
+// Failed marks the login history entry as a failed sign-in for the reason
+func (lhe *LoginHistoryEntry) Failed(reason string) *LoginHistoryEntry {
+	lhe.Success = false
+	lhe.FailureReason = reason
+	return lhe
+}
@@ -0,0 +1,31 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MagicLink is the magic link data access object
+// it records a request for a passwordless login link to an email, the link is only sent if the email belongs to a client
+// it belongs to the tenant it was requested through, and is only counted and consumed through that tenant
+type MagicLink struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantId  string             `json:"tenant_id" bson:"tenant_id"`
+	Email     string             `json:"email" bson:"email"`
+	TokenId   string             `json:"token_id" bson:"token_id"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at" bson:"used_at"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// NewMagicLink creates a new magic link for the email that expires after the given duration
+func NewMagicLink(email, tokenId string, expiresIn time.Duration) *MagicLink {
+	now := time.Now()
+	return &MagicLink{
+		Email:     email,
+		TokenId:   tokenId,
+		ExpiresAt: now.Add(expiresIn),
+		CreatedAt: now,
+	}
+}
@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization is the organization data access object
+// it owns the business profile, which clients share by being members of the organization
+// it belongs to the tenant of the client that created it, and only that tenant's clients can be its members
+type Organization struct {
+	Id           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantId     string             `json:"tenant_id" bson:"tenant_id"`
+	Name         string             `json:"name" bson:"name"`
+	Address      Address            `json:"address" bson:"address"`
+	BusinessType string             `json:"business_type" bson:"business_type"`
+	ApiKey       string             `json:"api_key,omitempty" bson:"api_key"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// NewOrganization formats the business profile and creates a new organization
+func NewOrganization(name string, address Address, businessType, apiKey string) *Organization {
+	now := time.Now()
+	return &Organization{
+		Name:         FormatName(name),
+		Address:      address,
+		BusinessType: businessType,
+		ApiKey:       apiKey,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// Role is what a member is allowed to do in an organization
+type Role string
+
+const (
+	// RoleOwner can do everything, including managing the other owners
+	RoleOwner Role = "owner"
+	// RoleAdmin can edit the business profile and manage the members that are not owners
+	RoleAdmin Role = "admin"
+	// RoleMember can read the business profile and the members
+	RoleMember Role = "member"
+)
+
+// roleRanks orders the roles so a role includes everything the roles ranked below it can do
+var roleRanks = map[Role]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+// Valid checks if the role is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// AtLeast reports whether the role can do everything the other role can
+func (r Role) AtLeast(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+// Membership is the membership data access object
+// it is what makes a client a member of an organization, with the role they have in it
+// it belongs to the tenant of the organization and the client
+type Membership struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantId       string             `json:"tenant_id" bson:"tenant_id"`
+	OrganizationId primitive.ObjectID `json:"organization_id" bson:"organization_id"`
+	ClientId       primitive.ObjectID `json:"client_id" bson:"client_id"`
+	Role           Role               `json:"role" bson:"role"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// NewMembership creates a new membership of the client in the organization
+func NewMembership(organizationId, clientId primitive.ObjectID, role Role) *Membership {
+	now := time.Now()
+	return &Membership{
+		OrganizationId: organizationId,
+		ClientId:       clientId,
+		Role:           role,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
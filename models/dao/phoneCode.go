@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// phone code purposes
+const (
+	// PhoneCodeVerify is a code that proves the client has the phone number on their profile
+	PhoneCodeVerify = "verify"
+)
+
+// PhoneCode is the phone code data access object
+// it records the one-time code last sent to a client's phone for a purpose, a newer code replaces it,
+// and how many codes were sent in the current send window
+// only a hash of the code is stored, and it is removed by a TTL index once both it and its send window have ended
+// it belongs to the tenant of the client it was sent to
+type PhoneCode struct {
+	Id              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantId        string             `json:"tenant_id" bson:"tenant_id"`
+	ClientId        primitive.ObjectID `json:"client_id" bson:"client_id"`
+	Purpose         string             `json:"purpose" bson:"purpose"`
+	PhoneNumber     string             `json:"phone_number" bson:"phone_number"`
+	CodeHash        string             `json:"-" bson:"code_hash"`
+	Attempts        int                `json:"attempts" bson:"attempts"`
+	SentCount       int                `json:"sent_count" bson:"sent_count"`
+	WindowStartedAt time.Time          `json:"window_started_at" bson:"window_started_at"`
+	ExpiresAt       time.Time          `json:"expires_at" bson:"expires_at"`
+	PurgeAt         time.Time          `json:"purge_at" bson:"purge_at"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// NewPhoneCode creates a new code for the purpose sent to the client's phone number that expires after the given duration
+// it is the first code of a send window that lasts for the window duration
+func NewPhoneCode(client *Client, purpose, code string, expiresIn, window time.Duration) *PhoneCode {
+	now := time.Now()
+	pc := &PhoneCode{
+		ClientId:        client.Id,
+		Purpose:         purpose,
+		PhoneNumber:     client.PhoneNumber,
+		CodeHash:        HashPhoneCode(client.Id, purpose, code),
+		SentCount:       1,
+		WindowStartedAt: now,
+		ExpiresAt:       now.Add(expiresIn),
+		CreatedAt:       now,
+	}
+	pc.PurgeAt = laterTime(pc.ExpiresAt, now.Add(window))
+	return pc
+}
+
+// ContinueWindow counts the code as sent in the send window of the code sent before it, if that window has not ended
+func (pc *PhoneCode) ContinueWindow(previous *PhoneCode, window time.Duration) {
+	windowEndsAt := previous.WindowStartedAt.Add(window)
+	if !pc.CreatedAt.Before(windowEndsAt) {
+		return
+	}
+	pc.SentCount = previous.SentCount + 1
+	pc.WindowStartedAt = previous.WindowStartedAt
+	pc.PurgeAt = laterTime(pc.ExpiresAt, windowEndsAt)
+}
+
+// Expired reports whether the code can no longer be used
+func (pc *PhoneCode) Expired() bool {
+	return !time.Now().Before(pc.ExpiresAt)
+}
+
+// laterTime returns the later of two times
+func laterTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// HashPhoneCode returns the hex encoded SHA-256 hash a phone code is stored as
+// the client and purpose are hashed with the code so the same code hashes differently for each of them
+func HashPhoneCode(clientId primitive.ObjectID, purpose, code string) string {
+	sum := sha256.Sum256([]byte(clientId.Hex() + ":" + purpose + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevokeLink is the revoke link data access object
+// it records a "this wasn't me" link sent in a login alert, so the link can only be used once
+type RevokeLink struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ClientId  primitive.ObjectID `json:"client_id" bson:"client_id"`
+	TokenId   string             `json:"token_id" bson:"token_id"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at" bson:"used_at"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// NewRevokeLink creates a new revoke link for the client that expires after the given duration
+func NewRevokeLink(clientId primitive.ObjectID, tokenId string, expiresIn time.Duration) *RevokeLink {
+	now := time.Now()
+	return &RevokeLink{
+		ClientId:  clientId,
+		TokenId:   tokenId,
+		ExpiresAt: now.Add(expiresIn),
+		CreatedAt: now,
+	}
+}
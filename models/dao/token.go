@@ -1,14 +1,22 @@
 package dao
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Token is the token data access object
+// it is removed by a TTL index once its refresh token expires
 type Token struct {
-	Id           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	ClientId       primitive.ObjectID  `json:"client_id" bson:"client_id"`
-	AccessToken  string              `json:"access_token" bson:"access_token"`
-	RefreshToken string              `json:"refresh_token" bson:"refresh_token"`
-	CreatedAt    primitive.Timestamp `json:"created_at" bson:"created_at"`
+	Id       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ClientId primitive.ObjectID `json:"client_id" bson:"client_id"`
+	// TenantId is the tenant the tokens were issued by
+	TenantId string `json:"tenant_id" bson:"tenant_id"`
+	// OrganizationId is the organization the tokens are for, if the client is a member of any
+	OrganizationId *primitive.ObjectID `json:"organization_id,omitempty" bson:"organization_id,omitempty"`
+	AccessToken    string              `json:"access_token" bson:"access_token"`
+	RefreshToken   string              `json:"refresh_token" bson:"refresh_token"`
+	CreatedAt      time.Time           `json:"created_at" bson:"created_at"`
+	ExpiresAt      time.Time           `json:"expires_at" bson:"expires_at"`
 }
@@ -0,0 +1,213 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// Address is a postal address sent by a client
+// it is sent as an object with the address fields, or as a single string the way addresses were sent
+// before they were structured, which is parsed on a best-effort basis
+type Address struct {
+	dao.Address
+	// text is set when the address was sent as a single string
+	text bool
+}
+
+// UnmarshalJSON reads an address sent as an object or as a single string
+func (a *Address) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*a = Address{Address: dao.ParseAddress(text), text: true}
+		return nil
+	}
+
+	var address dao.Address
+	if err := json.Unmarshal(data, &address); err != nil {
+		return fmt.Errorf("address must be a string or an object")
+	}
+	*a = Address{Address: address}
+	return nil
+}
+
+// Normalized returns the address with its fields trimmed and the country and postal code in upper case
+func (a Address) Normalized() dao.Address {
+	var lines []string
+	for _, line := range a.Lines {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return dao.Address{
+		Lines:      lines,
+		Locality:   strings.TrimSpace(a.Locality),
+		Region:     strings.TrimSpace(a.Region),
+		PostalCode: utils.NormalizePostalCode(a.PostalCode),
+		Country:    strings.ToUpper(strings.TrimSpace(a.Country)),
+	}
+}
+
+// Validate checks that the address has the fields a postal address needs,
+// and that the postal code matches the format of the country
+// an address sent as a single string only needs a line, since the parts it was not parsed into are unknown
+func (a Address) Validate() []error {
+	address := a.Normalized()
+	var errs []error
+
+	if len(address.Lines) == 0 {
+		errs = append(errs, fmt.Errorf("address needs at least one line"))
+	}
+
+	if !a.text {
+		utils.ShouldBePresentString(address.Locality, "address locality", &errs)
+		utils.ShouldBePresentString(address.Country, "address country", &errs)
+	}
+
+	if address.Country != "" {
+		if !utils.IsCountryCode(address.Country) {
+			errs = append(errs, fmt.Errorf("address country must be an ISO 3166-1 alpha-2 code"))
+		} else if err := utils.ValidatePostalCode(address.Country, address.PostalCode); err != nil && (!a.text || address.PostalCode != "") {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// AddressPatch holds the address fields to change, a nil field is left as it is
+// it is read from the address member of a JSON merge patch, which is merged into the current address when it is an object,
+// or replaces it when it is a string
+type AddressPatch struct {
+	Replace    *Address
+	Lines      *[]string
+	Locality   *string
+	Region     *string
+	PostalCode *string
+	Country    *string
+}
+
+// UnmarshalJSON reads the address member of a JSON merge patch
+func (ap *AddressPatch) UnmarshalJSON(data []byte) error {
+	patch, errs := parseAddressPatch(data)
+	if len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		return fmt.Errorf("%s", strings.Join(messages, ", "))
+	}
+	*ap = *patch
+	return nil
+}
+
+// addressPatchRemovable are the address fields a merge patch can remove with a null member
+var addressPatchRemovable = map[string]bool{
+	"region":      true,
+	"postal_code": true,
+}
+
+// parseAddressPatch reads the address member of a JSON merge patch
+func parseAddressPatch(raw json.RawMessage) (*AddressPatch, []error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte(`{`)) {
+		var address Address
+		if err := json.Unmarshal(raw, &address); err != nil {
+			return nil, []error{fmt.Errorf("address must be a string or an object")}
+		}
+		return &AddressPatch{Replace: &address}, nil
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, []error{fmt.Errorf("address must be a string or an object")}
+	}
+
+	// go through the members in order so the errors always come out the same
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	patch := &AddressPatch{}
+	for _, name := range names {
+		raw := members[name]
+
+		if name == "lines" {
+			var lines []string
+			if err := json.Unmarshal(raw, &lines); err != nil || lines == nil {
+				errs = append(errs, fmt.Errorf("address lines must be a list of strings"))
+				continue
+			}
+			patch.Lines = &lines
+			continue
+		}
+
+		var value *string
+		if string(raw) == "null" {
+			if !addressPatchRemovable[name] {
+				errs = append(errs, fmt.Errorf("address %s cannot be removed", name))
+				continue
+			}
+			empty := ""
+			value = &empty
+		} else {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				errs = append(errs, fmt.Errorf("address %s must be a string", name))
+				continue
+			}
+			value = &s
+		}
+
+		switch name {
+		case "locality":
+			patch.Locality = value
+		case "region":
+			patch.Region = value
+		case "postal_code":
+			patch.PostalCode = value
+		case "country":
+			patch.Country = value
+		default:
+			errs = append(errs, fmt.Errorf("address %s cannot be changed", name))
+		}
+	}
+
+	return patch, errs
+}
+
+// Apply returns the address with the patch merged into it
+// whether the result is valid can only be told once the patch is merged, since the postal code depends on the country
+func (ap *AddressPatch) Apply(address dao.Address) Address {
+	if ap.Replace != nil {
+		return *ap.Replace
+	}
+
+	if ap.Lines != nil {
+		address.Lines = *ap.Lines
+	}
+	if ap.Locality != nil {
+		address.Locality = *ap.Locality
+	}
+	if ap.Region != nil {
+		address.Region = *ap.Region
+	}
+	if ap.PostalCode != nil {
+		address.PostalCode = *ap.PostalCode
+	}
+	if ap.Country != nil {
+		address.Country = *ap.Country
+	}
+	return Address{Address: address}
+}
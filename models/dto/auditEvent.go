@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuditEventQuery holds the filters for listing audit events
+type AuditEventQuery struct {
+	Pagination
+	Type      string    `form:"type"`
+	ActorId   string    `form:"actor_id"`
+	TargetId  string    `form:"target_id"`
+	IP        string    `form:"ip"`
+	RequestId string    `form:"request_id"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// Validate validates an incoming audit event query
+func (aeq *AuditEventQuery) Validate() []error {
+	errs := aeq.Pagination.Validate()
+
+	if !aeq.From.IsZero() && !aeq.To.IsZero() && aeq.To.Before(aeq.From) {
+		errs = append(errs, fmt.Errorf("to must not be before from"))
+	}
+
+	return errs
+}
+
+// AuditChainBreak describes a place where the audit chain does not hold
+type AuditChainBreak struct {
+	Sequence int64  `json:"sequence"`
+	Reason   string `json:"reason"`
+}
+
+// AuditVerification holds the result of walking the audit chain and checking its checkpoints
+type AuditVerification struct {
+	EventsChecked      int64             `json:"events_checked"`
+	CheckpointsChecked int               `json:"checkpoints_checked"`
+	PrunedUpTo         int64             `json:"pruned_up_to,omitempty"`
+	Breaks             []AuditChainBreak `json:"breaks"`
+}
+
+// Valid checks if the verification found no breaks
+func (av *AuditVerification) Valid() bool {
+	return len(av.Breaks) == 0
+}
+
+// AddBreak records a break in the audit chain at the sequence number
+func (av *AuditVerification) AddBreak(sequence int64, format string, args ...interface{}) {
+	av.Breaks = append(av.Breaks, AuditChainBreak{Sequence: sequence, Reason: fmt.Sprintf(format, args...)})
+}
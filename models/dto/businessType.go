@@ -1,26 +1,80 @@
 package dto
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
 
-// BusinessType is a custom enum type
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// BusinessType is the name of a business type a client chooses
+// the business types are stored, so whether one can be chosen is checked by the business type service
 type BusinessType string
 
-var (
-	SoftwareEngineering BusinessType = "Software Engineering"
-	Construction BusinessType = "Construction"
-	InformationTechnology BusinessType = "Information Technology"
-)
+// BusinessTypeRequest holds the data for creating a business type
+type BusinessTypeRequest struct {
+	Name string `json:"name"`
+	// ParentId is the id of the sector the business type is a subcategory of, empty for a sector
+	ParentId string `json:"parent_id"`
+}
+
+// Validate validates an incoming business type request
+func (btr *BusinessTypeRequest) Validate() []error {
+	var errs []error
+
+	if strings.TrimSpace(btr.Name) == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+
+	return errs
+}
+
+// BusinessTypeUpdate holds the business type fields to change, a nil field is left as it is
+type BusinessTypeUpdate struct {
+	Name *string `json:"name"`
+	// ParentId moves the business type under another sector, or makes it a sector when it is empty
+	ParentId *string `json:"parent_id"`
+	Active   *bool   `json:"active"`
+}
 
-var BusinessTypes []BusinessType = []BusinessType{
-	SoftwareEngineering, Construction, InformationTechnology,
+// Validate validates an incoming business type update
+func (btu *BusinessTypeUpdate) Validate() []error {
+	var errs []error
+
+	if btu.Name != nil && strings.TrimSpace(*btu.Name) == "" {
+		errs = append(errs, fmt.Errorf("name cannot be empty"))
+	}
+
+	return errs
 }
 
-// Validate checks if a business type is valid
-func (bt BusinessType) Validate() error {
-	for _, b := range BusinessTypes {
-		if b == bt {
-			return nil
+// BusinessTypeNode is a business type as it is listed, with its subcategories under it
+type BusinessTypeNode struct {
+	Id            string             `json:"id"`
+	Name          string             `json:"name"`
+	Subcategories []BusinessTypeNode `json:"subcategories,omitempty"`
+}
+
+// NewBusinessTypeTree arranges the business types into their sectors, keeping their order
+// a subcategory whose sector is not in the list is left out
+func NewBusinessTypeTree(businessTypes []dao.BusinessType) []BusinessTypeNode {
+	sectors := make([]BusinessTypeNode, 0)
+	index := make(map[string]int)
+	for _, bt := range businessTypes {
+		if bt.ParentId == nil {
+			index[bt.Id.Hex()] = len(sectors)
+			sectors = append(sectors, BusinessTypeNode{Id: bt.Id.Hex(), Name: bt.Name})
 		}
 	}
-	return fmt.Errorf("invalid business type: %v", bt)
-}
\ No newline at end of file
+
+	for _, bt := range businessTypes {
+		if bt.ParentId == nil {
+			continue
+		}
+		if i, ok := index[bt.ParentId.Hex()]; ok {
+			sectors[i].Subcategories = append(sectors[i].Subcategories, BusinessTypeNode{Id: bt.Id.Hex(), Name: bt.Name})
+		}
+	}
+
+	return sectors
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"fmt"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// ChangePasswordRequest holds the data for changing a client's password
+type ChangePasswordRequest struct {
+	CurrentPassword Password `json:"current_password"`
+	NewPassword     Password `json:"new_password"`
+	ConfirmPassword Password `json:"confirm_password"`
+}
+
+// Validate validates an incoming change password request
+func (cpr *ChangePasswordRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(string(cpr.CurrentPassword), "current password", &errs)
+	utils.ShouldBePresentString(string(cpr.NewPassword), "new password", &errs)
+	utils.ShouldBePresentString(string(cpr.ConfirmPassword), "confirmed password", &errs)
+
+	// the new password is checked against the password policy when it is changed
+	if ok := cpr.NewPassword.IsEqualValue(cpr.ConfirmPassword); !ok {
+		errs = append(errs, fmt.Errorf("passwords do not match"))
+	}
+
+	return errs
+}
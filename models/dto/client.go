@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// NewClientProfile returns the client's profile as it is shown to the client, without their password
+func NewClientProfile(client dao.Client) dao.Client {
+	return dao.Client{
+		Id:            client.Id,
+		Name:          client.Name,
+		Email:         client.Email,
+		PhoneNumber:   client.PhoneNumber,
+		PhoneVerified: client.PhoneVerified,
+		AccountActive: client.AccountActive,
+		Locale:        client.Locale,
+		CreatedAt:     client.CreatedAt,
+		UpdatedAt:     client.UpdatedAt,
+		Version:       client.Version,
+	}
+}
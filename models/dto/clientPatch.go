@@ -0,0 +1,146 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// AnyVersion is the version an update is made against when it applies to whatever the current version is
+const AnyVersion int64 = -1
+
+// ClientPatch holds the profile fields to change, a nil field is left as it is
+// it is read from an RFC 7396 JSON merge patch, where a null member removes an optional field
+type ClientPatch struct {
+	Name        *string
+	Email       *Email
+	PhoneNumber *PhoneNumber
+	Locale      *string
+}
+
+// clientPatchRemovable are the fields a merge patch can remove with a null member
+var clientPatchRemovable = map[string]bool{
+	"phone_number": true,
+	"locale":       true,
+}
+
+// organizationProfileFields are the business profile fields that clients had before organizations owned them
+var organizationProfileFields = map[string]bool{
+	"address":       true,
+	"business_type": true,
+	"api_key":       true,
+}
+
+// ParseClientPatch reads a JSON merge patch of the client profile
+func ParseClientPatch(body []byte) (*ClientPatch, []error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return nil, []error{fmt.Errorf("patch must be a JSON object")}
+	}
+
+	// go through the members in order so the errors always come out the same
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	patch := &ClientPatch{}
+	for _, name := range names {
+		raw := members[name]
+
+		if organizationProfileFields[name] {
+			errs = append(errs, fmt.Errorf("%s is part of the organization's business profile, change it on the organization", name))
+			continue
+		}
+
+		var value *string
+		if string(raw) == "null" {
+			if !clientPatchRemovable[name] {
+				errs = append(errs, fmt.Errorf("%s cannot be removed", name))
+				continue
+			}
+			empty := ""
+			value = &empty
+		} else {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a string", name))
+				continue
+			}
+			value = &s
+		}
+
+		switch name {
+		case "name":
+			patch.Name = value
+		case "email":
+			email := Email(*value)
+			patch.Email = &email
+		case "phone_number":
+			phoneNumber := PhoneNumber(*value)
+			patch.PhoneNumber = &phoneNumber
+		case "locale":
+			patch.Locale = value
+		default:
+			errs = append(errs, fmt.Errorf("%s cannot be changed", name))
+		}
+	}
+
+	return patch, errs
+}
+
+// Validate validates only the fields that are present in the patch
+func (cp *ClientPatch) Validate() []error {
+	var errs []error
+
+	if cp.Name != nil && strings.TrimSpace(*cp.Name) == "" {
+		errs = append(errs, fmt.Errorf("name cannot be empty"))
+	}
+
+	if cp.Email != nil {
+		if err := cp.Email.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("email is invalid"))
+		}
+	}
+
+	if cp.PhoneNumber != nil && *cp.PhoneNumber != "" {
+		if err := cp.PhoneNumber.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("phone number is invalid: %v", err))
+		}
+	}
+
+	if cp.Locale != nil && *cp.Locale != "" {
+		if _, err := language.Parse(*cp.Locale); err != nil {
+			errs = append(errs, fmt.Errorf("locale is invalid"))
+		}
+	}
+
+	return errs
+}
+
+// Apply sets the fields that are present in the patch on the client
+// the phone number is stored in E.164 format, and a new phone number has to be verified again
+func (cp *ClientPatch) Apply(client *dao.Client) {
+	if cp.Name != nil {
+		client.Name = dao.FormatName(*cp.Name)
+	}
+	if cp.Email != nil {
+		client.Email = string(*cp.Email)
+	}
+	if cp.PhoneNumber != nil {
+		if phoneNumber := cp.PhoneNumber.Normalized(); phoneNumber != client.PhoneNumber {
+			client.PhoneNumber = phoneNumber
+			client.PhoneVerified = false
+		}
+	}
+	if cp.Locale != nil {
+		client.Locale = *cp.Locale
+	}
+}
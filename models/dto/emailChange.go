@@ -0,0 +1,17 @@
+package dto
+
+import "github.com/leonardchinonso/auth_service_cmp7174/utils"
+
+// EmailChangeTokenRequest holds the token from an email change confirm or cancel link
+type EmailChangeTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates an incoming email change token request
+func (etr *EmailChangeTokenRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(etr.Token, "token", &errs)
+
+	return errs
+}
@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"fmt"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// InvitationRequest holds the email to invite to an organization and the role they are given when they accept
+type InvitationRequest struct {
+	Email Email    `json:"email"`
+	Role  dao.Role `json:"role"`
+}
+
+// Validate validates an incoming invitation request
+func (ir *InvitationRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(string(ir.Email), "email", &errs)
+
+	if err := ir.Email.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("email is invalid"))
+	}
+
+	// invitees join as members unless another role is given
+	if ir.Role == "" {
+		ir.Role = dao.RoleMember
+	}
+	if !ir.Role.Valid() {
+		errs = append(errs, fmt.Errorf("role must be one of %v, %v or %v", dao.RoleOwner, dao.RoleAdmin, dao.RoleMember))
+	}
+
+	return errs
+}
+
+// InvitationTokenRequest holds the token from an invitation link, to accept it with an existing account
+type InvitationTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates an incoming invitation token request
+func (itr *InvitationTokenRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(itr.Token, "token", &errs)
+
+	return errs
+}
+
+// AcceptInvitationRequest holds the token from an invitation link and the account to sign up with to accept it
+// the business profile fields of the signup request are not used, since the organization has the business profile
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+	SignupRequest
+}
+
+// Validate validates an incoming accept invitation request
+func (air *AcceptInvitationRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(air.Token, "token", &errs)
+	errs = append(errs, air.ValidateAccount()...)
+
+	return errs
+}
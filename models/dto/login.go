@@ -37,18 +37,7 @@ type LoginResponse struct {
 // NewLoginResponse returns a new LoginResponse
 func NewLoginResponse(client dao.Client, accessToken, refreshToken string) *LoginResponse {
 	return &LoginResponse{
-		Client: dao.Client{
-			Id:          client.Id,
-			Name: client.Name,
-			Email:       client.Email,
-			Address: client.Address,
-			BusinessType: client.BusinessType,
-			PhoneNumber: client.PhoneNumber,
-			AccountActive: client.AccountActive,
-			ApiKey: client.ApiKey, // TODO: ask if should return to client
-			CreatedAt: client.CreatedAt,
-			UpdatedAt: client.UpdatedAt,
-		},
+		Client:       NewClientProfile(client),
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	}
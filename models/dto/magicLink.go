@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"fmt"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// MagicLinkRequest holds the data for requesting a magic login link
+type MagicLinkRequest struct {
+	Email Email `json:"email"`
+}
+
+// Validate validates an incoming magic link request
+func (mlr *MagicLinkRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(string(mlr.Email), "email", &errs)
+
+	if err := mlr.Email.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("email is invalid"))
+	}
+
+	return errs
+}
+
+// MagicLinkVerifyRequest holds the data for exchanging a magic link for a login
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates an incoming magic link verification request
+func (mlvr *MagicLinkVerifyRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(mlvr.Token, "token", &errs)
+
+	return errs
+}
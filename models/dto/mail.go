@@ -0,0 +1,58 @@
+package dto
+
+// Mail is a rendered email ready to be delivered
+type Mail struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// MagicLinkMail holds the data for the magic link mail template
+type MagicLinkMail struct {
+	Name      string
+	Link      string
+	ExpiresIn string
+}
+
+// AccountLockedMail holds the data for the account locked mail template
+type AccountLockedMail struct {
+	Name        string
+	MaxAttempts int
+	LockedUntil string
+}
+
+// LoginAlertMail holds the data for the login alert mail template
+type LoginAlertMail struct {
+	Name       string
+	Device     string
+	IP         string
+	Time       string
+	RevokeLink string
+}
+
+// EmailChangeConfirmMail holds the data for the mail sent to the new address of an email change
+type EmailChangeConfirmMail struct {
+	Name        string
+	NewEmail    string
+	ConfirmLink string
+	ExpiresIn   string
+}
+
+// EmailChangeNoticeMail holds the data for the mail sent to the old address of an email change
+type EmailChangeNoticeMail struct {
+	Name       string
+	NewEmail   string
+	CancelLink string
+	ExpiresIn  string
+}
+
+// InvitationMail holds the data for the mail inviting someone to join an organization
+type InvitationMail struct {
+	InviterName      string
+	OrganizationName string
+	Role             string
+	Link             string
+	ExpiresIn        string
+}
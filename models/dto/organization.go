@@ -0,0 +1,146 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// OrganizationRequest holds the business profile of an organization being created
+type OrganizationRequest struct {
+	Name string `json:"name"`
+	// Address is an object with the address fields, or a single string that is parsed on a best-effort basis
+	Address      Address      `json:"address"`
+	BusinessType BusinessType `json:"business_type"`
+	ApiKey       string       `json:"api_key"`
+}
+
+// Validate validates an incoming organization request
+func (or *OrganizationRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(or.Name, "name", &errs)
+	utils.ShouldBePresentString(string(or.BusinessType), "business type", &errs)
+	utils.ShouldBePresentString(or.ApiKey, "api key", &errs)
+
+	// validate the address
+	errs = append(errs, or.Address.Validate()...)
+
+	return errs
+}
+
+// OrganizationUpdate holds the business profile fields to change, a nil field is left as it is
+// the address is merged into the current address when it is an object, or replaces it when it is a string
+type OrganizationUpdate struct {
+	Name         *string       `json:"name"`
+	Address      *AddressPatch `json:"address"`
+	BusinessType *BusinessType `json:"business_type"`
+	ApiKey       *string       `json:"api_key"`
+}
+
+// Validate validates an incoming organization update
+// the address is validated when the update is applied, since it is merged into the current address
+func (ou *OrganizationUpdate) Validate() []error {
+	var errs []error
+
+	if ou.Name != nil && strings.TrimSpace(*ou.Name) == "" {
+		errs = append(errs, fmt.Errorf("name cannot be empty"))
+	}
+	if ou.ApiKey != nil && strings.TrimSpace(*ou.ApiKey) == "" {
+		errs = append(errs, fmt.Errorf("api key cannot be empty"))
+	}
+	if ou.BusinessType != nil && strings.TrimSpace(string(*ou.BusinessType)) == "" {
+		errs = append(errs, fmt.Errorf("business type cannot be empty"))
+	}
+
+	return errs
+}
+
+// Apply applies the update to the organization and returns the names of the fields it changed,
+// or why the merged address is invalid
+func (ou *OrganizationUpdate) Apply(organization *dao.Organization) ([]string, []error) {
+	var changed []string
+
+	if ou.Name != nil {
+		if name := dao.FormatName(strings.TrimSpace(*ou.Name)); name != organization.Name {
+			organization.Name = name
+			changed = append(changed, "name")
+		}
+	}
+	if ou.Address != nil {
+		patched := ou.Address.Apply(organization.Address)
+		if errs := patched.Validate(); len(errs) > 0 {
+			return nil, errs
+		}
+		if address := patched.Normalized(); !address.Equal(organization.Address) {
+			organization.Address = address
+			changed = append(changed, "address")
+		}
+	}
+	if ou.BusinessType != nil && string(*ou.BusinessType) != organization.BusinessType {
+		organization.BusinessType = string(*ou.BusinessType)
+		changed = append(changed, "business_type")
+	}
+	if ou.ApiKey != nil && *ou.ApiKey != organization.ApiKey {
+		organization.ApiKey = *ou.ApiKey
+		changed = append(changed, "api_key")
+	}
+
+	return changed, nil
+}
+
+// RoleRequest holds the role a member is given
+type RoleRequest struct {
+	Role dao.Role `json:"role"`
+}
+
+// Validate validates an incoming role request
+func (rr *RoleRequest) Validate() []error {
+	var errs []error
+
+	if !rr.Role.Valid() {
+		errs = append(errs, fmt.Errorf("role must be one of %v, %v or %v", dao.RoleOwner, dao.RoleAdmin, dao.RoleMember))
+	}
+
+	return errs
+}
+
+// OrganizationProfile is an organization as it is shown to one of its members, with the member's role
+type OrganizationProfile struct {
+	dao.Organization
+	Role dao.Role `json:"role"`
+}
+
+// NewOrganizationProfile returns the organization as it is shown to a member with the role
+// the api key is only shown to the members that can change it
+func NewOrganizationProfile(organization dao.Organization, role dao.Role) OrganizationProfile {
+	if !role.AtLeast(dao.RoleAdmin) {
+		organization.ApiKey = ""
+	}
+	return OrganizationProfile{Organization: organization, Role: role}
+}
+
+// MemberProfile is a member of an organization as it is shown to the other members
+type MemberProfile struct {
+	ClientId primitive.ObjectID `json:"client_id"`
+	Name     string             `json:"name"`
+	Email    string             `json:"email"`
+	Role     dao.Role           `json:"role"`
+	JoinedAt time.Time          `json:"joined_at"`
+}
+
+// NewMemberProfile returns the member profile of the client with the membership
+func NewMemberProfile(client dao.Client, membership dao.Membership) MemberProfile {
+	return MemberProfile{
+		ClientId: client.Id,
+		Name:     client.Name,
+		Email:    client.Email,
+		Role:     membership.Role,
+		JoinedAt: membership.CreatedAt,
+	}
+}
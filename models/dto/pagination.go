@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// DefaultPageLimit is the page size used when none is requested
+	DefaultPageLimit = 20
+	// MaxPageLimit is the largest page size that can be requested
+	MaxPageLimit = 100
+)
+
+// Pagination holds the requested page of a list
+type Pagination struct {
+	Page  int64 `form:"page"`
+	Limit int64 `form:"limit"`
+}
+
+// Validate validates the requested page and fills in the defaults
+func (p *Pagination) Validate() []error {
+	var errs []error
+
+	if p.Page == 0 {
+		p.Page = 1
+	}
+	if p.Limit == 0 {
+		p.Limit = DefaultPageLimit
+	}
+
+	if p.Page < 1 {
+		errs = append(errs, fmt.Errorf("page must be at least 1"))
+	}
+	if p.Limit < 1 || p.Limit > MaxPageLimit {
+		errs = append(errs, fmt.Errorf("limit must be between 1 and %s", strconv.Itoa(MaxPageLimit)))
+	}
+
+	return errs
+}
+
+// Skip returns how many items come before the requested page
+func (p *Pagination) Skip() int64 {
+	return (p.Page - 1) * p.Limit
+}
+
+// PaginatedResponse holds a page of a list
+type PaginatedResponse struct {
+	Items interface{} `json:"items"`
+	Page  int64       `json:"page"`
+	Limit int64       `json:"limit"`
+	Total int64       `json:"total"`
+}
+
+// NewPaginatedResponse returns a new PaginatedResponse
+func NewPaginatedResponse(items interface{}, pagination Pagination, total int64) *PaginatedResponse {
+	return &PaginatedResponse{
+		Items: items,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	}
+}
@@ -0,0 +1,139 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// password policy rule names reported in a PasswordRuleFailure
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUpperCase    = "upper_case"
+	RuleLowerCase    = "lower_case"
+	RuleDigit        = "digit"
+	RuleSpecialChar  = "special_character"
+	RuleStrength     = "strength"
+	RulePersonalInfo = "personal_info"
+	RuleHistory      = "history"
+	RuleBreached     = "breached"
+)
+
+// PasswordPolicy holds the rules a password has to meet
+type PasswordPolicy struct {
+	MinLength          int
+	MaxLength          int
+	RequireUpperCase   bool
+	RequireLowerCase   bool
+	RequireDigit       bool
+	RequireSpecialChar bool
+	// MinStrength is the lowest acceptable strength score, from 0 (weakest) to 4 (strongest)
+	MinStrength        int
+	ForbidPersonalInfo bool
+	// HistorySize is how many previous passwords cannot be reused
+	HistorySize int
+	// MaxBytes is the longest password in bytes the password hasher can hash, 0 if it can hash any length
+	MaxBytes int
+}
+
+// PasswordRuleFailure describes a password policy rule that a password does not meet
+type PasswordRuleFailure struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error returns the message from PasswordRuleFailure
+// it fulfills the interface requirements for the standard error type
+func (prf PasswordRuleFailure) Error() string {
+	return prf.Message
+}
+
+// Evaluate checks a password against every rule in the policy and returns the rules it fails
+// personalInfo holds values such as the client's name and email that must not appear in the password
+func (pp *PasswordPolicy) Evaluate(password Password, personalInfo ...string) []PasswordRuleFailure {
+	var failures []PasswordRuleFailure
+	p := string(password)
+
+	length := utf8.RuneCountInString(p)
+	if length < pp.MinLength {
+		failures = append(failures, PasswordRuleFailure{RuleMinLength, fmt.Sprintf("password must be at least %d characters long", pp.MinLength)})
+	}
+	if pp.MaxLength > 0 && length > pp.MaxLength {
+		failures = append(failures, PasswordRuleFailure{RuleMaxLength, fmt.Sprintf("password must be at most %d characters long", pp.MaxLength)})
+	} else if pp.MaxBytes > 0 && len(p) > pp.MaxBytes {
+		failures = append(failures, PasswordRuleFailure{RuleMaxLength, fmt.Sprintf("password must be at most %d bytes long, accented letters and symbols take more than one", pp.MaxBytes)})
+	}
+
+	var hasUpperCase, hasLowerCase, hasNum, hasSpecChar bool
+	for _, c := range p {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpperCase = true
+		case unicode.IsLower(c):
+			hasLowerCase = true
+		case unicode.IsNumber(c):
+			hasNum = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			hasSpecChar = true
+		}
+	}
+
+	if pp.RequireUpperCase && !hasUpperCase {
+		failures = append(failures, PasswordRuleFailure{RuleUpperCase, "password must contain an upper case letter"})
+	}
+	if pp.RequireLowerCase && !hasLowerCase {
+		failures = append(failures, PasswordRuleFailure{RuleLowerCase, "password must contain a lower case letter"})
+	}
+	if pp.RequireDigit && !hasNum {
+		failures = append(failures, PasswordRuleFailure{RuleDigit, "password must contain a number"})
+	}
+	if pp.RequireSpecialChar && !hasSpecChar {
+		failures = append(failures, PasswordRuleFailure{RuleSpecialChar, "password must contain a special character"})
+	}
+
+	if pp.ForbidPersonalInfo && containsPersonalInfo(p, personalInfo) {
+		failures = append(failures, PasswordRuleFailure{RulePersonalInfo, "password must not contain your name or email"})
+	}
+
+	if score := PasswordStrength(p, personalInfo...); score < pp.MinStrength {
+		failures = append(failures, PasswordRuleFailure{RuleStrength, "password is too easy to guess, try a longer password or a few unrelated words"})
+	}
+
+	return failures
+}
+
+// containsPersonalInfo checks if the password contains any of the personal values
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	password = strings.ToLower(password)
+	for _, token := range personalTokens(personalInfo) {
+		if strings.Contains(password, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// personalTokens breaks personal values into the lower case words that should not appear in a password
+// only the local part of an email is used, and words shorter than three characters are ignored
+// as they match too many passwords
+func personalTokens(personalInfo []string) []string {
+	const minTokenLength = 3
+
+	var tokens []string
+	for _, info := range personalInfo {
+		if at := strings.LastIndex(info, "@"); at >= 0 {
+			info = info[:at]
+		}
+		fields := strings.FieldsFunc(strings.ToLower(info), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, f := range fields {
+			if utf8.RuneCountInString(f) >= minTokenLength {
+				tokens = append(tokens, f)
+			}
+		}
+	}
+	return tokens
+}
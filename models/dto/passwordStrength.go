@@ -0,0 +1,258 @@
+package dto
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are frequently used passwords and words, most common first
+// a password built from them is guessed long before a brute force search would find it
+var commonPasswords = []string{
+	"password", "123456", "qwerty", "abc123", "letmein", "monkey", "dragon", "111111",
+	"iloveyou", "admin", "welcome", "login", "master", "sunshine", "princess", "football",
+	"baseball", "shadow", "superman", "trustno1", "passw0rd", "starwars", "whatever",
+	"hello", "freedom", "michael", "charlie", "jennifer", "jordan", "hunter", "ranger",
+	"buster", "soccer", "hockey", "killer", "george", "summer", "winter", "spring",
+	"autumn", "secret", "access", "flower", "cookie", "pepper", "ginger", "orange",
+	"banana", "computer", "internet", "samsung", "google", "apple", "love", "test",
+	"user", "guest", "root", "pass", "money", "business", "company", "office",
+	"change", "default", "london", "england", "america", "chelsea", "arsenal",
+	"liverpool", "january", "february", "march", "april", "may", "june",
+	"july", "august", "september", "october", "november", "december", "monday",
+	"friday", "sunday", "secure", "system", "server", "service",
+}
+
+// keyboardRows are the rows of a qwerty keyboard used to find keyboard walks like `asdf`
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+}
+
+// leetSubstitutions maps the common l33t substitutions back to the letters they replace
+var leetSubstitutions = map[rune]rune{
+	'@': 'a', '4': 'a', '8': 'b', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// minPatternLength is the shortest run of characters that is treated as a pattern
+const minPatternLength = 3
+
+// PasswordStrength estimates how hard a password is to guess and scores it from 0 to 4
+// in the style of zxcvbn: the password is split into dictionary words, repeats, sequences
+// and keyboard walks, each of which is much cheaper to guess than random characters,
+// and the estimated number of guesses is mapped to a score
+// userInputs holds personal values that an attacker would try first
+func PasswordStrength(password string, userInputs ...string) int {
+	if password == "" {
+		return 0
+	}
+
+	bits := passwordEntropy(password, userInputs)
+
+	// the guess thresholds used by zxcvbn for each score
+	switch guesses := math.Pow(2, bits); {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// passwordEntropy estimates the bits of entropy in a password by greedily matching
+// the longest pattern at each position and falling back to brute force for single characters
+func passwordEntropy(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	unleeted := unleet(lower)
+	dictionary := append(personalTokens(userInputs), commonPasswords...)
+	bruteForceBits := math.Log2(float64(charsetSize(runes)))
+
+	var bits float64
+	var patterns int
+	for i := 0; i < len(runes); {
+		// personal values and common passwords, including their l33t spellings
+		if n, rank := dictionaryMatch(unleeted[i:], dictionary); n > 0 {
+			bits += math.Log2(float64(rank+1)) + capitalizationBits(runes[i:i+n]) + leetBits(lower[i:i+n], unleeted[i:i+n]) + 1
+			i += n
+			patterns++
+			continue
+		}
+
+		// years are one of a couple of hundred likely values
+		if isYear(runes[i:]) {
+			bits += math.Log2(200)
+			i += 4
+			patterns++
+			continue
+		}
+
+		// repeats, sequences and keyboard walks cost one character plus the length of the run
+		if n := patternMatch(lower[i:]); n > 0 {
+			bits += bruteForceBits + math.Log2(float64(n))
+			i += n
+			patterns++
+			continue
+		}
+
+		bits += bruteForceBits
+		i++
+		patterns++
+	}
+
+	// an attacker also has to guess how the patterns were put together
+	return bits + math.Log2(float64(patterns))
+}
+
+// dictionaryMatch returns the length and rank of the longest dictionary word at the start of the runes
+func dictionaryMatch(runes []rune, dictionary []string) (int, int) {
+	s := string(runes)
+	length, rank := 0, 0
+	for r, word := range dictionary {
+		if len([]rune(word)) > length && strings.HasPrefix(s, word) {
+			length, rank = len([]rune(word)), r
+		}
+	}
+	return length, rank
+}
+
+// unleet replaces the l33t substitutions in the runes with the letters they stand for
+func unleet(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		if l, ok := leetSubstitutions[r]; ok {
+			out[i] = l
+		} else {
+			out[i] = r
+		}
+	}
+	return out
+}
+
+// leetBits estimates the extra bits from the l33t substitutions in a dictionary word
+func leetBits(original, unleeted []rune) float64 {
+	var substitutions float64
+	for i := range original {
+		if original[i] != unleeted[i] {
+			substitutions++
+		}
+	}
+	return substitutions
+}
+
+// isYear checks if the runes start with a year between 1900 and 2099
+func isYear(runes []rune) bool {
+	if len(runes) < 4 {
+		return false
+	}
+	for _, r := range runes[:4] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	prefix := string(runes[:2])
+	return prefix == "19" || prefix == "20"
+}
+
+// patternMatch returns the length of the longest repeat, sequence or keyboard walk at the start of the runes
+func patternMatch(runes []rune) int {
+	if len(runes) < minPatternLength {
+		return 0
+	}
+
+	longest := 0
+	for _, step := range []func(a, b rune) bool{isRepeat, isSequence, isKeyboardNeighbour} {
+		n := 1
+		for n < len(runes) && step(runes[n-1], runes[n]) {
+			n++
+		}
+		if n > longest {
+			longest = n
+		}
+	}
+
+	if longest < minPatternLength {
+		return 0
+	}
+	return longest
+}
+
+// isRepeat checks if b repeats a
+func isRepeat(a, b rune) bool {
+	return a == b
+}
+
+// isSequence checks if b follows a in a sequence like `abc` or `321`
+func isSequence(a, b rune) bool {
+	return b-a == 1 || a-b == 1
+}
+
+// isKeyboardNeighbour checks if b is next to a on the same row of the keyboard
+func isKeyboardNeighbour(a, b rune) bool {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, a)
+		if i < 0 {
+			continue
+		}
+		if (i > 0 && rune(row[i-1]) == b) || (i < len(row)-1 && rune(row[i+1]) == b) {
+			return true
+		}
+	}
+	return false
+}
+
+// capitalizationBits estimates the extra bits from capitalizing a dictionary word
+func capitalizationBits(runes []rune) float64 {
+	var upper int
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case upper == 1 && unicode.IsUpper(runes[0]), upper == len(runes):
+		return 1
+	default:
+		return float64(len(runes))
+	}
+}
+
+// charsetSize returns the size of the character set a brute force search would need for the password
+func charsetSize(runes []rune) int {
+	var hasLower, hasUpper, hasDigit, hasOther bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasOther = true
+		}
+	}
+
+	size := 0
+	if hasLower {
+		size += 26
+	}
+	if hasUpper {
+		size += 26
+	}
+	if hasDigit {
+		size += 10
+	}
+	if hasOther {
+		size += 33
+	}
+	return size
+}
@@ -0,0 +1,35 @@
+package dto
+
+import "github.com/leonardchinonso/auth_service_cmp7174/utils"
+
+// PhoneNumber is a custom type for managing phone numbers
+type PhoneNumber string
+
+// Validate checks that a phone number is an international number that can be normalized to E.164
+func (p PhoneNumber) Validate() error {
+	_, err := utils.NormalizePhoneNumber(string(p))
+	return err
+}
+
+// Normalized returns the phone number in E.164 format, or as it is if it cannot be normalized
+func (p PhoneNumber) Normalized() string {
+	normalized, err := utils.NormalizePhoneNumber(string(p))
+	if err != nil {
+		return string(p)
+	}
+	return normalized
+}
+
+// PhoneCodeRequest holds the one-time code sent to a client's phone
+type PhoneCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// Validate validates an incoming phone code request
+func (pcr *PhoneCodeRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(pcr.Code, "code", &errs)
+
+	return errs
+}
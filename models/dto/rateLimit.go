@@ -0,0 +1,95 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitAlgorithm is a custom enum type for the algorithms a rate limit can use
+type RateLimitAlgorithm string
+
+var (
+	TokenBucket   RateLimitAlgorithm = "token_bucket"
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// RateLimitKey is a custom enum type for what a rate limit counts requests by
+type RateLimitKey string
+
+var (
+	RateLimitByIP    RateLimitKey = "ip"
+	RateLimitByEmail RateLimitKey = "email"
+	// RateLimitByClient counts the requests of the logged-in client, on routes that authorize the client first
+	RateLimitByClient RateLimitKey = "client"
+)
+
+// RateLimitRule holds the limit applied to a route
+type RateLimitRule struct {
+	Route     string
+	Key       RateLimitKey
+	Limit     int
+	Window    time.Duration
+	Algorithm RateLimitAlgorithm
+}
+
+// RateLimitResult holds the outcome of counting a request against a rule
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// ParseRateLimitRule parses a rule for a route from a spec in the format `key:limit/window:algorithm`
+// e.g. `ip:10/1m:sliding_window` allows ten requests a minute from an ip
+// it returns nil if the spec is empty so the route is not limited
+func ParseRateLimitRule(route, spec string) (*RateLimitRule, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid rate limit for %s: %q", route, spec)
+	}
+
+	key := RateLimitKey(parts[0])
+	switch key {
+	case RateLimitByIP, RateLimitByEmail, RateLimitByClient:
+	default:
+		return nil, fmt.Errorf("invalid rate limit key for %s: %q", route, parts[0])
+	}
+
+	limitParts := strings.Split(parts[1], "/")
+	if len(limitParts) != 2 {
+		return nil, fmt.Errorf("invalid rate limit for %s: %q", route, parts[1])
+	}
+
+	limit, err := strconv.Atoi(limitParts[0])
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("invalid rate limit count for %s: %q", route, limitParts[0])
+	}
+
+	window, err := time.ParseDuration(limitParts[1])
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit window for %s: %q", route, limitParts[1])
+	}
+
+	algorithm := RateLimitAlgorithm(parts[2])
+	switch algorithm {
+	case TokenBucket, SlidingWindow:
+	default:
+		return nil, fmt.Errorf("invalid rate limit algorithm for %s: %q", route, parts[2])
+	}
+
+	return &RateLimitRule{
+		Route:     route,
+		Key:       key,
+		Limit:     limit,
+		Window:    window,
+		Algorithm: algorithm,
+	}, nil
+}
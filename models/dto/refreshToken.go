@@ -0,0 +1,17 @@
+package dto
+
+import "github.com/leonardchinonso/auth_service_cmp7174/utils"
+
+// RefreshTokenRequest holds the data for exchanging a refresh token for a new token pair
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Validate validates an incoming refresh token request
+func (rtr *RefreshTokenRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(rtr.RefreshToken, "refresh_token", &errs)
+
+	return errs
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "context"
+
+// RequestMetaKey is the key the request metadata is stored under in the request context
+const RequestMetaKey = "request_meta"
+
+// RequestMeta holds the details about where a request came from
+type RequestMeta struct {
+	RequestId string `json:"request_id"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// RequestMetaFromContext gets the request metadata set by the request meta middleware
+// it returns an empty RequestMeta if none was set
+func RequestMetaFromContext(ctx context.Context) RequestMeta {
+	if meta, ok := ctx.Value(RequestMetaKey).(RequestMeta); ok {
+		return meta
+	}
+	return RequestMeta{}
+}
+
+// RequestDoneKey is the key the channel that is closed when the caller of a request goes away is stored under
+const RequestDoneKey = "request_done"
+
+// RequestDone returns a channel that is closed when the caller of the request goes away or the context is done
+// the request context of a handler is not cancelled when the caller goes away, so the writes a request started
+// are finished, and only work that is safe to give up waits on this channel
+// it returns nil, which is never closed, if the context does not belong to a request and cannot be done
+func RequestDone(ctx context.Context) <-chan struct{} {
+	if done := ctx.Done(); done != nil {
+		return done
+	}
+	done, _ := ctx.Value(RequestDoneKey).(<-chan struct{})
+	return done
+}
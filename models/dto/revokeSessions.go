@@ -0,0 +1,17 @@
+package dto
+
+import "github.com/leonardchinonso/auth_service_cmp7174/utils"
+
+// RevokeSessionsRequest holds the data for revoking a client's sessions from a login alert link
+type RevokeSessionsRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates an incoming revoke sessions request
+func (rsr *RevokeSessionsRequest) Validate() []error {
+	var errs []error
+
+	utils.ShouldBePresentString(rsr.Token, "token", &errs)
+
+	return errs
+}
@@ -3,6 +3,8 @@ package dto
 import (
 	"fmt"
 
+	"golang.org/x/text/language"
+
 	"github.com/leonardchinonso/auth_service_cmp7174/utils"
 )
 
@@ -10,41 +12,53 @@ import (
 type SignupRequest struct {
 	Name       string   `json:"name"`
 	Email           Email    `json:"email"`
-	Address string `json:"address"`
+	// Address is an object with the address fields, or a single string that is parsed on a best-effort basis
+	Address Address `json:"address"`
 	Password        Password `json:"password"`
 	ConfirmPassword Password `json:"confirm_password"`
 	BusinessType BusinessType `json:"business_type"`
 	ApiKey string `json:"api_key"`
+	// Locale is the optional language tag for emails sent to the client, e.g. `en` or `fr-CA`
+	Locale string `json:"locale"`
 }
 
 // Validate validates an incoming signup request
 func (sr *SignupRequest) Validate() []error {
+	errs := sr.ValidateAccount()
+
+	utils.ShouldBePresentString(string(sr.BusinessType), "business type", &errs)
+	utils.ShouldBePresentString(sr.ApiKey, "api key", &errs)
+
+	// validate the address
+	errs = append(errs, sr.Address.Validate()...)
+
+	return errs
+}
+
+// ValidateAccount validates the account fields of a signup request, without the business profile
+// it is all that is needed to sign up by accepting an invitation, since the organization has the business profile
+func (sr *SignupRequest) ValidateAccount() []error {
 	var errs []error
 
 	utils.ShouldBePresentString(sr.Name, "name", &errs)
 	utils.ShouldBePresentString(string(sr.Email), "email", &errs)
-	utils.ShouldBePresentString(sr.Address, "address", &errs)
 	utils.ShouldBePresentString(string(sr.Password), "password", &errs)
 	utils.ShouldBePresentString(string(sr.ConfirmPassword), "confirmed password", &errs)
-	utils.ShouldBePresentString(string(sr.BusinessType), "business type", &errs)
-	utils.ShouldBePresentString(sr.ApiKey, "api key", &errs)
 
 	// validate the email
 	if err := sr.Email.Validate(); err != nil {
 		errs = append(errs, fmt.Errorf("email is invalid"))
 	}
 
-	// validate the business type
-	if len(sr.BusinessType) > 0 {
-		if err := sr.BusinessType.Validate(); err != nil {
-			errs = append(errs, fmt.Errorf("business type is invalid"))
+	// validate the locale
+	if sr.Locale != "" {
+		if _, err := language.Parse(sr.Locale); err != nil {
+			errs = append(errs, fmt.Errorf("locale is invalid"))
 		}
 	}
 
-	// validate the password
-	if err := sr.Password.Validate(); err != nil {
-		errs = append(errs, err)
-	} else if ok := sr.Password.IsEqualValue(sr.ConfirmPassword); !ok {
+	// the password is checked against the password policy when the client is signed up
+	if ok := sr.Password.IsEqualValue(sr.ConfirmPassword); !ok {
 		errs = append(errs, fmt.Errorf("passwords do not match"))
 	}
 
@@ -0,0 +1,108 @@
+package dto
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+)
+
+// TenantKey is the key the tenant of a request is stored under in the request context
+const TenantKey = "tenant"
+
+// DefaultTenantId is the id of the tenant that requests belong to when they do not name another one
+// the clients and tokens stored before there were tenants belong to it
+const DefaultTenantId = "default"
+
+// Tenant is a brand that shares the deployment with the others but none of its clients or tokens
+// its access and refresh tokens are signed with its own keys, and its password policy and mail
+// can differ from the global config
+type Tenant struct {
+	Id string `json:"id"`
+	// Hosts are the request hosts that belong to the tenant, without a port
+	Hosts       []string `json:"hosts"`
+	ATSecretKey string   `json:"at_secret_key"`
+	RTSecretKey string   `json:"rt_secret_key"`
+	// PasswordPolicy overrides the global password policy config for the tenant, e.g. `{"PASSWORD_MIN_LENGTH": "12"}`
+	PasswordPolicy map[string]string `json:"password_policy,omitempty"`
+	// MailFrom is the address the tenant's mail is sent from, the global MAIL_FROM is used when it is empty
+	MailFrom string `json:"mail_from,omitempty"`
+	// MailTemplatesDir is a directory laid out like the embedded mail templates, e.g. `en/magic_link.html`,
+	// whose files replace the embedded ones for the tenant
+	MailTemplatesDir string `json:"mail_templates_dir,omitempty"`
+	// LinkURLs overrides the global URLs of the links mailed to the tenant's clients, e.g. `{"MAGIC_LINK_URL": "https://brand.example/magic-link"}`
+	LinkURLs map[string]string `json:"link_urls,omitempty"`
+}
+
+// passwordPolicyKeys are the config names a tenant can override in its password policy
+var passwordPolicyKeys = map[string]bool{
+	config.PasswordMinLength:          true,
+	config.PasswordMaxLength:          true,
+	config.PasswordRequireUpperCase:   true,
+	config.PasswordRequireLowerCase:   true,
+	config.PasswordRequireDigit:       true,
+	config.PasswordRequireSpecialChar: true,
+	config.PasswordMinStrength:        true,
+	config.PasswordForbidPersonalInfo: true,
+	config.PasswordHistorySize:        true,
+}
+
+// linkURLKeys are the config names of the link URLs a tenant can override
+var linkURLKeys = map[string]bool{
+	config.MagicLinkURL:          true,
+	config.LoginAlertRevokeURL:   true,
+	config.EmailChangeConfirmURL: true,
+	config.EmailChangeCancelURL:  true,
+	config.InvitationURL:         true,
+}
+
+// Validate validates a tenant read from the tenants file
+func (t *Tenant) Validate() []error {
+	var errs []error
+
+	if strings.TrimSpace(t.Id) == "" {
+		errs = append(errs, fmt.Errorf("id cannot be empty"))
+	}
+	if t.ATSecretKey == "" || t.RTSecretKey == "" {
+		errs = append(errs, fmt.Errorf("tenant %q must have an at_secret_key and an rt_secret_key", t.Id))
+	}
+	for key := range t.PasswordPolicy {
+		if !passwordPolicyKeys[key] {
+			errs = append(errs, fmt.Errorf("tenant %q cannot override %s in its password policy", t.Id, key))
+		}
+	}
+	for key, value := range t.LinkURLs {
+		if !linkURLKeys[key] {
+			errs = append(errs, fmt.Errorf("tenant %q cannot override %s in its link urls", t.Id, key))
+		} else if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("tenant %q has an empty %s", t.Id, key))
+		}
+	}
+
+	return errs
+}
+
+// TenantFromContext gets the tenant set by the tenant middleware
+// it returns nil if the context does not belong to a tenant
+func TenantFromContext(ctx context.Context) *Tenant {
+	if tenant, ok := ctx.Value(TenantKey).(*Tenant); ok {
+		return tenant
+	}
+	return nil
+}
+
+// TenantIdFromContext gets the id of the tenant set by the tenant middleware
+// it returns an error if the context does not belong to a tenant, so tenant data is never read or written unscoped
+func TenantIdFromContext(ctx context.Context) (string, error) {
+	tenant := TenantFromContext(ctx)
+	if tenant == nil {
+		return "", fmt.Errorf("no tenant in context")
+	}
+	return tenant.Id, nil
+}
+
+// WithTenant returns a context that belongs to the tenant, for work on a tenant's data that is not requested by it
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, TenantKey, tenant)
+}
@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"fmt"
+)
+
+// UnlockRequest holds the data for lifting a login lockout
+type UnlockRequest struct {
+	Email Email  `json:"email"`
+	IP    string `json:"ip"`
+}
+
+// Validate validates an incoming unlock request
+func (ur *UnlockRequest) Validate() []error {
+	var errs []error
+
+	if ur.Email == "" && ur.IP == "" {
+		errs = append(errs, fmt.Errorf("email or ip is required"))
+	}
+
+	// validate the email
+	if len(ur.Email) > 0 {
+		if err := ur.Email.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("email is invalid"))
+		}
+	}
+
+	return errs
+}
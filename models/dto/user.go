@@ -9,10 +9,8 @@ import (
 // EditProfileRequest holds the data for the edit profile information
 type EditProfileRequest struct {
 	Name    string `json:"name"`
-	Address string `json:"address"`
 	Email       Email  `json:"email"`
-	PhoneNumber string `json:"phone_number"`
-	BusinessType BusinessType `json:"business_type"`
+	PhoneNumber PhoneNumber `json:"phone_number"`
 }
 
 // Validate validates an incoming edit profile request
@@ -20,21 +18,28 @@ func (epr *EditProfileRequest) Validate() []error {
 	var errs []error
 
 	utils.ShouldBePresentString(epr.Name, "name", &errs)
-	utils.ShouldBePresentString(epr.Address, "address", &errs)
 	utils.ShouldBePresentString(string(epr.Email), "email", &errs)
-	utils.ShouldBePresentString(string(epr.BusinessType), "business type", &errs)
 
 	// validate the email
 	if err := epr.Email.Validate(); err != nil {
 		errs = append(errs, fmt.Errorf("email is invalid"))
 	}
 
-	// validate the business type
-	if len(epr.BusinessType) > 0 {
-		if err := epr.BusinessType.Validate(); err != nil {
-			errs = append(errs, fmt.Errorf("business type is invalid"))
+	// validate the phone number, which is optional
+	if len(epr.PhoneNumber) > 0 {
+		if err := epr.PhoneNumber.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("phone number is invalid: %v", err))
 		}
 	}
 
 	return errs
 }
+
+// Patch returns the edit profile request as a patch that replaces every profile field it covers
+func (epr *EditProfileRequest) Patch() *ClientPatch {
+	return &ClientPatch{
+		Name:        &epr.Name,
+		Email:       &epr.Email,
+		PhoneNumber: &epr.PhoneNumber,
+	}
+}
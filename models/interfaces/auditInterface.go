@@ -0,0 +1,37 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// AuditRepositoryInterface defines methods that are applicable to the audit repository
+// audit events can only be appended and read, and only removed from the start of the chain
+// once a signed checkpoint anchors what is left
+type AuditRepositoryInterface interface {
+	Insert(ctx context.Context, event *dao.AuditEvent) error
+	FindLast(ctx context.Context, event *dao.AuditEvent) (bool, error)
+	FindBySequence(ctx context.Context, event *dao.AuditEvent) (bool, error)
+	Find(ctx context.Context, query *dto.AuditEventQuery) ([]dao.AuditEvent, int64, error)
+	Walk(ctx context.Context, fn func(event *dao.AuditEvent) error) error
+	DeleteUpTo(ctx context.Context, sequence int64) (int64, error)
+}
+
+// AuditCheckpointRepositoryInterface defines methods that are applicable to the audit checkpoint repository
+type AuditCheckpointRepositoryInterface interface {
+	Insert(ctx context.Context, checkpoint *dao.AuditCheckpoint) error
+	FindAll(ctx context.Context) ([]dao.AuditCheckpoint, error)
+}
+
+// AuditServiceInterface defines methods that are applicable to the audit service
+type AuditServiceInterface interface {
+	Record(ctx context.Context, event *dao.AuditEvent)
+	Query(ctx context.Context, query *dto.AuditEventQuery) (*dto.PaginatedResponse, error)
+	Verify(ctx context.Context) (*dto.AuditVerification, error)
+	Checkpoint(ctx context.Context) (*dao.AuditCheckpoint, error)
+	Checkpoints(ctx context.Context) ([]dao.AuditCheckpoint, error)
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,6 @@
+package interfaces
+
+// BreachedPasswordCheckerInterface defines methods that are applicable to the breached password checkers
+type BreachedPasswordCheckerInterface interface {
+	IsBreached(password string) (bool, error)
+}
@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// BusinessTypeRepositoryInterface defines methods that are applicable to the business type repository
+type BusinessTypeRepositoryInterface interface {
+	Create(ctx context.Context, businessType *dao.BusinessType) error
+	FindAll(ctx context.Context) ([]dao.BusinessType, error)
+	FindByID(ctx context.Context, businessType *dao.BusinessType) (bool, error)
+	Update(ctx context.Context, businessType *dao.BusinessType) (bool, error)
+	Delete(ctx context.Context, id primitive.ObjectID) (bool, error)
+	CountChildren(ctx context.Context, id primitive.ObjectID) (int64, error)
+}
+
+// BusinessTypeServiceInterface defines methods that are applicable to the business type service
+type BusinessTypeServiceInterface interface {
+	Validate(ctx context.Context, name string) error
+	List(ctx context.Context) ([]dto.BusinessTypeNode, error)
+	ListAll(ctx context.Context) ([]dao.BusinessType, error)
+	Create(ctx context.Context, request *dto.BusinessTypeRequest) (*dao.BusinessType, error)
+	Update(ctx context.Context, id primitive.ObjectID, update *dto.BusinessTypeUpdate) (*dao.BusinessType, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
@@ -14,14 +15,21 @@ type ClientRepositoryInterface interface {
 	Create(ctx context.Context, client *dao.Client) (primitive.ObjectID, error)
 	FindByID(ctx context.Context, client *dao.Client) (bool, error)
 	FindByEmail(ctx context.Context, client *dao.Client) (bool, error)
-	Update(ctx context.Context, client *dao.Client) error
+	Update(ctx context.Context, client *dao.Client, changes dao.ClientChanges) (bool, error)
+	ReplaceEmail(ctx context.Context, clientId primitive.ObjectID, from, to string, version int64) (bool, error)
+	SetPhoneVerified(ctx context.Context, clientId primitive.ObjectID, phoneNumber string) (bool, error)
+	RevokeSessions(ctx context.Context, clientId primitive.ObjectID, at time.Time) (bool, error)
+	Delete(ctx context.Context, clientId primitive.ObjectID) (bool, error)
+	UpdatePassword(ctx context.Context, client *dao.Client) error
 }
 
 // ClientServiceInterface defines methods that are associated with the client repository
 type ClientServiceInterface interface {
 	Signup(ctx context.Context, client *dao.Client, password dto.Password) (primitive.ObjectID, error)
+	RevertSignup(ctx context.Context, client *dao.Client) error
 	Login(ctx context.Context, client *dao.Client, password dto.Password) error
 	Logout(ctx context.Context, clientId primitive.ObjectID) error
 	GetClientByID(ctx context.Context, clientId primitive.ObjectID) (*dao.Client, error)
-	EditClientProfile(ctx context.Context, client *dao.Client) error
+	EditClientProfile(ctx context.Context, clientId primitive.ObjectID, version int64, patch *dto.ClientPatch) (*dao.Client, error)
+	ChangePassword(ctx context.Context, clientId primitive.ObjectID, currentPassword, newPassword dto.Password) error
 }
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// EmailChangeRepositoryInterface defines methods that are applicable to the email change repository
+type EmailChangeRepositoryInterface interface {
+	Create(ctx context.Context, change *dao.EmailChange) error
+	FindByConfirmToken(ctx context.Context, token string) (*dao.EmailChange, error)
+	FindByCancelToken(ctx context.Context, token string) (*dao.EmailChange, error)
+	Transition(ctx context.Context, id primitive.ObjectID, from, to string) (bool, error)
+	SupersedePending(ctx context.Context, clientId primitive.ObjectID) error
+}
+
+// EmailChangeServiceInterface defines methods that are applicable to the email change service
+type EmailChangeServiceInterface interface {
+	Request(ctx context.Context, client *dao.Client, newEmail string) error
+	Confirm(ctx context.Context, token string) error
+	Cancel(ctx context.Context, token string) error
+}
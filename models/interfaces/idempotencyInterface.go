@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// IdempotencyKeyRepositoryInterface defines methods that are applicable to the idempotency key repository
+type IdempotencyKeyRepositoryInterface interface {
+	Reserve(ctx context.Context, key *dao.IdempotencyKey) (bool, error)
+	Complete(ctx context.Context, key *dao.IdempotencyKey) error
+	Release(ctx context.Context, id string) error
+}
@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// InvitationRepositoryInterface defines methods that are applicable to the invitation repository
+type InvitationRepositoryInterface interface {
+	Create(ctx context.Context, invitation *dao.Invitation) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*dao.Invitation, error)
+	FindByTokenId(ctx context.Context, tokenId string) (*dao.Invitation, error)
+	FindPending(ctx context.Context, organizationId primitive.ObjectID) ([]dao.Invitation, error)
+	HasPending(ctx context.Context, organizationId primitive.ObjectID, email string) (bool, error)
+	Renew(ctx context.Context, id primitive.ObjectID, tokenId string, expiresAt time.Time) (bool, error)
+	Transition(ctx context.Context, id primitive.ObjectID, from, to string) (bool, error)
+}
+
+// InvitationServiceInterface defines methods that are applicable to the invitation service
+type InvitationServiceInterface interface {
+	Enabled() bool
+	Invite(ctx context.Context, client *dao.Client, organizationId primitive.ObjectID, request *dto.InvitationRequest) (*dao.Invitation, error)
+	List(ctx context.Context, clientId, organizationId primitive.ObjectID) ([]dao.Invitation, error)
+	Resend(ctx context.Context, client *dao.Client, organizationId, invitationId primitive.ObjectID) (*dao.Invitation, error)
+	Revoke(ctx context.Context, client *dao.Client, organizationId, invitationId primitive.ObjectID) error
+	AcceptWithSignup(ctx context.Context, request *dto.AcceptInvitationRequest) (*dao.Client, primitive.ObjectID, error)
+	Accept(ctx context.Context, client *dao.Client, token string) (primitive.ObjectID, error)
+}
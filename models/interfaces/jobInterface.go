@@ -0,0 +1,36 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// JobRepositoryInterface defines methods that are applicable to the job repository
+type JobRepositoryInterface interface {
+	Create(ctx context.Context, job *dao.Job) error
+	Claim(ctx context.Context, workerId string, lease time.Duration) (*dao.Job, bool, error)
+	Complete(ctx context.Context, id primitive.ObjectID, workerId string) error
+	Retry(ctx context.Context, id primitive.ObjectID, workerId string, runAt time.Time, lastError string) error
+	Kill(ctx context.Context, id primitive.ObjectID, workerId string, lastError string) error
+	DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// JobHandler runs a job, returning an error if it should be retried
+type JobHandler func(ctx context.Context, job *dao.Job) error
+
+// JobQueueInterface defines methods that are applicable to the job queue
+type JobQueueInterface interface {
+	Enqueue(ctx context.Context, jobType string, payload interface{}) error
+	Schedule(ctx context.Context, jobType string, payload interface{}, runAt time.Time) error
+}
+
+// JobWorkerPoolInterface defines methods that are applicable to the job worker pool
+type JobWorkerPoolInterface interface {
+	Register(jobType string, handler JobHandler)
+	Start()
+	Shutdown(ctx context.Context) error
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// RevokeLinkRepositoryInterface defines methods that are applicable to the revoke link repository
+type RevokeLinkRepositoryInterface interface {
+	Create(ctx context.Context, link *dao.RevokeLink) error
+	Consume(ctx context.Context, link *dao.RevokeLink) (bool, error)
+}
+
+// LoginAlertServiceInterface defines methods that are applicable to the login alert service
+type LoginAlertServiceInterface interface {
+	Enabled() bool
+	CheckLogin(ctx context.Context, client *dao.Client)
+	RevokeSessions(ctx context.Context, token string) error
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// LoginAttemptRepositoryInterface defines methods that are applicable to the login attempt repository
+type LoginAttemptRepositoryInterface interface {
+	FindByKey(ctx context.Context, attempt *dao.LoginAttempt) (bool, error)
+	RecordFailure(ctx context.Context, key string, resetBefore time.Time) (*dao.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginGuardServiceInterface defines methods that are applicable to the login guard service
+type LoginGuardServiceInterface interface {
+	Check(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context, email, ip string) error
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, email, ip string) error
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// LoginHistoryRepositoryInterface defines methods that are applicable to the login history repository
+type LoginHistoryRepositoryInterface interface {
+	Create(ctx context.Context, entry *dao.LoginHistoryEntry) error
+	CountSuccessful(ctx context.Context, clientId primitive.ObjectID, ip, userAgent string) (int64, error)
+	FindByClientId(ctx context.Context, clientId primitive.ObjectID, pagination dto.Pagination) ([]dao.LoginHistoryEntry, int64, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginHistoryServiceInterface defines methods that are applicable to the login history service
+type LoginHistoryServiceInterface interface {
+	Record(ctx context.Context, entry *dao.LoginHistoryEntry)
+	ListForClient(ctx context.Context, clientId primitive.ObjectID, pagination dto.Pagination) (*dto.PaginatedResponse, error)
+}
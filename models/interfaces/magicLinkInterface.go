@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// MagicLinkRepositoryInterface defines methods that are applicable to the magic link repository
+type MagicLinkRepositoryInterface interface {
+	Create(ctx context.Context, link *dao.MagicLink) error
+	CountByEmailSince(ctx context.Context, email string, since time.Time) (int64, error)
+	Consume(ctx context.Context, tokenId string) (bool, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MagicLinkServiceInterface defines methods that are applicable to the magic link service
+type MagicLinkServiceInterface interface {
+	Enabled() bool
+	SendLink(ctx context.Context, email string) error
+	VerifyLink(ctx context.Context, token string) (*dao.Client, error)
+}
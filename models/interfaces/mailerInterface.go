@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// MailTransportInterface defines methods that are applicable to a mail transport
+// a transport delivers mail that has already been rendered, e.g. over SMTP or to a local outbox
+type MailTransportInterface interface {
+	Deliver(ctx context.Context, mail *dto.Mail) error
+}
+
+// MailerInterface defines methods that are applicable to the mailer
+type MailerInterface interface {
+	Send(ctx context.Context, to, locale, template string, data interface{}) error
+}
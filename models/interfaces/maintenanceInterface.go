@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+)
+
+// MaintenanceSchedulerInterface defines methods that are applicable to the maintenance scheduler
+type MaintenanceSchedulerInterface interface {
+	Start()
+	RunOnce(ctx context.Context)
+	Shutdown(ctx context.Context) error
+}
@@ -0,0 +1,43 @@
+package interfaces
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// OrganizationRepositoryInterface defines methods that are applicable to the organization repository
+type OrganizationRepositoryInterface interface {
+	Create(ctx context.Context, organization *dao.Organization) error
+	FindByID(ctx context.Context, organization *dao.Organization) (bool, error)
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]dao.Organization, error)
+	Update(ctx context.Context, organization *dao.Organization) (bool, error)
+	Delete(ctx context.Context, id primitive.ObjectID) (bool, error)
+	CountByBusinessType(ctx context.Context, businessType string) (int64, error)
+	RenameBusinessType(ctx context.Context, from, to string) (int64, error)
+}
+
+// MembershipRepositoryInterface defines methods that are applicable to the membership repository
+type MembershipRepositoryInterface interface {
+	Create(ctx context.Context, membership *dao.Membership) error
+	Find(ctx context.Context, membership *dao.Membership) (bool, error)
+	FindByClient(ctx context.Context, clientId primitive.ObjectID) ([]dao.Membership, error)
+	FindByOrganization(ctx context.Context, organizationId primitive.ObjectID) ([]dao.Membership, error)
+	SetRole(ctx context.Context, organizationId, clientId primitive.ObjectID, role dao.Role) (bool, error)
+	Delete(ctx context.Context, organizationId, clientId primitive.ObjectID) (bool, error)
+	CountRole(ctx context.Context, organizationId primitive.ObjectID, role dao.Role) (int64, error)
+}
+
+// OrganizationServiceInterface defines methods that are applicable to the organization service
+type OrganizationServiceInterface interface {
+	Create(ctx context.Context, owner *dao.Client, organization *dao.Organization) error
+	List(ctx context.Context, clientId primitive.ObjectID) ([]dto.OrganizationProfile, error)
+	Get(ctx context.Context, clientId, organizationId primitive.ObjectID) (*dto.OrganizationProfile, error)
+	Update(ctx context.Context, client *dao.Client, organizationId primitive.ObjectID, update *dto.OrganizationUpdate) (*dto.OrganizationProfile, error)
+	Members(ctx context.Context, clientId, organizationId primitive.ObjectID) ([]dto.MemberProfile, error)
+	ChangeRole(ctx context.Context, client *dao.Client, organizationId, memberId primitive.ObjectID, role dao.Role) error
+	RemoveMember(ctx context.Context, client *dao.Client, organizationId, memberId primitive.ObjectID) error
+}
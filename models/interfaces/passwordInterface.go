@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// PasswordServiceInterface defines methods that are applicable to the password service
+type PasswordServiceInterface interface {
+	Validate(ctx context.Context, password dto.Password, client *dao.Client) ([]dto.PasswordRuleFailure, error)
+	AddToHistory(client *dao.Client, hash string)
+}
+
+// PasswordHasherInterface defines methods that are applicable to the password hasher
+type PasswordHasherInterface interface {
+	Hash(ctx context.Context, password string) (string, error)
+	Verify(ctx context.Context, password, hash string) (bool, error)
+	NeedsRehash(hash string) bool
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+)
+
+// PhoneCodeRepositoryInterface defines methods that are applicable to the phone code repository
+type PhoneCodeRepositoryInterface interface {
+	Replace(ctx context.Context, code *dao.PhoneCode) error
+	Find(ctx context.Context, clientId primitive.ObjectID, purpose string) (*dao.PhoneCode, error)
+	Attempt(ctx context.Context, code *dao.PhoneCode, maxAttempts int) (bool, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// PhoneVerificationServiceInterface defines methods that are applicable to the phone verification service
+type PhoneVerificationServiceInterface interface {
+	SendCode(ctx context.Context, client *dao.Client) error
+	VerifyPhone(ctx context.Context, clientId primitive.ObjectID, code string) (*dao.Client, error)
+}
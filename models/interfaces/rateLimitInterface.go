@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// RateLimitStoreInterface defines methods that are applicable to the rate limit stores
+type RateLimitStoreInterface interface {
+	Allow(ctx context.Context, key string, rule *dto.RateLimitRule) (*dto.RateLimitResult, error)
+}
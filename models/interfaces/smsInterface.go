@@ -0,0 +1,9 @@
+package interfaces
+
+import "context"
+
+// SMSSenderInterface defines methods that are applicable to an SMS sender
+// a sender delivers a text message to a phone number in E.164 format, e.g. through an SMS provider or to the log
+type SMSSenderInterface interface {
+	Send(ctx context.Context, to, message string) error
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// TenantRegistryInterface defines methods that are applicable to the tenant registry
+type TenantRegistryInterface interface {
+	Default() *dto.Tenant
+	Find(id string) (*dto.Tenant, bool)
+	FindByHost(host string) (*dto.Tenant, bool)
+	All() []*dto.Tenant
+}
@@ -11,11 +11,15 @@ import (
 // TokenRepositoryInterface defines methods that are applicable to the token repository
 type TokenRepositoryInterface interface {
 	Upsert(ctx context.Context, token *dao.Token) error
-	Delete(ctx context.Context, clientId primitive.ObjectID) error
+	FindByClientId(ctx context.Context, token *dao.Token) (bool, error)
+	Delete(ctx context.Context, clientId primitive.ObjectID) (bool, error)
 }
 
 // TokenServiceInterface defines methods that are applicable to the token service
 type TokenServiceInterface interface {
 	GenerateTokenPair(ctx context.Context, client *dao.Client) (string, string, error)
-	ClientFromAccessToken(tokenString string) (*dao.Client, error)
+	SwitchOrganization(ctx context.Context, client *dao.Client, organizationId primitive.ObjectID) (string, string, error)
+	ClientFromAccessToken(ctx context.Context, tokenString string) (*dao.Client, *primitive.ObjectID, error)
+	RefreshTokenPair(ctx context.Context, refreshToken string) (*dao.Client, string, string, error)
+	TenantIdFromToken(tokenString string) string
 }
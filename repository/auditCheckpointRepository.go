@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type auditCheckpointRepo struct {
+	c *mongo.Collection
+}
+
+const auditCheckpointCollectionName = "audit_checkpoints"
+
+// NewAuditCheckpointRepository returns an audit checkpoint interface with all the model repository methods
+func NewAuditCheckpointRepository(db *mongo.Database) interfaces.AuditCheckpointRepositoryInterface {
+	return &auditCheckpointRepo{
+		c: db.Collection(auditCheckpointCollectionName),
+	}
+}
+
+// Insert creates a new audit checkpoint document in the database
+func (acr *auditCheckpointRepo) Insert(ctx context.Context, checkpoint *dao.AuditCheckpoint) error {
+	_, err := acr.c.InsertOne(ctx, checkpoint)
+	return err
+}
+
+// FindAll finds every audit checkpoint in the database in sequence order
+func (acr *auditCheckpointRepo) FindAll(ctx context.Context) ([]dao.AuditCheckpoint, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}, {Key: "created_at", Value: 1}})
+	cursor, err := acr.c.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find audit checkpoints: %w", err)
+	}
+
+	checkpoints := []dao.AuditCheckpoint{}
+	if err = cursor.All(ctx, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to decode audit checkpoints: %w", err)
+	}
+
+	return checkpoints, nil
+}
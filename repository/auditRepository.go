@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type auditRepo struct {
+	c *mongo.Collection
+}
+
+const auditCollectionName = "audit_events"
+
+// NewAuditRepository returns an audit interface with all the model repository methods
+func NewAuditRepository(db *mongo.Database) interfaces.AuditRepositoryInterface {
+	return &auditRepo{
+		c: db.Collection(auditCollectionName),
+	}
+}
+
+// Insert appends an audit event to the database
+// it returns a duplicate key error if an event with the same sequence number already exists
+func (ar *auditRepo) Insert(ctx context.Context, event *dao.AuditEvent) error {
+	_, err := ar.c.InsertOne(ctx, event)
+	return err
+}
+
+// FindLast finds the audit event with the highest sequence number in the database
+func (ar *auditRepo) FindLast(ctx context.Context, event *dao.AuditEvent) (bool, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	err := ar.c.FindOne(ctx, bson.D{}, opts).Decode(event)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find last audit event: %w", err)
+	}
+	return true, nil
+}
+
+// FindBySequence finds an audit event by sequence number in the database
+func (ar *auditRepo) FindBySequence(ctx context.Context, event *dao.AuditEvent) (bool, error) {
+	err := ar.c.FindOne(ctx, bson.D{{Key: "sequence", Value: event.Sequence}}).Decode(event)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find audit event: %w", err)
+	}
+	return true, nil
+}
+
+// Find finds a page of the audit events that match the query, newest first, and counts all the matches
+func (ar *auditRepo) Find(ctx context.Context, query *dto.AuditEventQuery) ([]dao.AuditEvent, int64, error) {
+	filter := bson.D{}
+	for key, value := range map[string]string{
+		"type":       query.Type,
+		"actor_id":   query.ActorId,
+		"target_id":  query.TargetId,
+		"ip":         query.IP,
+		"request_id": query.RequestId,
+	} {
+		if value != "" {
+			filter = append(filter, bson.E{Key: key, Value: value})
+		}
+	}
+
+	createdAt := bson.D{}
+	if !query.From.IsZero() {
+		createdAt = append(createdAt, bson.E{Key: "$gte", Value: query.From})
+	}
+	if !query.To.IsZero() {
+		createdAt = append(createdAt, bson.E{Key: "$lt", Value: query.To})
+	}
+	if len(createdAt) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: createdAt})
+	}
+
+	total, err := ar.c.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(query.Skip()).
+		SetLimit(query.Limit)
+
+	cursor, err := ar.c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find audit events: %w", err)
+	}
+
+	events := []dao.AuditEvent{}
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// Walk calls fn with every audit event in sequence order, stopping at the first error fn returns
+func (ar *auditRepo) Walk(ctx context.Context, fn func(event *dao.AuditEvent) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+	cursor, err := ar.c.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to find audit events: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event dao.AuditEvent
+		if err = cursor.Decode(&event); err != nil {
+			return fmt.Errorf("failed to decode audit event: %w", err)
+		}
+		if err = fn(&event); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// DeleteUpTo removes the audit events up to and including the sequence number from the start of the chain
+// and returns how many were removed
+func (ar *auditRepo) DeleteUpTo(ctx context.Context, sequence int64) (int64, error) {
+	result, err := ar.c.DeleteMany(ctx, bson.D{{Key: "sequence", Value: bson.D{{Key: "$lte", Value: sequence}}}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit events: %w", err)
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type businessTypeRepo struct {
+	c *mongo.Collection
+}
+
+const businessTypeCollectionName = "business_types"
+
+// NewBusinessTypeRepository returns a business type interface with all the model repository methods
+func NewBusinessTypeRepository(db *mongo.Database) interfaces.BusinessTypeRepositoryInterface {
+	return &businessTypeRepo{
+		c: db.Collection(businessTypeCollectionName),
+	}
+}
+
+// Create creates a new business type document in the database
+func (br *businessTypeRepo) Create(ctx context.Context, businessType *dao.BusinessType) error {
+	result, err := br.c.InsertOne(ctx, businessType)
+	if err != nil {
+		return err
+	}
+	businessType.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindAll returns every business type ordered by name
+func (br *businessTypeRepo) FindAll(ctx context.Context) ([]dao.BusinessType, error) {
+	cursor, err := br.c.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find business types: %w", err)
+	}
+
+	businessTypes := make([]dao.BusinessType, 0)
+	if err = cursor.All(ctx, &businessTypes); err != nil {
+		return nil, fmt.Errorf("failed to decode business types: %w", err)
+	}
+	return businessTypes, nil
+}
+
+// FindByID finds a business type by its id
+func (br *businessTypeRepo) FindByID(ctx context.Context, businessType *dao.BusinessType) (bool, error) {
+	err := br.c.FindOne(ctx, bson.D{{Key: "_id", Value: businessType.Id}}).Decode(businessType)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Update writes the name, parent and active flag of a business type
+// it returns false if the business type does not exist
+func (br *businessTypeRepo) Update(ctx context.Context, businessType *dao.BusinessType) (bool, error) {
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "name", Value: businessType.Name},
+		{Key: "active", Value: businessType.Active},
+		{Key: "updated_at", Value: businessType.UpdatedAt},
+	}}}
+	if businessType.ParentId != nil {
+		update[0].Value = append(update[0].Value.(bson.D), bson.E{Key: "parent_id", Value: businessType.ParentId})
+	} else {
+		update = append(update, bson.E{Key: "$unset", Value: bson.D{{Key: "parent_id", Value: ""}}})
+	}
+
+	result, err := br.c.UpdateOne(ctx, bson.D{{Key: "_id", Value: businessType.Id}}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// Delete removes a business type and returns false if it does not exist
+func (br *businessTypeRepo) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	result, err := br.c.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// CountChildren counts the subcategories of a business type
+func (br *businessTypeRepo) CountChildren(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return br.c.CountDocuments(ctx, bson.D{{Key: "parent_id", Value: id}})
+}
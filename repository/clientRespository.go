@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
 )
 
@@ -20,14 +22,21 @@ type clientRepo struct {
 const clientCollectionName = "clients"
 
 // NewClientRepository returns a token interface with all the model repository methods
+// every query is scoped to the tenant in its context, so one tenant's clients are never read or changed through another
 func NewClientRepository(db *mongo.Database) interfaces.ClientRepositoryInterface {
 	return &clientRepo{
 		c: db.Collection(clientCollectionName),
 	}
 }
 
-// Create creates a new client document in the database
+// Create creates a new client document in the database for the tenant in the context
 func (ur *clientRepo) Create(ctx context.Context, client *dao.Client) (primitive.ObjectID, error) {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	client.TenantId = tenantId
+
 	result, err := ur.c.InsertOne(ctx, client)
 	if err != nil {
 		return primitive.ObjectID{}, err
@@ -37,7 +46,12 @@ func (ur *clientRepo) Create(ctx context.Context, client *dao.Client) (primitive
 
 // FindByID finds a client by id in the database
 func (ur *clientRepo) FindByID(ctx context.Context, client *dao.Client) (bool, error) {
-	err := ur.c.FindOne(ctx, bson.M{"_id": client.Id}).Decode(client)
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: client.Id}})
+	if err != nil {
+		return false, err
+	}
+
+	err = ur.c.FindOne(ctx, filter).Decode(client)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return false, nil
@@ -49,7 +63,12 @@ func (ur *clientRepo) FindByID(ctx context.Context, client *dao.Client) (bool, e
 
 // FindByEmail finds a client by email in the database
 func (ur *clientRepo) FindByEmail(ctx context.Context, client *dao.Client) (bool, error) {
-	err := ur.c.FindOne(ctx, bson.M{"email": client.Email}).Decode(client)
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "email", Value: client.Email}})
+	if err != nil {
+		return false, err
+	}
+
+	err = ur.c.FindOne(ctx, filter).Decode(client)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return false, nil
@@ -59,15 +78,117 @@ func (ur *clientRepo) FindByEmail(ctx context.Context, client *dao.Client) (bool
 	return true, nil
 }
 
-// Update updates a client in the database
-func (ur *clientRepo) Update(ctx context.Context, client *dao.Client) error {
-	filter := bson.D{{Key: "_id", Value: client.Id}}
+// Update sets the changed fields of a client in the database if it is still at the client's version,
+// and increments the version
+// it returns false if the client does not exist or was updated by someone else since that version was read
+func (ur *clientRepo) Update(ctx context.Context, client *dao.Client, changes dao.ClientChanges) (bool, error) {
+	set := bson.D{{Key: "updated_at", Value: client.UpdatedAt}}
+	for field, value := range changes {
+		set = append(set, bson.E{Key: field, Value: value})
+	}
+
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: client.Id}, {Key: "version", Value: client.Version}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+
+	result, err := ur.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+
+	client.Version++
+	return true, nil
+}
+
+// ReplaceEmail changes the client's email from one address to another and bumps the profile version
+// it returns false if the client's email is no longer the from address, or the client is no longer at the version
+// a version of 0 matches any version
+func (ur *clientRepo) ReplaceEmail(ctx context.Context, clientId primitive.ObjectID, from, to string, version int64) (bool, error) {
+	filter := bson.D{{Key: "_id", Value: clientId}, {Key: "email", Value: from}}
+	if version != 0 {
+		filter = append(filter, bson.E{Key: "version", Value: version})
+	}
+	filter, err := scopeToTenant(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "email", Value: to}, {Key: "updated_at", Value: time.Now()}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+
+	result, err := ur.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// SetPhoneVerified marks the client's phone number as verified and bumps the profile version
+// it returns false if the client's phone number is no longer the one that was verified
+func (ur *clientRepo) SetPhoneVerified(ctx context.Context, clientId primitive.ObjectID, phoneNumber string) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: clientId}, {Key: "phone_number", Value: phoneNumber}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "phone_verified", Value: true}, {Key: "updated_at", Value: time.Now()}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+
+	result, err := ur.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// RevokeSessions marks every token issued to the client until now as revoked and reports whether the client exists
+func (ur *clientRepo) RevokeSessions(ctx context.Context, clientId primitive.ObjectID, at time.Time) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: clientId}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "sessions_revoked_at", Value: at}}}}
+
+	result, err := ur.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// Delete removes a client of the tenant and reports whether it existed
+func (ur *clientRepo) Delete(ctx context.Context, clientId primitive.ObjectID) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: clientId}})
+	if err != nil {
+		return false, err
+	}
+
+	result, err := ur.c.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// UpdatePassword updates a client's password and password history in the database
+func (ur *clientRepo) UpdatePassword(ctx context.Context, client *dao.Client) error {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: client.Id}})
+	if err != nil {
+		return err
+	}
 	update := bson.D{{Key: "$set", Value: bson.D{
-		{Key: "name", Value: client.Name},
-		{Key: "email", Value: client.Email},
-		{Key: "address", Value: client.Address},
-		{Key: "phone_number", Value: client.PhoneNumber},
-		{Key: "business_type", Value: client.BusinessType},
+		{Key: "password", Value: client.Password},
+		{Key: "password_history", Value: client.PasswordHistory},
 		{Key: "updated_at", Value: client.UpdatedAt},
 	}}}
 	return ur.updateByQuery(ctx, filter, update)
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type emailChangeRepo struct {
+	c *mongo.Collection
+}
+
+const emailChangeCollectionName = "email_changes"
+
+// NewEmailChangeRepository returns an email change interface with all the model repository methods
+func NewEmailChangeRepository(db *mongo.Database) interfaces.EmailChangeRepositoryInterface {
+	return &emailChangeRepo{
+		c: db.Collection(emailChangeCollectionName),
+	}
+}
+
+// Create creates a new email change document in the database for the tenant in the context
+func (er *emailChangeRepo) Create(ctx context.Context, change *dao.EmailChange) error {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	change.TenantId = tenantId
+
+	result, err := er.c.InsertOne(ctx, change)
+	if err != nil {
+		return err
+	}
+	change.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByConfirmToken returns the unexpired email change the confirm token was issued for, or nil if there is none
+func (er *emailChangeRepo) FindByConfirmToken(ctx context.Context, token string) (*dao.EmailChange, error) {
+	return er.findUnexpired(ctx, "confirm_token_hash", dao.HashEmailChangeToken(token))
+}
+
+// FindByCancelToken returns the unexpired email change the cancel token was issued for, or nil if there is none
+func (er *emailChangeRepo) FindByCancelToken(ctx context.Context, token string) (*dao.EmailChange, error) {
+	return er.findUnexpired(ctx, "cancel_token_hash", dao.HashEmailChangeToken(token))
+}
+
+// findUnexpired returns the unexpired email change of the tenant with the token hash in the field
+// the TTL index only removes expired changes from time to time, so the expiry is checked here too
+func (er *emailChangeRepo) findUnexpired(ctx context.Context, field, hash string) (*dao.EmailChange, error) {
+	filter, err := scopeToTenant(ctx, bson.D{
+		{Key: field, Value: hash},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var change dao.EmailChange
+	err = er.c.FindOne(ctx, filter).Decode(&change)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find email change: %w", err)
+	}
+	return &change, nil
+}
+
+// Transition moves an email change from one status to another
+// it returns false if the change was not in the from status, so only one caller can make a given transition
+func (er *emailChangeRepo) Transition(ctx context.Context, id primitive.ObjectID, from, to string) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: from}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: to},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	result, err := er.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to update email change: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// SupersedePending marks the client's pending email changes as superseded, so their links stop working
+func (er *emailChangeRepo) SupersedePending(ctx context.Context, clientId primitive.ObjectID) error {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "client_id", Value: clientId}, {Key: "status", Value: dao.EmailChangePending}})
+	if err != nil {
+		return err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: dao.EmailChangeSuperseded},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	if _, err = er.c.UpdateMany(ctx, filter, update); err != nil {
+		return fmt.Errorf("failed to supersede email changes: %w", err)
+	}
+	return nil
+}
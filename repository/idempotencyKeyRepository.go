@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// idempotencyReserveAttempts is how many times a reservation is retried when the stored key expires while reserving it
+const idempotencyReserveAttempts = 2
+
+type idempotencyKeyRepo struct {
+	c *mongo.Collection
+}
+
+const idempotencyKeyCollectionName = "idempotency_keys"
+
+// NewIdempotencyKeyRepository returns an idempotency key interface with all the model repository methods
+func NewIdempotencyKeyRepository(db *mongo.Database) interfaces.IdempotencyKeyRepositoryInterface {
+	return &idempotencyKeyRepo{
+		c: db.Collection(idempotencyKeyCollectionName),
+	}
+}
+
+// Reserve stores a new idempotency key, or takes over a key whose request stopped being handled without finishing
+// it returns false if the key is already held or completed, and fills key with the stored one
+func (ir *idempotencyKeyRepo) Reserve(ctx context.Context, key *dao.IdempotencyKey) (bool, error) {
+	for attempt := 0; attempt < idempotencyReserveAttempts; attempt++ {
+		_, err := ir.c.InsertOne(ctx, key)
+		if err == nil {
+			return true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+
+		// a request with the same fingerprint may take over a key whose lock has run out
+		filter := bson.D{
+			{Key: "_id", Value: key.Id},
+			{Key: "status", Value: dao.IdempotencyProcessing},
+			{Key: "fingerprint", Value: key.Fingerprint},
+			{Key: "locked_until", Value: bson.D{{Key: "$lt", Value: time.Now()}}},
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "locked_until", Value: key.LockedUntil}}}}
+		result, err := ir.c.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if result.ModifiedCount == 1 {
+			return true, nil
+		}
+
+		err = ir.c.FindOne(ctx, bson.D{{Key: "_id", Value: key.Id}}).Decode(key)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to find idempotency key: %w", err)
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to reserve idempotency key after %d attempts", idempotencyReserveAttempts)
+}
+
+// Complete stores the response of the request that holds the idempotency key
+func (ir *idempotencyKeyRepo) Complete(ctx context.Context, key *dao.IdempotencyKey) error {
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: dao.IdempotencyCompleted},
+		{Key: "response_status", Value: key.ResponseStatus},
+		{Key: "response_body", Value: key.ResponseBody},
+		{Key: "response_headers", Value: key.ResponseHeaders},
+		{Key: "client_id", Value: key.ClientId},
+	}}}
+	_, err := ir.c.UpdateOne(ctx, bson.D{{Key: "_id", Value: key.Id}}, update)
+	return err
+}
+
+// Release removes an idempotency key whose request failed, so it can be retried
+func (ir *idempotencyKeyRepo) Release(ctx context.Context, id string) error {
+	_, err := ir.c.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: dao.IdempotencyProcessing}})
+	return err
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type invitationRepo struct {
+	c *mongo.Collection
+}
+
+const invitationCollectionName = "invitations"
+
+// NewInvitationRepository returns an invitation interface with all the model repository methods
+func NewInvitationRepository(db *mongo.Database) interfaces.InvitationRepositoryInterface {
+	return &invitationRepo{
+		c: db.Collection(invitationCollectionName),
+	}
+}
+
+// Create creates a new invitation document in the database for the tenant in the context
+func (ir *invitationRepo) Create(ctx context.Context, invitation *dao.Invitation) error {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	invitation.TenantId = tenantId
+
+	result, err := ir.c.InsertOne(ctx, invitation)
+	if err != nil {
+		return err
+	}
+	invitation.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID finds an invitation by its id, it returns nil if there is none
+func (ir *invitationRepo) FindByID(ctx context.Context, id primitive.ObjectID) (*dao.Invitation, error) {
+	return ir.findOne(ctx, bson.D{{Key: "_id", Value: id}})
+}
+
+// FindByTokenId finds the invitation a link was sent for, it returns nil if there is none
+func (ir *invitationRepo) FindByTokenId(ctx context.Context, tokenId string) (*dao.Invitation, error) {
+	return ir.findOne(ctx, bson.D{{Key: "token_id", Value: tokenId}})
+}
+
+// findOne finds the invitation of the tenant that matches the filter, it returns nil if there is none
+func (ir *invitationRepo) findOne(ctx context.Context, filter bson.D) (*dao.Invitation, error) {
+	filter, err := scopeToTenant(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation dao.Invitation
+	err = ir.c.FindOne(ctx, filter).Decode(&invitation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+// FindPending returns the pending invitations of an organization, newest first
+// invitations that expired without being accepted are included so they can be resent
+func (ir *invitationRepo) FindPending(ctx context.Context, organizationId primitive.ObjectID) ([]dao.Invitation, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "organization_id", Value: organizationId}, {Key: "status", Value: dao.InvitationPending}})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := ir.c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invitations: %w", err)
+	}
+
+	invitations := make([]dao.Invitation, 0)
+	if err = cursor.All(ctx, &invitations); err != nil {
+		return nil, fmt.Errorf("failed to decode invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// HasPending reports whether an organization has a pending invitation for the email
+func (ir *invitationRepo) HasPending(ctx context.Context, organizationId primitive.ObjectID, email string) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{
+		{Key: "organization_id", Value: organizationId},
+		{Key: "email", Value: email},
+		{Key: "status", Value: dao.InvitationPending},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	count, err := ir.c.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to count invitations: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Renew gives a pending invitation a new token id and expiry, so the links sent for it before stop working
+// it returns false if the invitation is not pending
+func (ir *invitationRepo) Renew(ctx context.Context, id primitive.ObjectID, tokenId string, expiresAt time.Time) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: dao.InvitationPending}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "token_id", Value: tokenId},
+		{Key: "expires_at", Value: expiresAt},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	result, err := ir.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to renew invitation: %w", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// Transition moves an invitation from one status to another
+// it returns false if the invitation was not in the from status, so only one caller can make a given transition
+func (ir *invitationRepo) Transition(ctx context.Context, id primitive.ObjectID, from, to string) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: from}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: to},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	result, err := ir.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to update invitation: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type jobRepo struct {
+	c *mongo.Collection
+}
+
+const jobCollectionName = "jobs"
+
+// NewJobRepository returns a job interface with all the model repository methods
+func NewJobRepository(db *mongo.Database) interfaces.JobRepositoryInterface {
+	return &jobRepo{
+		c: db.Collection(jobCollectionName),
+	}
+}
+
+// Create creates a new job document in the database
+func (jr *jobRepo) Create(ctx context.Context, job *dao.Job) error {
+	result, err := jr.c.InsertOne(ctx, job)
+	if err != nil {
+		return err
+	}
+	job.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Claim atomically takes the pending job that is due soonest, or a running job whose lease has run out,
+// and leases it to the worker, so a job whose worker died is picked up again
+func (jr *jobRepo) Claim(ctx context.Context, workerId string, lease time.Duration) (*dao.Job, bool, error) {
+	now := time.Now()
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "status", Value: dao.JobPending}, {Key: "run_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+		bson.D{{Key: "status", Value: dao.JobRunning}, {Key: "locked_until", Value: bson.D{{Key: "$lt", Value: now}}}},
+	}}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: dao.JobRunning},
+			{Key: "locked_by", Value: workerId},
+			{Key: "locked_until", Value: now.Add(lease)},
+			{Key: "updated_at", Value: now},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	job := &dao.Job{}
+	err := jr.c.FindOneAndUpdate(ctx, filter, update, opts).Decode(job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, true, nil
+}
+
+// Complete marks a job that the worker still holds as done
+// the payload is removed as it is not needed anymore and can hold secrets, e.g. the links in a rendered mail
+func (jr *jobRepo) Complete(ctx context.Context, id primitive.ObjectID, workerId string) error {
+	return jr.release(ctx, id, workerId, bson.D{
+		{Key: "status", Value: dao.JobDone},
+	}, "payload")
+}
+
+// Retry puts a job that the worker still holds back in the queue to run again at runAt
+func (jr *jobRepo) Retry(ctx context.Context, id primitive.ObjectID, workerId string, runAt time.Time, lastError string) error {
+	return jr.release(ctx, id, workerId, bson.D{
+		{Key: "status", Value: dao.JobPending},
+		{Key: "run_at", Value: runAt},
+		{Key: "last_error", Value: lastError},
+	})
+}
+
+// Kill moves a job that the worker still holds to the dead letter state
+// the payload is removed like on Complete, the type and last error are kept so the failure can be inspected
+func (jr *jobRepo) Kill(ctx context.Context, id primitive.ObjectID, workerId string, lastError string) error {
+	return jr.release(ctx, id, workerId, bson.D{
+		{Key: "status", Value: dao.JobDead},
+		{Key: "last_error", Value: lastError},
+	}, "payload")
+}
+
+// release sets the fields on a job, removes the unset ones and drops the worker's lease on it
+// nothing is changed if the lease ran out and another worker claimed the job
+func (jr *jobRepo) release(ctx context.Context, id primitive.ObjectID, workerId string, set bson.D, unset ...string) error {
+	remove := bson.D{{Key: "locked_by", Value: ""}, {Key: "locked_until", Value: ""}}
+	for _, field := range unset {
+		remove = append(remove, bson.E{Key: field, Value: ""})
+	}
+
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "locked_by", Value: workerId}}
+	update := bson.D{
+		{Key: "$set", Value: append(set, bson.E{Key: "updated_at", Value: time.Now()})},
+		{Key: "$unset", Value: remove},
+	}
+
+	if _, err := jr.c.UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	return nil
+}
+
+// DeleteFinishedBefore removes the jobs that finished before the given time, done or dead, and returns how many were removed
+func (jr *jobRepo) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{dao.JobDone, dao.JobDead}}}},
+		{Key: "updated_at", Value: bson.D{{Key: "$lt", Value: before}}},
+	}
+	result, err := jr.c.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete jobs: %w", err)
+	}
+	return result.DeletedCount, nil
+}
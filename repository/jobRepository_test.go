@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+func TestJobRepositoryDropsPayloadOfFinishedJobs(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	finish := map[string]func(jr interfaces.JobRepositoryInterface) error{
+		"complete": func(jr interfaces.JobRepositoryInterface) error {
+			return jr.Complete(context.Background(), primitive.NewObjectID(), "worker")
+		},
+		"kill": func(jr interfaces.JobRepositoryInterface) error {
+			return jr.Kill(context.Background(), primitive.NewObjectID(), "worker", "failed")
+		},
+	}
+
+	for name, run := range finish {
+		mt.Run(name, func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+			if err := run(NewJobRepository(mt.DB)); err != nil {
+				mt.Fatalf("failed to finish job: %v", err)
+			}
+
+			update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+			if _, err := update.Lookup("$unset").Document().LookupErr("payload"); err != nil {
+				mt.Errorf("the payload of the job was kept")
+			}
+		})
+	}
+}
+
+func TestJobRepositoryDeletesDeadJobsAfterRetention(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("delete finished", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		if _, err := NewJobRepository(mt.DB).DeleteFinishedBefore(context.Background(), time.Now()); err != nil {
+			mt.Fatalf("failed to delete jobs: %v", err)
+		}
+
+		filter := sentFilter(mt)
+		statuses, _ := filter.Lookup("status").Document().Lookup("$in").Array().Values()
+		dead := false
+		for _, status := range statuses {
+			dead = dead || status.StringValue() == dao.JobDead
+		}
+		if !dead {
+			mt.Errorf("dead jobs are not deleted after the retention")
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type loginAttemptRepo struct {
+	c *mongo.Collection
+}
+
+const loginAttemptCollectionName = "login_attempts"
+
+// NewLoginAttemptRepository returns a login attempt interface with all the model repository methods
+func NewLoginAttemptRepository(db *mongo.Database) interfaces.LoginAttemptRepositoryInterface {
+	return &loginAttemptRepo{
+		c: db.Collection(loginAttemptCollectionName),
+	}
+}
+
+// FindByKey finds the login attempts recorded for a key in the database
+func (lr *loginAttemptRepo) FindByKey(ctx context.Context, attempt *dao.LoginAttempt) (bool, error) {
+	err := lr.c.FindOne(ctx, bson.M{"key": attempt.Key}).Decode(attempt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find login attempt: %w", err)
+	}
+	return true, nil
+}
+
+// RecordFailure atomically increments the failure count for a key and returns the updated record
+// the count starts over if the last failure happened before resetBefore
+func (lr *loginAttemptRepo) RecordFailure(ctx context.Context, key string, resetBefore time.Time) (*dao.LoginAttempt, error) {
+	now := time.Now()
+	filter := bson.D{{Key: "key", Value: key}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "key", Value: key},
+			{Key: "failures", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gt", Value: bson.A{"$last_failure_at", resetBefore}}},
+				bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$failures", 0}}}, 1}}},
+				1,
+			}}}},
+			{Key: "last_failure_at", Value: now},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	attempt := &dao.LoginAttempt{}
+	if err := lr.c.FindOneAndUpdate(ctx, filter, update, opts).Decode(attempt); err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return attempt, nil
+}
+
+// Lock locks a key until the given time
+func (lr *loginAttemptRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	filter := bson.D{{Key: "key", Value: key}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "locked_until", Value: until}}}}
+	_, err := lr.c.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Delete removes the login attempts recorded for a key
+func (lr *loginAttemptRepo) Delete(ctx context.Context, key string) error {
+	_, err := lr.c.DeleteOne(ctx, bson.D{{Key: "key", Value: key}})
+	return err
+}
+
+// DeleteStale removes the login attempts whose last failure was before the given time and that are not locked
+// and returns how many were removed
+func (lr *loginAttemptRepo) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.D{
+		{Key: "last_failure_at", Value: bson.D{{Key: "$lt", Value: before}}},
+		{Key: "locked_until", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: time.Now()}}}}},
+	}
+	result, err := lr.c.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login attempts: %w", err)
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type loginHistoryRepo struct {
+	c *mongo.Collection
+}
+
+const loginHistoryCollectionName = "login_history"
+
+// NewLoginHistoryRepository returns a login history interface with all the model repository methods
+func NewLoginHistoryRepository(db *mongo.Database) interfaces.LoginHistoryRepositoryInterface {
+	return &loginHistoryRepo{
+		c: db.Collection(loginHistoryCollectionName),
+	}
+}
+
+// Create creates a new login history entry document in the database
+func (lhr *loginHistoryRepo) Create(ctx context.Context, entry *dao.LoginHistoryEntry) error {
+	_, err := lhr.c.InsertOne(ctx, entry)
+	return err
+}
+
+// CountSuccessful counts the client's successful logins, only from the ip and user agent if they are set
+func (lhr *loginHistoryRepo) CountSuccessful(ctx context.Context, clientId primitive.ObjectID, ip, userAgent string) (int64, error) {
+	filter := bson.D{{Key: "client_id", Value: clientId}, {Key: "success", Value: true}}
+	if ip != "" {
+		filter = append(filter, bson.E{Key: "ip", Value: ip})
+	}
+	if userAgent != "" {
+		filter = append(filter, bson.E{Key: "user_agent", Value: userAgent})
+	}
+
+	count, err := lhr.c.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count login history: %w", err)
+	}
+	return count, nil
+}
+
+// FindByClientId finds a page of the client's login history, newest first, and counts all their entries
+func (lhr *loginHistoryRepo) FindByClientId(ctx context.Context, clientId primitive.ObjectID, pagination dto.Pagination) ([]dao.LoginHistoryEntry, int64, error) {
+	filter := bson.D{{Key: "client_id", Value: clientId}}
+
+	total, err := lhr.c.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count login history: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(pagination.Skip()).
+		SetLimit(pagination.Limit)
+
+	cursor, err := lhr.c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find login history: %w", err)
+	}
+
+	entries := []dao.LoginHistoryEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode login history: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// DeleteBefore removes the login history entries created before the given time and returns how many were removed
+func (lhr *loginHistoryRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := lhr.c.DeleteMany(ctx, bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: before}}}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login history: %w", err)
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type magicLinkRepo struct {
+	c *mongo.Collection
+}
+
+const magicLinkCollectionName = "magic_links"
+
+// NewMagicLinkRepository returns a magic link interface with all the model repository methods
+func NewMagicLinkRepository(db *mongo.Database) interfaces.MagicLinkRepositoryInterface {
+	return &magicLinkRepo{
+		c: db.Collection(magicLinkCollectionName),
+	}
+}
+
+// Create creates a new magic link document in the tenant of the context
+func (mr *magicLinkRepo) Create(ctx context.Context, link *dao.MagicLink) error {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	link.TenantId = tenantId
+
+	_, err = mr.c.InsertOne(ctx, link)
+	return err
+}
+
+// CountByEmailSince counts the magic links requested for an email since the given time, sent or not
+func (mr *magicLinkRepo) CountByEmailSince(ctx context.Context, email string, since time.Time) (int64, error) {
+	filter, err := scopeToTenant(ctx, bson.D{
+		{Key: "email", Value: email},
+		{Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return mr.c.CountDocuments(ctx, filter)
+}
+
+// Consume marks an unused and unexpired magic link as used
+// it returns false if the link does not exist, has expired or was used already
+func (mr *magicLinkRepo) Consume(ctx context.Context, tokenId string) (bool, error) {
+	now := time.Now()
+	filter, err := scopeToTenant(ctx, bson.D{
+		{Key: "token_id", Value: tokenId},
+		{Key: "used_at", Value: nil},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: now}}}}
+
+	err = mr.c.FindOneAndUpdate(ctx, filter, update).Err()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteBefore removes the magic links created before the given time and returns how many were removed
+// it removes them in every tenant, since the retention applies to the whole deployment
+func (mr *magicLinkRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := mr.c.DeleteMany(ctx, bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: before}}}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete magic links: %w", err)
+	}
+	return result.DeletedCount, nil
+}
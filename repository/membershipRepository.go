@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type membershipRepo struct {
+	c *mongo.Collection
+}
+
+const membershipCollectionName = "memberships"
+
+// NewMembershipRepository returns a membership interface with all the model repository methods
+func NewMembershipRepository(db *mongo.Database) interfaces.MembershipRepositoryInterface {
+	return &membershipRepo{
+		c: db.Collection(membershipCollectionName),
+	}
+}
+
+// Create creates a new membership document in the database for the tenant in the context
+// the unique index on the organization and client rejects a client that is already a member
+func (mr *membershipRepo) Create(ctx context.Context, membership *dao.Membership) error {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	membership.TenantId = tenantId
+
+	result, err := mr.c.InsertOne(ctx, membership)
+	if err != nil {
+		return err
+	}
+	membership.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Find finds the membership of a client in an organization of the tenant
+func (mr *membershipRepo) Find(ctx context.Context, membership *dao.Membership) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "organization_id", Value: membership.OrganizationId}, {Key: "client_id", Value: membership.ClientId}})
+	if err != nil {
+		return false, err
+	}
+
+	err = mr.c.FindOne(ctx, filter).Decode(membership)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FindByClient returns the memberships of a client, oldest first
+func (mr *membershipRepo) FindByClient(ctx context.Context, clientId primitive.ObjectID) ([]dao.Membership, error) {
+	return mr.find(ctx, bson.D{{Key: "client_id", Value: clientId}})
+}
+
+// FindByOrganization returns the memberships of an organization, oldest first
+func (mr *membershipRepo) FindByOrganization(ctx context.Context, organizationId primitive.ObjectID) ([]dao.Membership, error) {
+	return mr.find(ctx, bson.D{{Key: "organization_id", Value: organizationId}})
+}
+
+// find returns the memberships of the tenant that match the filter, oldest first
+func (mr *membershipRepo) find(ctx context.Context, filter bson.D) ([]dao.Membership, error) {
+	filter, err := scopeToTenant(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := mr.c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find memberships: %w", err)
+	}
+
+	memberships := make([]dao.Membership, 0)
+	if err = cursor.All(ctx, &memberships); err != nil {
+		return nil, fmt.Errorf("failed to decode memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+// SetRole gives a member a new role and returns false if the client is not a member
+func (mr *membershipRepo) SetRole(ctx context.Context, organizationId, clientId primitive.ObjectID, role dao.Role) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "organization_id", Value: organizationId}, {Key: "client_id", Value: clientId}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "role", Value: role}, {Key: "updated_at", Value: time.Now()}}}}
+
+	result, err := mr.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// Delete removes a client from an organization and returns false if the client is not a member
+func (mr *membershipRepo) Delete(ctx context.Context, organizationId, clientId primitive.ObjectID) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "organization_id", Value: organizationId}, {Key: "client_id", Value: clientId}})
+	if err != nil {
+		return false, err
+	}
+	result, err := mr.c.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// CountRole counts the members of an organization that have the role
+func (mr *membershipRepo) CountRole(ctx context.Context, organizationId primitive.ObjectID, role dao.Role) (int64, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "organization_id", Value: organizationId}, {Key: "role", Value: role}})
+	if err != nil {
+		return 0, err
+	}
+	return mr.c.CountDocuments(ctx, filter)
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type organizationRepo struct {
+	c *mongo.Collection
+}
+
+const organizationCollectionName = "organizations"
+
+// NewOrganizationRepository returns an organization interface with all the model repository methods
+func NewOrganizationRepository(db *mongo.Database) interfaces.OrganizationRepositoryInterface {
+	return &organizationRepo{
+		c: db.Collection(organizationCollectionName),
+	}
+}
+
+// Create creates a new organization document in the database for the tenant in the context
+func (or *organizationRepo) Create(ctx context.Context, organization *dao.Organization) error {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	organization.TenantId = tenantId
+
+	result, err := or.c.InsertOne(ctx, organization)
+	if err != nil {
+		return err
+	}
+	organization.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID finds an organization of the tenant by its id
+func (or *organizationRepo) FindByID(ctx context.Context, organization *dao.Organization) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: organization.Id}})
+	if err != nil {
+		return false, err
+	}
+
+	err = or.c.FindOne(ctx, filter).Decode(organization)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FindByIDs returns the organizations of the tenant with the ids, ordered by name
+func (or *organizationRepo) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]dao.Organization, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := or.c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find organizations: %w", err)
+	}
+
+	organizations := make([]dao.Organization, 0, len(ids))
+	if err = cursor.All(ctx, &organizations); err != nil {
+		return nil, fmt.Errorf("failed to decode organizations: %w", err)
+	}
+	return organizations, nil
+}
+
+// Update writes the business profile of an organization
+// it returns false if the organization does not exist in the tenant
+func (or *organizationRepo) Update(ctx context.Context, organization *dao.Organization) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: organization.Id}})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "name", Value: organization.Name},
+		{Key: "address", Value: organization.Address},
+		{Key: "business_type", Value: organization.BusinessType},
+		{Key: "api_key", Value: organization.ApiKey},
+		{Key: "updated_at", Value: organization.UpdatedAt},
+	}}}
+
+	result, err := or.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// Delete removes an organization of the tenant and reports whether it existed
+func (or *organizationRepo) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return false, err
+	}
+
+	result, err := or.c.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+// CountByBusinessType counts the organizations with a business type
+// it counts across every tenant, since the business types are shared by them
+func (or *organizationRepo) CountByBusinessType(ctx context.Context, businessType string) (int64, error) {
+	return or.c.CountDocuments(ctx, bson.D{{Key: "business_type", Value: businessType}})
+}
+
+// RenameBusinessType moves the organizations with a business type to its new name and returns how many were moved
+// it moves them in every tenant, since the business types are shared by them
+func (or *organizationRepo) RenameBusinessType(ctx context.Context, from, to string) (int64, error) {
+	filter := bson.D{{Key: "business_type", Value: from}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "business_type", Value: to}, {Key: "updated_at", Value: time.Now()}}}}
+
+	result, err := or.c.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type phoneCodeRepo struct {
+	c *mongo.Collection
+}
+
+const phoneCodeCollectionName = "phone_codes"
+
+// NewPhoneCodeRepository returns a phone code interface with all the model repository methods
+func NewPhoneCodeRepository(db *mongo.Database) interfaces.PhoneCodeRepositoryInterface {
+	return &phoneCodeRepo{
+		c: db.Collection(phoneCodeCollectionName),
+	}
+}
+
+// Replace stores the code as the client's only code for its purpose, in the tenant in the context
+func (pr *phoneCodeRepo) Replace(ctx context.Context, code *dao.PhoneCode) error {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	code.TenantId = tenantId
+
+	filter := bson.D{{Key: "tenant_id", Value: tenantId}, {Key: "client_id", Value: code.ClientId}, {Key: "purpose", Value: code.Purpose}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "phone_number", Value: code.PhoneNumber},
+		{Key: "code_hash", Value: code.CodeHash},
+		{Key: "attempts", Value: code.Attempts},
+		{Key: "sent_count", Value: code.SentCount},
+		{Key: "window_started_at", Value: code.WindowStartedAt},
+		{Key: "expires_at", Value: code.ExpiresAt},
+		{Key: "purge_at", Value: code.PurgeAt},
+		{Key: "created_at", Value: code.CreatedAt},
+	}}}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err = pr.c.FindOneAndUpdate(ctx, filter, update, opts).Decode(code); err != nil {
+		return fmt.Errorf("failed to store phone code: %w", err)
+	}
+	return nil
+}
+
+// Find returns the client's last code for the purpose, or nil if there is none
+// the code is returned after it expires until its send window ends, so it has to be checked for expiry before it is used
+func (pr *phoneCodeRepo) Find(ctx context.Context, clientId primitive.ObjectID, purpose string) (*dao.PhoneCode, error) {
+	filter, err := scopeToTenant(ctx, bson.D{
+		{Key: "client_id", Value: clientId},
+		{Key: "purpose", Value: purpose},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var code dao.PhoneCode
+	err = pr.c.FindOne(ctx, filter).Decode(&code)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find phone code: %w", err)
+	}
+	return &code, nil
+}
+
+// Attempt counts a guess of the code
+// it returns false if the code was already guessed the maximum number of times, or was replaced in the meantime
+func (pr *phoneCodeRepo) Attempt(ctx context.Context, code *dao.PhoneCode, maxAttempts int) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{
+		{Key: "_id", Value: code.Id},
+		{Key: "code_hash", Value: code.CodeHash},
+		{Key: "attempts", Value: bson.D{{Key: "$lt", Value: maxAttempts}}},
+	})
+	if err != nil {
+		return false, err
+	}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}}}
+
+	result, err := pr.c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to count phone code attempt: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Delete removes a code of the tenant once it was used
+func (pr *phoneCodeRepo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	_, err = pr.c.DeleteOne(ctx, filter)
+	return err
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// memoryRateLimitSweepInterval is how often expired counters are dropped from memory
+const memoryRateLimitSweepInterval = time.Minute
+
+// memoryRateLimitState holds the counters for a single rate limit key
+type memoryRateLimitState struct {
+	tokens      float64
+	updatedAt   time.Time
+	windowStart time.Time
+	current     int
+	previous    int
+	expiresAt   time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	states    map[string]*memoryRateLimitState
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore returns a rate limit store that keeps its counters in memory
+// the counters are not shared, so it is only suitable for a single instance
+func NewMemoryRateLimitStore() interfaces.RateLimitStoreInterface {
+	return &memoryRateLimitStore{
+		states:    make(map[string]*memoryRateLimitState),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow counts a request for the key against the rule
+func (ms *memoryRateLimitStore) Allow(_ context.Context, key string, rule *dto.RateLimitRule) (*dto.RateLimitResult, error) {
+	now := time.Now()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.sweep(now)
+
+	state, ok := ms.states[key]
+	if !ok {
+		state = &memoryRateLimitState{}
+		ms.states[key] = state
+	}
+	state.expiresAt = now.Add(2 * rule.Window)
+
+	if rule.Algorithm == dto.TokenBucket {
+		tokens, result := takeToken(state.tokens, state.updatedAt, now, rule)
+		state.tokens, state.updatedAt = tokens, now
+		return result, nil
+	}
+
+	// roll the fixed windows forward
+	start := windowStart(now, rule.Window)
+	switch {
+	case start.Equal(state.windowStart):
+	case start.Equal(state.windowStart.Add(rule.Window)):
+		state.previous, state.current = state.current, 0
+	default:
+		state.previous, state.current = 0, 0
+	}
+	state.windowStart = start
+
+	result := slidingWindow(state.previous, state.current, now, rule)
+	if result.Allowed {
+		state.current++
+	}
+
+	return result, nil
+}
+
+// sweep drops the counters that have expired, at most once per sweep interval
+func (ms *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(ms.lastSweep) < memoryRateLimitSweepInterval {
+		return
+	}
+
+	for key, state := range ms.states {
+		if now.After(state.expiresAt) {
+			delete(ms.states, key)
+		}
+	}
+	ms.lastSweep = now
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// mongoRateLimitRetries is how many times a conflicting update is retried before giving up
+const mongoRateLimitRetries = 5
+
+type mongoRateLimitStore struct {
+	c *mongo.Collection
+}
+
+const rateLimitCollectionName = "rate_limits"
+
+// mongoTokenBucket is the stored state of a token bucket
+type mongoTokenBucket struct {
+	Id        string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// mongoWindowCounter is the stored count of requests in a fixed window
+type mongoWindowCounter struct {
+	Id        string    `bson:"_id"`
+	Count     int       `bson:"count"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewMongoRateLimitStore returns a rate limit store that keeps its counters in the database
+// the counters are shared by every instance of the service, and a TTL index removes them once they expire
+func NewMongoRateLimitStore(db *mongo.Database) interfaces.RateLimitStoreInterface {
+	return &mongoRateLimitStore{
+		c: db.Collection(rateLimitCollectionName),
+	}
+}
+
+// Allow counts a request for the key against the rule
+func (ms *mongoRateLimitStore) Allow(ctx context.Context, key string, rule *dto.RateLimitRule) (*dto.RateLimitResult, error) {
+	if rule.Algorithm == dto.TokenBucket {
+		return ms.takeToken(ctx, key, rule)
+	}
+	return ms.slidingWindow(ctx, key, rule)
+}
+
+// takeToken takes a token from the bucket for the key with compare-and-set retries
+func (ms *mongoRateLimitStore) takeToken(ctx context.Context, key string, rule *dto.RateLimitRule) (*dto.RateLimitResult, error) {
+	for i := 0; i < mongoRateLimitRetries; i++ {
+		now := time.Now()
+
+		bucket := mongoTokenBucket{}
+		err := ms.c.FindOne(ctx, bson.M{"_id": key}).Decode(&bucket)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to find token bucket: %w", err)
+		}
+		exists := err == nil
+
+		tokens, result := takeToken(bucket.Tokens, bucket.UpdatedAt, now, rule)
+		next := mongoTokenBucket{Id: key, Tokens: tokens, UpdatedAt: now, ExpiresAt: now.Add(rule.Window)}
+
+		if !exists {
+			_, err = ms.c.InsertOne(ctx, next)
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to create token bucket: %w", err)
+			}
+			return result, nil
+		}
+
+		// only write the bucket back if nobody else has updated it in the meantime
+		filter := bson.D{{Key: "_id", Value: key}, {Key: "updated_at", Value: bucket.UpdatedAt}}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: next.Tokens},
+			{Key: "updated_at", Value: next.UpdatedAt},
+			{Key: "expires_at", Value: next.ExpiresAt},
+		}}}
+		res, err := ms.c.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update token bucket: %w", err)
+		}
+		if res.MatchedCount == 1 {
+			return result, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to update token bucket: too many concurrent updates")
+}
+
+// slidingWindow counts the request in the current fixed window and estimates the sliding window from it
+func (ms *mongoRateLimitStore) slidingWindow(ctx context.Context, key string, rule *dto.RateLimitRule) (*dto.RateLimitResult, error) {
+	now := time.Now()
+	start := windowStart(now, rule.Window)
+	currentId := fmt.Sprintf("%s:%d", key, start.Unix())
+	previousId := fmt.Sprintf("%s:%d", key, start.Add(-rule.Window).Unix())
+
+	// count the request up front so concurrent requests see each other
+	filter := bson.D{{Key: "_id", Value: currentId}}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "expires_at", Value: start.Add(2 * rule.Window)}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	current := mongoWindowCounter{}
+	err := ms.c.FindOneAndUpdate(ctx, filter, update, opts).Decode(&current)
+	if mongo.IsDuplicateKeyError(err) {
+		// two upserts raced to create the window, the loser can now update it
+		err = ms.c.FindOneAndUpdate(ctx, filter, update, opts).Decode(&current)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to count request: %w", err)
+	}
+
+	previous := mongoWindowCounter{}
+	err = ms.c.FindOne(ctx, bson.M{"_id": previousId}).Decode(&previous)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to find previous window: %w", err)
+	}
+
+	result := slidingWindow(previous.Count, current.Count-1, now, rule)
+	if !result.Allowed {
+		// a rejected request does not count towards the limit
+		if _, err = ms.c.UpdateOne(ctx, filter, bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: -1}}}}); err != nil {
+			return nil, fmt.Errorf("failed to uncount request: %w", err)
+		}
+	}
+
+	return result, nil
+}
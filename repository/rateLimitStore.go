@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"math"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// takeToken refills a token bucket for the time elapsed since it was last updated
+// and takes a token from it if one is available, returning the tokens left
+func takeToken(tokens float64, updatedAt, now time.Time, rule *dto.RateLimitRule) (float64, *dto.RateLimitResult) {
+	capacity := float64(rule.Limit)
+	rate := capacity / rule.Window.Seconds()
+
+	// a bucket that has not been seen before starts full
+	if updatedAt.IsZero() {
+		tokens = capacity
+	} else {
+		tokens = math.Min(capacity, tokens+now.Sub(updatedAt).Seconds()*rate)
+	}
+
+	result := &dto.RateLimitResult{Limit: rule.Limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+
+	result.Remaining = int(tokens)
+	result.ResetAfter = secondsToDuration((capacity - tokens) / rate)
+
+	return tokens, result
+}
+
+// slidingWindow estimates the requests made in the sliding window from the counts of the previous
+// and current fixed windows, and reports whether one more request fits in the limit
+func slidingWindow(previous, current int, now time.Time, rule *dto.RateLimitRule) *dto.RateLimitResult {
+	window := rule.Window.Seconds()
+	elapsed := now.Sub(now.Truncate(rule.Window)).Seconds()
+	limit := float64(rule.Limit)
+
+	// the previous window counts less the further we are into the current one
+	estimate := float64(previous)*(1-elapsed/window) + float64(current)
+
+	result := &dto.RateLimitResult{
+		Limit:      rule.Limit,
+		ResetAfter: secondsToDuration(window - elapsed),
+	}
+
+	if estimate < limit {
+		result.Allowed = true
+		result.Remaining = int(math.Max(0, math.Floor(limit-estimate-1)))
+		return result
+	}
+
+	// work out how long until the estimate drops below the limit
+	var wait float64
+	if current < rule.Limit && previous > 0 {
+		wait = window*(1-(limit-float64(current))/float64(previous)) - elapsed
+	} else {
+		wait = (window - elapsed) + window*(1-limit/float64(current))
+	}
+	result.RetryAfter = secondsToDuration(math.Max(wait, 1))
+
+	return result
+}
+
+// windowStart returns the start of the fixed window that the time falls in
+func windowStart(now time.Time, window time.Duration) time.Time {
+	return now.Truncate(window)
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
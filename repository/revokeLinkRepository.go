@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type revokeLinkRepo struct {
+	c *mongo.Collection
+}
+
+const revokeLinkCollectionName = "revoke_links"
+
+// NewRevokeLinkRepository returns a revoke link interface with all the model repository methods
+func NewRevokeLinkRepository(db *mongo.Database) interfaces.RevokeLinkRepositoryInterface {
+	return &revokeLinkRepo{
+		c: db.Collection(revokeLinkCollectionName),
+	}
+}
+
+// Create creates a new revoke link document in the database
+func (rr *revokeLinkRepo) Create(ctx context.Context, link *dao.RevokeLink) error {
+	_, err := rr.c.InsertOne(ctx, link)
+	return err
+}
+
+// Consume marks an unused and unexpired revoke link of the client as used
+// it returns false if the link does not exist, has expired or was used already
+func (rr *revokeLinkRepo) Consume(ctx context.Context, link *dao.RevokeLink) (bool, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "token_id", Value: link.TokenId},
+		{Key: "client_id", Value: link.ClientId},
+		{Key: "used_at", Value: nil},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: now}}}}
+
+	err := rr.c.FindOneAndUpdate(ctx, filter, update).Err()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+var (
+	tenantA = &dto.Tenant{Id: "tenant-a"}
+	tenantB = &dto.Tenant{Id: "tenant-b"}
+)
+
+// emptyFind is the mock response of a find that matched nothing
+func emptyFind(ns string) bson.D {
+	return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch)
+}
+
+// unmatchedWrite is the mock response of an update or delete that matched nothing
+func unmatchedWrite() bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0})
+}
+
+// sentFilter returns the filter of the last find, update, delete or count the repository sent
+func sentFilter(mt *mtest.T) bson.Raw {
+	mt.Helper()
+
+	event := mt.GetStartedEvent()
+	if event == nil {
+		mt.Fatalf("no command was sent")
+	}
+	switch event.CommandName {
+	case "find":
+		return event.Command.Lookup("filter").Document()
+	case "update":
+		return event.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+	case "delete":
+		return event.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+	case "findAndModify":
+		return event.Command.Lookup("query").Document()
+	case "aggregate":
+		return event.Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+	}
+	mt.Fatalf("unexpected command %q", event.CommandName)
+	return nil
+}
+
+// TestRepositoriesScopeToTenant checks that every lookup by id, email or token of tenant A's documents is sent
+// with tenant B's id when it is made through tenant B, so it cannot match them
+func TestRepositoriesScopeToTenant(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	// the ids and emails are tenant A's, and every lookup is made through tenant B
+	clientId := primitive.NewObjectID()
+	organizationId := primitive.NewObjectID()
+	ctx := dto.WithTenant(context.Background(), tenantB)
+
+	tests := []struct {
+		name     string
+		response bson.D
+		lookup   func(mt *mtest.T) (bool, error)
+	}{
+		{"client find by id", emptyFind("db.clients"), func(mt *mtest.T) (bool, error) {
+			return NewClientRepository(mt.DB).FindByID(ctx, &dao.Client{Id: clientId})
+		}},
+		{"client find by email", emptyFind("db.clients"), func(mt *mtest.T) (bool, error) {
+			return NewClientRepository(mt.DB).FindByEmail(ctx, &dao.Client{Email: "a@tenant-a.test"})
+		}},
+		{"client update", unmatchedWrite(), func(mt *mtest.T) (bool, error) {
+			return NewClientRepository(mt.DB).Update(ctx, &dao.Client{Id: clientId, Version: 1}, dao.ClientChanges{"name": "B"})
+		}},
+		{"client delete", unmatchedWrite(), func(mt *mtest.T) (bool, error) {
+			return NewClientRepository(mt.DB).Delete(ctx, clientId)
+		}},
+		{"token find by client id", emptyFind("db.tokens"), func(mt *mtest.T) (bool, error) {
+			return NewTokenRepository(mt.DB).FindByClientId(ctx, &dao.Token{ClientId: clientId})
+		}},
+		{"token delete", unmatchedWrite(), func(mt *mtest.T) (bool, error) {
+			return NewTokenRepository(mt.DB).Delete(ctx, clientId)
+		}},
+		{"organization find by id", emptyFind("db.organizations"), func(mt *mtest.T) (bool, error) {
+			return NewOrganizationRepository(mt.DB).FindByID(ctx, &dao.Organization{Id: organizationId})
+		}},
+		{"membership find", emptyFind("db.memberships"), func(mt *mtest.T) (bool, error) {
+			return NewMembershipRepository(mt.DB).Find(ctx, &dao.Membership{OrganizationId: organizationId, ClientId: clientId})
+		}},
+		{"membership delete", unmatchedWrite(), func(mt *mtest.T) (bool, error) {
+			return NewMembershipRepository(mt.DB).Delete(ctx, organizationId, clientId)
+		}},
+		{"invitation find by token id", emptyFind("db.invitations"), func(mt *mtest.T) (bool, error) {
+			invitation, err := NewInvitationRepository(mt.DB).FindByTokenId(ctx, "token-id")
+			return invitation != nil, err
+		}},
+		{"email change find by confirm token", emptyFind("db.email_changes"), func(mt *mtest.T) (bool, error) {
+			change, err := NewEmailChangeRepository(mt.DB).FindByConfirmToken(ctx, "confirm-token")
+			return change != nil, err
+		}},
+		{"phone code find", emptyFind("db.phone_codes"), func(mt *mtest.T) (bool, error) {
+			code, err := NewPhoneCodeRepository(mt.DB).Find(ctx, clientId, dao.PhoneCodeVerify)
+			return code != nil, err
+		}},
+		{"magic link count by email", emptyFind("db.magic_links"), func(mt *mtest.T) (bool, error) {
+			count, err := NewMagicLinkRepository(mt.DB).CountByEmailSince(ctx, "a@tenant-a.test", time.Time{})
+			return count > 0, err
+		}},
+		{"magic link consume", mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}), func(mt *mtest.T) (bool, error) {
+			return NewMagicLinkRepository(mt.DB).Consume(ctx, "token-id")
+		}},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(tt.response)
+
+			found, err := tt.lookup(mt)
+			if err != nil {
+				mt.Fatalf("lookup failed: %v", err)
+			}
+			if found {
+				mt.Errorf("lookup matched a document of another tenant")
+			}
+
+			if tenantId := sentFilter(mt).Lookup("tenant_id").StringValue(); tenantId != tenantB.Id {
+				mt.Errorf("filter has tenant_id %q, want %q", tenantId, tenantB.Id)
+			}
+		})
+	}
+}
+
+// TestRepositoriesRefuseUnscopedContext checks that a lookup from a context without a tenant fails
+// instead of being sent unscoped
+func TestRepositoriesRefuseUnscopedContext(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("client find by id", func(mt *mtest.T) {
+		if _, err := NewClientRepository(mt.DB).FindByID(context.Background(), &dao.Client{Id: primitive.NewObjectID()}); err == nil {
+			mt.Errorf("lookup without a tenant did not fail")
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("lookup without a tenant sent %q", event.CommandName)
+		}
+	})
+
+	mt.Run("membership find", func(mt *mtest.T) {
+		if _, err := NewMembershipRepository(mt.DB).Find(context.Background(), &dao.Membership{}); err == nil {
+			mt.Errorf("lookup without a tenant did not fail")
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("lookup without a tenant sent %q", event.CommandName)
+		}
+	})
+}
+
+// TestRepositoriesStoreTenant checks that documents are created in the tenant of the context
+func TestRepositoriesStoreTenant(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	ctx := dto.WithTenant(context.Background(), tenantA)
+
+	tests := []struct {
+		name   string
+		create func(mt *mtest.T) (string, error)
+	}{
+		{"client", func(mt *mtest.T) (string, error) {
+			client := dao.NewClient("a", "a@tenant-a.test", "password")
+			_, err := NewClientRepository(mt.DB).Create(ctx, client)
+			return client.TenantId, err
+		}},
+		{"organization", func(mt *mtest.T) (string, error) {
+			organization := dao.NewOrganization("a", dao.Address{}, "Construction", "key")
+			err := NewOrganizationRepository(mt.DB).Create(ctx, organization)
+			return organization.TenantId, err
+		}},
+		{"membership", func(mt *mtest.T) (string, error) {
+			membership := dao.NewMembership(primitive.NewObjectID(), primitive.NewObjectID(), dao.RoleOwner)
+			err := NewMembershipRepository(mt.DB).Create(ctx, membership)
+			return membership.TenantId, err
+		}},
+		{"magic link", func(mt *mtest.T) (string, error) {
+			link := dao.NewMagicLink("a@tenant-a.test", "token-id", time.Minute)
+			err := NewMagicLinkRepository(mt.DB).Create(ctx, link)
+			return link.TenantId, err
+		}},
+		{"invitation", func(mt *mtest.T) (string, error) {
+			invitation := dao.NewInvitation(primitive.NewObjectID(), "b@tenant-a.test", dao.RoleMember, primitive.NewObjectID(), "token-id", 0)
+			err := NewInvitationRepository(mt.DB).Create(ctx, invitation)
+			return invitation.TenantId, err
+		}},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+			tenantId, err := tt.create(mt)
+			if err != nil {
+				mt.Fatalf("create failed: %v", err)
+			}
+			if tenantId != tenantA.Id {
+				mt.Errorf("created with tenant_id %q, want %q", tenantId, tenantA.Id)
+			}
+
+			event := mt.GetStartedEvent()
+			if event == nil || event.CommandName != "insert" {
+				mt.Fatalf("no insert was sent")
+			}
+			stored := event.Command.Lookup("documents").Array().Index(0).Value().Document().Lookup("tenant_id").StringValue()
+			if stored != tenantA.Id {
+				mt.Errorf("inserted with tenant_id %q, want %q", stored, tenantA.Id)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// scopeToTenant adds the tenant in the context to a filter, so a query cannot match another tenant's documents
+// it fails when the context does not belong to a tenant rather than running the query unscoped
+func scopeToTenant(ctx context.Context, filter bson.D) (bson.D, error) {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(bson.D{{Key: "tenant_id", Value: tenantId}}, filter...), nil
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -9,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
 )
 
@@ -19,31 +22,67 @@ type tokenRepo struct {
 const tokenCollectionName = "tokens"
 
 // NewTokenRepository returns a token interface with all the model repository methods
+// every query is scoped to the tenant in its context, like the client repository
 func NewTokenRepository(db *mongo.Database) interfaces.TokenRepositoryInterface {
 	return &tokenRepo{
 		c: db.Collection(tokenCollectionName),
 	}
 }
 
-// Upsert updates a token by the clientId if it exists in the database
+// Upsert updates a token by the clientId if it exists in the database for the tenant in the context
 // it inserts a new document if it does not exist
 func (tr *tokenRepo) Upsert(ctx context.Context, token *dao.Token) error {
-	filter := bson.D{{"client_id", token.ClientId}}
-	update := bson.D{{"$set", bson.D{{"client_id", token.ClientId}, {"refresh_token", token.RefreshToken}, {"access_token", token.AccessToken}}}}
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	token.TenantId = tenantId
+
+	filter := bson.D{{Key: "tenant_id", Value: tenantId}, {Key: "client_id", Value: token.ClientId}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "client_id", Value: token.ClientId}, {Key: "refresh_token", Value: token.RefreshToken}, {Key: "access_token", Value: token.AccessToken}, {Key: "created_at", Value: token.CreatedAt}, {Key: "expires_at", Value: token.ExpiresAt}}}}
+	if token.OrganizationId != nil {
+		update[0].Value = append(update[0].Value.(bson.D), bson.E{Key: "organization_id", Value: token.OrganizationId})
+	} else {
+		update = append(update, bson.E{Key: "$unset", Value: bson.D{{Key: "organization_id", Value: ""}}})
+	}
 	opts := options.Update().SetUpsert(true)
-	_, err := tr.c.UpdateOne(ctx, filter, update, opts)
+	_, err = tr.c.UpdateOne(ctx, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		// a racing login inserted the client's token first, so this one updates it
+		_, err = tr.c.UpdateOne(ctx, filter, update, opts)
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Delete removes a token from the token collection
-func (tr *tokenRepo) Delete(ctx context.Context, clientId primitive.ObjectID) error {
-	filter := bson.D{{"client_id", clientId}}
-	_, err := tr.c.DeleteOne(ctx, filter)
+// FindByClientId finds a token by the clientId in the database
+func (tr *tokenRepo) FindByClientId(ctx context.Context, token *dao.Token) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "client_id", Value: token.ClientId}})
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+
+	err = tr.c.FindOne(ctx, filter).Decode(token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find token: %w", err)
+	}
+	return true, nil
+}
+
+// Delete removes a token from the token collection and reports whether the client had one
+func (tr *tokenRepo) Delete(ctx context.Context, clientId primitive.ObjectID) (bool, error) {
+	filter, err := scopeToTenant(ctx, bson.D{{Key: "client_id", Value: clientId}})
+	if err != nil {
+		return false, err
+	}
+	result, err := tr.c.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
 }
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// maxAuditAppendAttempts is how many times an append is retried when another
+// replica takes the same sequence number first
+const maxAuditAppendAttempts = 5
+
+type auditService struct {
+	auditRepository           interfaces.AuditRepositoryInterface
+	auditCheckpointRepository interfaces.AuditCheckpointRepositoryInterface
+	signingKey                ed25519.PrivateKey
+	checkpointInterval        int64
+	// mu serializes appends from this replica so they do not race each other for sequence numbers
+	mu sync.Mutex
+}
+
+// NewAuditService returns an interface for the audit service methods
+func NewAuditService(cfg *map[string]string, auditRepo interfaces.AuditRepositoryInterface, auditCheckpointRepo interfaces.AuditCheckpointRepositoryInterface) (interfaces.AuditServiceInterface, error) {
+	checkpointInterval, err := strconv.ParseInt((*cfg)[config.AuditCheckpointInterval], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var signingKey ed25519.PrivateKey
+	if encoded := (*cfg)[config.AuditSigningKey]; encoded != "" {
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s must be a base64 encoded %d byte ed25519 seed", config.AuditSigningKey, ed25519.SeedSize)
+		}
+		signingKey = ed25519.NewKeyFromSeed(seed)
+	}
+
+	return &auditService{
+		auditRepository:           auditRepo,
+		auditCheckpointRepository: auditCheckpointRepo,
+		signingKey:                signingKey,
+		checkpointInterval:        checkpointInterval,
+	}, nil
+}
+
+// Record appends an audit event with the details of the request it happened in to the audit chain
+// a failure to store the event is logged rather than failing the request
+func (as *auditService) Record(ctx context.Context, event *dao.AuditEvent) {
+	meta := dto.RequestMetaFromContext(ctx)
+	event.IP = meta.IP
+	event.UserAgent = meta.UserAgent
+	event.RequestId = meta.RequestId
+	event.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	if event.ActorType == "" {
+		event.ActorType = dao.AuditTypeAnonymous
+	}
+
+	if err := as.append(ctx, event); err != nil {
+		log.Printf("Error recording audit event: %s. Error: %v\n", event.Type, err.Error())
+		return
+	}
+
+	// sign a checkpoint of the chain every checkpoint interval events
+	if as.signingKey != nil && as.checkpointInterval > 0 && event.Sequence%as.checkpointInterval == 0 {
+		if _, err := as.checkpoint(ctx, event); err != nil {
+			log.Printf("Error creating audit checkpoint at sequence: %d. Error: %v\n", event.Sequence, err.Error())
+		}
+	}
+}
+
+// append links the event to the end of the audit chain and inserts it
+func (as *auditService) append(ctx context.Context, event *dao.AuditEvent) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < maxAuditAppendAttempts; attempt++ {
+		last := &dao.AuditEvent{}
+		var found bool
+		if found, err = as.auditRepository.FindLast(ctx, last); err != nil {
+			return err
+		}
+
+		event.Sequence, event.PrevHash = 1, ""
+		if found {
+			event.Sequence, event.PrevHash = last.Sequence+1, last.Hash
+		}
+
+		if event.Hash, err = event.ComputeHash(); err != nil {
+			return err
+		}
+
+		err = as.auditRepository.Insert(ctx, event)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to append audit event after %d attempts: %w", maxAuditAppendAttempts, err)
+}
+
+// Query returns a page of the audit events that match the query
+func (as *auditService) Query(ctx context.Context, query *dto.AuditEventQuery) (*dto.PaginatedResponse, error) {
+	events, total, err := as.auditRepository.Find(ctx, query)
+	if err != nil {
+		log.Printf("Error finding audit events. Error: %v\n", err.Error())
+		return nil, errors.ErrInternalServerError("failed to fetch audit events", nil)
+	}
+
+	return dto.NewPaginatedResponse(events, query.Pagination, total), nil
+}
+
+// Verify walks the audit chain and reports every edited, missing or reordered event,
+// then checks that each checkpoint is correctly signed and still matches the chain
+// a checkpoint past the end of the chain means events were deleted from its end
+// a chain that was pruned has to start right after a valid checkpoint that holds the hash it links to
+func (as *auditService) Verify(ctx context.Context) (*dto.AuditVerification, error) {
+	result := &dto.AuditVerification{}
+
+	checkpoints, err := as.auditCheckpointRepository.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var expected int64 = 1
+	prevHash := ""
+	err = as.auditRepository.Walk(ctx, func(event *dao.AuditEvent) error {
+		result.EventsChecked++
+
+		// the start of the chain was pruned if a checkpoint anchors the first event left
+		if result.EventsChecked == 1 && event.Sequence > 1 && anchoredByCheckpoint(event, checkpoints, as.signingKey) {
+			result.PrunedUpTo = event.Sequence - 1
+			expected, prevHash = event.Sequence, event.PrevHash
+		}
+
+		if event.Sequence != expected {
+			result.AddBreak(event.Sequence, "expected sequence %d, events may have been deleted", expected)
+		}
+		if event.PrevHash != prevHash {
+			result.AddBreak(event.Sequence, "previous hash does not match the hash of the event before it")
+		}
+
+		hash, err := event.ComputeHash()
+		if err != nil {
+			return err
+		}
+		if hash != event.Hash {
+			result.AddBreak(event.Sequence, "hash does not match the event, it may have been edited")
+		}
+
+		expected, prevHash = event.Sequence+1, event.Hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range checkpoints {
+		cp := &checkpoints[i]
+		result.CheckpointsChecked++
+
+		if err = verifyAuditCheckpoint(cp, as.signingKey); err != nil {
+			result.AddBreak(cp.Sequence, "checkpoint %s", err.Error())
+			continue
+		}
+
+		// the events of checkpoints before the start of a pruned chain are gone
+		if cp.Sequence <= result.PrunedUpTo {
+			continue
+		}
+
+		event := &dao.AuditEvent{Sequence: cp.Sequence}
+		found, err := as.auditRepository.FindBySequence(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			result.AddBreak(cp.Sequence, "checkpointed event is missing, events may have been deleted")
+			continue
+		}
+		if event.Hash != cp.Hash {
+			result.AddBreak(cp.Sequence, "checkpointed hash does not match the chain")
+		}
+	}
+
+	return result, nil
+}
+
+// Checkpoint signs a checkpoint of the current end of the audit chain
+func (as *auditService) Checkpoint(ctx context.Context) (*dao.AuditCheckpoint, error) {
+	if as.signingKey == nil {
+		return nil, fmt.Errorf("%s is not set", config.AuditSigningKey)
+	}
+
+	last := &dao.AuditEvent{}
+	found, err := as.auditRepository.FindLast(ctx, last)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("the audit chain is empty")
+	}
+
+	return as.checkpoint(ctx, last)
+}
+
+// Checkpoints returns every audit checkpoint in sequence order
+func (as *auditService) Checkpoints(ctx context.Context) ([]dao.AuditCheckpoint, error) {
+	return as.auditCheckpointRepository.FindAll(ctx)
+}
+
+// Prune removes the audit events created before the given time from the start of the chain
+// only the events up to the newest valid checkpoint before that time are removed, so the checkpoint
+// anchors what is left of the chain and a pruned chain can still be told apart from a tampered one
+// it returns how many events were removed
+func (as *auditService) Prune(ctx context.Context, before time.Time) (int64, error) {
+	// only checkpoints signed with our own key can anchor a pruned chain
+	if as.signingKey == nil {
+		return 0, fmt.Errorf("%s is not set", config.AuditSigningKey)
+	}
+
+	checkpoints, err := as.auditCheckpointRepository.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var anchor *dao.AuditCheckpoint
+	for i := len(checkpoints) - 1; i >= 0 && anchor == nil; i-- {
+		cp := &checkpoints[i]
+		if verifyAuditCheckpoint(cp, as.signingKey) != nil {
+			continue
+		}
+
+		event := &dao.AuditEvent{Sequence: cp.Sequence}
+		found, err := as.auditRepository.FindBySequence(ctx, event)
+		if err != nil {
+			return 0, err
+		}
+		if found && event.Hash == cp.Hash && event.CreatedAt.Before(before) {
+			anchor = cp
+		}
+	}
+
+	// nothing old enough is covered by a checkpoint
+	if anchor == nil {
+		return 0, nil
+	}
+
+	as.Record(ctx, dao.NewAuditEvent(dao.AuditEventsPruned, dao.AuditTypeSystem, "", "").With("up_to_sequence", anchor.Sequence))
+
+	return as.auditRepository.DeleteUpTo(ctx, anchor.Sequence)
+}
+
+// checkpoint signs and stores a checkpoint of the chain at the event
+func (as *auditService) checkpoint(ctx context.Context, event *dao.AuditEvent) (*dao.AuditCheckpoint, error) {
+	cp := dao.NewAuditCheckpoint(event)
+	cp.PublicKey = base64.StdEncoding.EncodeToString(as.signingKey.Public().(ed25519.PublicKey))
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(as.signingKey, cp.Message()))
+
+	if err := as.auditCheckpointRepository.Insert(ctx, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// verifyAuditCheckpoint checks the checkpoint signature against its public key,
+// and that the public key is the signing key's if one is configured
+func verifyAuditCheckpoint(cp *dao.AuditCheckpoint, signingKey ed25519.PrivateKey) error {
+	publicKey, err := base64.StdEncoding.DecodeString(cp.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("has an invalid public key")
+	}
+
+	if signingKey != nil && !signingKey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(publicKey)) {
+		return fmt.Errorf("was signed by an unknown key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil || !ed25519.Verify(publicKey, cp.Message(), signature) {
+		return fmt.Errorf("has an invalid signature")
+	}
+
+	return nil
+}
+
+// anchoredByCheckpoint checks if a valid checkpoint holds the hash of the event before the given one
+func anchoredByCheckpoint(event *dao.AuditEvent, checkpoints []dao.AuditCheckpoint, signingKey ed25519.PrivateKey) bool {
+	for i := range checkpoints {
+		cp := &checkpoints[i]
+		if cp.Sequence == event.Sequence-1 && cp.Hash == event.PrevHash && verifyAuditCheckpoint(cp, signingKey) == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type businessTypeService struct {
+	businessTypeRepository interfaces.BusinessTypeRepositoryInterface
+	organizationRepository interfaces.OrganizationRepositoryInterface
+	auditService           interfaces.AuditServiceInterface
+	cacheTTL               time.Duration
+
+	// mu guards the cached business types and when they were read
+	mu       sync.RWMutex
+	cached   []dao.BusinessType
+	loadedAt time.Time
+}
+
+// NewBusinessTypeService returns an interface for the business type service methods
+func NewBusinessTypeService(cfg *map[string]string, businessTypeRepo interfaces.BusinessTypeRepositoryInterface, organizationRepo interfaces.OrganizationRepositoryInterface, auditService interfaces.AuditServiceInterface) (interfaces.BusinessTypeServiceInterface, error) {
+	cacheTTL, err := strconv.Atoi((*cfg)[config.BusinessTypeCacheTTL])
+	if err != nil || cacheTTL < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative number of seconds", config.BusinessTypeCacheTTL)
+	}
+
+	return &businessTypeService{
+		businessTypeRepository: businessTypeRepo,
+		organizationRepository: organizationRepo,
+		auditService:           auditService,
+		cacheTTL:               time.Duration(cacheTTL) * time.Second,
+	}, nil
+}
+
+// businessTypes returns the cached business types, reading them again once the cache is older than its ttl
+// if they cannot be read, the business types that were cached before are used until they can
+func (bs *businessTypeService) businessTypes(ctx context.Context) ([]dao.BusinessType, error) {
+	bs.mu.RLock()
+	cached, loadedAt := bs.cached, bs.loadedAt
+	bs.mu.RUnlock()
+
+	if cached != nil && time.Since(loadedAt) < bs.cacheTTL {
+		return cached, nil
+	}
+
+	businessTypes, err := bs.businessTypeRepository.FindAll(ctx)
+	if err != nil {
+		if cached != nil {
+			log.Printf("Error reading business types, using the cached ones. Error: %v\n", err.Error())
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	bs.mu.Lock()
+	bs.cached, bs.loadedAt = businessTypes, time.Now()
+	bs.mu.Unlock()
+
+	return businessTypes, nil
+}
+
+// invalidate drops the cached business types so the next read sees the latest changes
+func (bs *businessTypeService) invalidate() {
+	bs.mu.Lock()
+	bs.loadedAt = time.Time{}
+	bs.mu.Unlock()
+}
+
+// choosable returns the business types that clients can choose
+// an active subcategory of an inactive sector cannot be chosen
+func choosable(businessTypes []dao.BusinessType) []dao.BusinessType {
+	activeSectors := make(map[primitive.ObjectID]bool)
+	for _, bt := range businessTypes {
+		if bt.ParentId == nil && bt.Active {
+			activeSectors[bt.Id] = true
+		}
+	}
+
+	active := make([]dao.BusinessType, 0, len(businessTypes))
+	for _, bt := range businessTypes {
+		if !bt.Active || (bt.ParentId != nil && !activeSectors[*bt.ParentId]) {
+			continue
+		}
+		active = append(active, bt)
+	}
+	return active
+}
+
+// Validate checks that the business type with the name exists and can be chosen
+// it returns a bad request if it cannot, so it can be returned as it is for the request that chose it
+func (bs *businessTypeService) Validate(ctx context.Context, name string) error {
+	businessTypes, err := bs.businessTypes(ctx)
+	if err != nil {
+		log.Printf("Error reading business types. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to check business type", nil)
+	}
+
+	for _, bt := range choosable(businessTypes) {
+		if bt.Name == name {
+			return nil
+		}
+	}
+	return errors.ErrBadRequest("business type is invalid", []string{fmt.Sprintf("%q is not a business type that can be chosen", name)})
+}
+
+// List returns the business types clients can choose, with the subcategories under their sectors
+func (bs *businessTypeService) List(ctx context.Context) ([]dto.BusinessTypeNode, error) {
+	businessTypes, err := bs.businessTypes(ctx)
+	if err != nil {
+		log.Printf("Error listing business types. Error: %v\n", err.Error())
+		return nil, errors.ErrInternalServerError("failed to list business types", nil)
+	}
+
+	return dto.NewBusinessTypeTree(choosable(businessTypes)), nil
+}
+
+// ListAll returns every business type, including the inactive ones
+func (bs *businessTypeService) ListAll(ctx context.Context) ([]dao.BusinessType, error) {
+	businessTypes, err := bs.businessTypeRepository.FindAll(ctx)
+	if err != nil {
+		log.Printf("Error listing business types. Error: %v\n", err.Error())
+		return nil, errors.ErrInternalServerError("failed to list business types", nil)
+	}
+	return businessTypes, nil
+}
+
+// sector finds the sector a business type is put under
+// it returns a bad request if the id is not a sector, since subcategories cannot have subcategories
+func (bs *businessTypeService) sector(ctx context.Context, parentId string) (*primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(parentId)
+	if err != nil {
+		return nil, errors.ErrBadRequest("invalid parent id", nil)
+	}
+
+	parent := &dao.BusinessType{Id: id}
+	ok, err := bs.businessTypeRepository.FindByID(ctx, parent)
+	if err != nil {
+		log.Printf("Error finding business type with id: %v. Error: %v\n", id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to find parent business type", nil)
+	}
+	if !ok {
+		return nil, errors.ErrBadRequest("parent business type does not exist", nil)
+	}
+	if parent.ParentId != nil {
+		return nil, errors.ErrBadRequest("a subcategory cannot have subcategories", nil)
+	}
+	return &id, nil
+}
+
+// Create creates a business type, as a sector or as a subcategory of one
+func (bs *businessTypeService) Create(ctx context.Context, request *dto.BusinessTypeRequest) (*dao.BusinessType, error) {
+	var parentId *primitive.ObjectID
+	if request.ParentId != "" {
+		var err error
+		if parentId, err = bs.sector(ctx, request.ParentId); err != nil {
+			return nil, err
+		}
+	}
+
+	businessType := dao.NewBusinessType(strings.TrimSpace(request.Name), parentId)
+	if err := bs.businessTypeRepository.Create(ctx, businessType); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrConflict("business type already exists", nil)
+		}
+		log.Printf("Error creating business type. Error: %v\n", err.Error())
+		return nil, errors.ErrInternalServerError("failed to create business type", nil)
+	}
+	bs.invalidate()
+
+	event := dao.NewAuditEvent(dao.AuditBusinessTypeCreated, dao.AuditTypeAdmin, "", "").
+		WithTarget(dao.AuditTypeBusinessType, businessType.Id.Hex()).
+		With("name", businessType.Name)
+	if parentId != nil {
+		event = event.With("parent_id", parentId.Hex())
+	}
+	bs.auditService.Record(ctx, event)
+
+	return businessType, nil
+}
+
+// Update changes the name, sector or active flag of a business type
+// organizations with the business type are moved to its new name when it is renamed
+func (bs *businessTypeService) Update(ctx context.Context, id primitive.ObjectID, update *dto.BusinessTypeUpdate) (*dao.BusinessType, error) {
+	businessType := &dao.BusinessType{Id: id}
+	ok, err := bs.businessTypeRepository.FindByID(ctx, businessType)
+	if err != nil {
+		log.Printf("Error finding business type with id: %v. Error: %v\n", id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to update business type", nil)
+	}
+	if !ok {
+		return nil, errors.ErrNotFound("business type does not exist", nil)
+	}
+
+	oldName := businessType.Name
+	if update.Name != nil {
+		businessType.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Active != nil {
+		businessType.Active = *update.Active
+	}
+	if update.ParentId != nil {
+		if *update.ParentId == "" {
+			businessType.ParentId = nil
+		} else {
+			if *update.ParentId == id.Hex() {
+				return nil, errors.ErrBadRequest("a business type cannot be its own parent", nil)
+			}
+			parentId, err := bs.sector(ctx, *update.ParentId)
+			if err != nil {
+				return nil, err
+			}
+
+			// a sector can only become a subcategory once it has no subcategories of its own
+			children, err := bs.businessTypeRepository.CountChildren(ctx, id)
+			if err != nil {
+				log.Printf("Error counting subcategories of business type with id: %v. Error: %v\n", id, err.Error())
+				return nil, errors.ErrInternalServerError("failed to update business type", nil)
+			}
+			if children > 0 {
+				return nil, errors.ErrBadRequest("a business type with subcategories cannot become a subcategory", nil)
+			}
+			businessType.ParentId = parentId
+		}
+	}
+	businessType.UpdatedAt = time.Now()
+
+	ok, err = bs.businessTypeRepository.Update(ctx, businessType)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrConflict("business type already exists", nil)
+		}
+		log.Printf("Error updating business type with id: %v. Error: %v\n", id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to update business type", nil)
+	}
+	if !ok {
+		return nil, errors.ErrNotFound("business type does not exist", nil)
+	}
+	bs.invalidate()
+
+	event := dao.NewAuditEvent(dao.AuditBusinessTypeUpdated, dao.AuditTypeAdmin, "", "").
+		WithTarget(dao.AuditTypeBusinessType, id.Hex()).
+		With("name", businessType.Name).
+		With("active", businessType.Active)
+	if businessType.Name != oldName {
+		organizationsMoved, err := bs.organizationRepository.RenameBusinessType(ctx, oldName, businessType.Name)
+		if err != nil {
+			log.Printf("Error moving organizations from business type %q to %q. Error: %v\n", oldName, businessType.Name, err.Error())
+			return nil, errors.ErrInternalServerError("failed to move organizations to the renamed business type", nil)
+		}
+		event = event.With("old_name", oldName).With("organizations_moved", organizationsMoved)
+	}
+	bs.auditService.Record(ctx, event)
+
+	return businessType, nil
+}
+
+// Delete removes a business type that has no subcategories and that no organization has
+// a business type that is still in use can be deactivated instead, so it cannot be chosen anymore
+func (bs *businessTypeService) Delete(ctx context.Context, id primitive.ObjectID) error {
+	businessType := &dao.BusinessType{Id: id}
+	ok, err := bs.businessTypeRepository.FindByID(ctx, businessType)
+	if err != nil {
+		log.Printf("Error finding business type with id: %v. Error: %v\n", id, err.Error())
+		return errors.ErrInternalServerError("failed to delete business type", nil)
+	}
+	if !ok {
+		return errors.ErrNotFound("business type does not exist", nil)
+	}
+
+	children, err := bs.businessTypeRepository.CountChildren(ctx, id)
+	if err != nil {
+		log.Printf("Error counting subcategories of business type with id: %v. Error: %v\n", id, err.Error())
+		return errors.ErrInternalServerError("failed to delete business type", nil)
+	}
+	if children > 0 {
+		return errors.ErrConflict("business type has subcategories, delete or move them first", nil)
+	}
+
+	inUse, err := bs.organizationRepository.CountByBusinessType(ctx, businessType.Name)
+	if err != nil {
+		log.Printf("Error counting organizations with business type with id: %v. Error: %v\n", id, err.Error())
+		return errors.ErrInternalServerError("failed to delete business type", nil)
+	}
+	if inUse > 0 {
+		return errors.ErrConflict("business type is still used by organizations, deactivate it instead", nil)
+	}
+
+	ok, err = bs.businessTypeRepository.Delete(ctx, id)
+	if err != nil {
+		log.Printf("Error deleting business type with id: %v. Error: %v\n", id, err.Error())
+		return errors.ErrInternalServerError("failed to delete business type", nil)
+	}
+	if !ok {
+		return errors.ErrNotFound("business type does not exist", nil)
+	}
+	bs.invalidate()
+
+	bs.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditBusinessTypeDeleted, dao.AuditTypeAdmin, "", "").
+		WithTarget(dao.AuditTypeBusinessType, id.Hex()))
+
+	return nil
+}
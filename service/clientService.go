@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/leonardchinonso/auth_service_cmp7174/errors"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
@@ -15,52 +19,90 @@ import (
 type clientService struct {
 	clientRepository  interfaces.ClientRepositoryInterface
 	tokenRepository interfaces.TokenRepositoryInterface
+	loginGuard interfaces.LoginGuardServiceInterface
+	passwordService interfaces.PasswordServiceInterface
+	passwordHasher interfaces.PasswordHasherInterface
+	auditService interfaces.AuditServiceInterface
+	emailChangeService interfaces.EmailChangeServiceInterface
 }
 
 // NewClientService returns an interface for the client service methods
-func NewClientService(clientRepo interfaces.ClientRepositoryInterface, tokenRepo interfaces.TokenRepositoryInterface) interfaces.ClientServiceInterface {
+func NewClientService(clientRepo interfaces.ClientRepositoryInterface, tokenRepo interfaces.TokenRepositoryInterface, loginGuard interfaces.LoginGuardServiceInterface, passwordService interfaces.PasswordServiceInterface, passwordHasher interfaces.PasswordHasherInterface, auditService interfaces.AuditServiceInterface, emailChangeService interfaces.EmailChangeServiceInterface) interfaces.ClientServiceInterface {
 	return &clientService{
 		clientRepository:  clientRepo,
 		tokenRepository: tokenRepo,
+		loginGuard: loginGuard,
+		passwordService: passwordService,
+		passwordHasher: passwordHasher,
+		auditService: auditService,
+		emailChangeService: emailChangeService,
 	}
 }
 
 // Signup handles the client creation and logs the client in
 func (us *clientService) Signup(ctx context.Context, client *dao.Client, password dto.Password) (primitive.ObjectID, error) {
+	// check the password against the password policy
+	failures, err := us.passwordService.Validate(ctx, password, client)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	if len(failures) > 0 {
+		return primitive.ObjectID{}, errors.ErrBadRequest("password does not meet the password policy", failures)
+	}
+
 	// hash the password to hide its real value
-	hashedPassword, err := password.Hash()
+	hashedPassword, err := us.passwordHasher.Hash(ctx, string(password))
 	if err != nil {
-		log.Printf("Error hashing client password: %s. Error: %v\n", password, err.Error())
+		log.Printf("Error hashing client password. Error: %v\n", err.Error())
+		if re, ok := errors.AsRestError(err); ok {
+			return primitive.ObjectID{}, re
+		}
 		return primitive.ObjectID{}, errors.ErrInternalServerError("failed to sign up client", err)
 	}
 
 	// update the client password to its hashed value
 	client.Password = hashedPassword
 
-	// check that the email is not taken
-	clientExists, err := us.clientRepository.FindByEmail(ctx, client)
-	if err != nil {
-		log.Printf("Error finding client with email: %s. Error: %v\n", client.Email, err.Error())
-		return primitive.ObjectID{}, errors.ErrInternalServerError("failed to fetch client details", err)
-	}
-
-	// if the email already exists, return an error saying the email is taken
-	if clientExists {
-		return primitive.ObjectID{}, errors.ErrBadRequest("sorry, email is taken", nil)
-	}
-
 	// create a new client with the credentials
+	// the unique index on the email rejects a taken email, even when two signups race for it
 	insertedId, err := us.clientRepository.Create(ctx, client)
+	if mongo.IsDuplicateKeyError(err) {
+		return primitive.ObjectID{}, errors.ErrConflict("sorry, email is taken", nil)
+	}
 	if err != nil {
 		log.Printf("Error creating client with email: %s. Error: %v\n", client.Email, err.Error())
 		return primitive.ObjectID{}, errors.ErrInternalServerError("failed to sign up client", err)
 	}
 
+	client.Id = insertedId
+	us.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditSignup, client))
+
 	return insertedId, nil
 }
 
+// RevertSignup removes a client whose signup could not be finished, so they can sign up with the email again
+func (us *clientService) RevertSignup(ctx context.Context, client *dao.Client) error {
+	ok, err := us.clientRepository.Delete(ctx, client.Id)
+	if err != nil {
+		log.Printf("Error removing client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to revert signup", nil)
+	}
+	if ok {
+		us.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditSignupReverted, client))
+	}
+	return nil
+}
+
 // Login logs the client into the application and returns the authentication tokens
 func (us *clientService) Login(ctx context.Context, client *dao.Client, password dto.Password) error {
+	meta := dto.RequestMetaFromContext(ctx)
+
+	// refuse the attempt if the account is locked or the email or ip is backing off
+	if err := us.loginGuard.Check(ctx, client.Email, meta.IP); err != nil {
+		us.auditService.Record(ctx, loginFailureEvent(client.Email, "blocked"))
+		return err
+	}
+
 	// find the client by email and password
 	clientExists, err := us.clientRepository.FindByEmail(ctx, client)
 	if err != nil { // if an unexpected error occurs
@@ -68,16 +110,41 @@ func (us *clientService) Login(ctx context.Context, client *dao.Client, password
 		return errors.ErrInternalServerError("failed to fetch client details", err)
 	}
 
-	// if the client does not exist, then the password and/or email are wrong
-	if !clientExists {
-		return errors.ErrUnauthorized(errors.ErrInvalidLogin, nil)
+	// the password can only be checked if the client exists
+	passwordCorrect := false
+	if clientExists {
+		passwordCorrect, err = us.isPasswordCorrect(ctx, password, client)
+		if err != nil {
+			return err
+		}
 	}
 
-	// if the client exists, but the password is not correct
-	if !password.IsEqualHash(client.Password) {
+	// if the client does not exist or the password is not correct, then the password and/or email are wrong
+	if !passwordCorrect {
+		reason := "wrong_password"
+		if !clientExists {
+			reason = "unknown_email"
+		}
+		us.auditService.Record(ctx, loginFailureEvent(client.Email, reason))
+
+		if err = us.loginGuard.RecordFailure(ctx, client.Email, meta.IP); err != nil {
+			log.Printf("Error recording failed login for email: %s. Error: %v\n", client.Email, err.Error())
+		}
 		return errors.ErrUnauthorized(errors.ErrInvalidLogin, nil)
 	}
 
+	// upgrade the stored hash if it was made with an outdated algorithm or parameters
+	if us.passwordHasher.NeedsRehash(client.Password) {
+		us.rehashPassword(ctx, client, password)
+	}
+
+	// a successful login clears the failed attempts for the account
+	if err = us.loginGuard.RecordSuccess(ctx, client.Email); err != nil {
+		log.Printf("Error clearing failed logins for email: %s. Error: %v\n", client.Email, err.Error())
+	}
+
+	us.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditLoginSuccess, client))
+
 	return nil
 }
 
@@ -87,12 +154,16 @@ func (us *clientService) Logout(ctx context.Context, clientId primitive.ObjectID
 		ClientId: clientId,
 	}
 
-	err := us.tokenRepository.Delete(ctx, token.ClientId)
+	_, err := us.tokenRepository.Delete(ctx, token.ClientId)
 	if err != nil {
 		log.Printf("Error trying to delete token with clientId: %v. Error: %v\n", clientId, err.Error())
 		return errors.ErrInternalServerError("failed to log client out", err)
 	}
 
+	us.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditTokenRevoked, dao.AuditTypeClient, clientId.Hex(), "").
+		WithTarget(dao.AuditTypeClient, clientId.Hex()).
+		With("reason", "logout"))
+
 	return nil
 }
 
@@ -121,33 +192,166 @@ func (us *clientService) GetClientByID(ctx context.Context, clientId primitive.O
 	return client, nil
 }
 
-func (us *clientService) EditClientProfile(ctx context.Context, client *dao.Client) error {
-	// check that the client id is not empty
-	if client.Id.IsZero() {
-		log.Printf("Error validating client Id: %v\n", client.Id)
-		return errors.ErrBadRequest("invalid client id", nil)
+// EditClientProfile applies the patch to the client's profile if it is still at the version the patch was made against
+// only the fields the patch changes are written, and a patch that changes nothing does not bump the version
+// a patch made against dto.AnyVersion applies to the current version
+// the email is what the client signs in with, so a new email is not written but starts an email change
+// that has to be confirmed from the new address and can be cancelled from the old one
+func (us *clientService) EditClientProfile(ctx context.Context, clientId primitive.ObjectID, version int64, patch *dto.ClientPatch) (*dao.Client, error) {
+	current, err := us.GetClientByID(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == dto.AnyVersion {
+		version = current.Version
+	}
+	if current.Version != version {
+		return nil, errors.ErrPreconditionFailed("the profile was changed since it was read, fetch it again and retry", map[string]int64{"version": current.Version})
+	}
+
+	client := *current
+	client.Version = version
+	patch.Apply(&client)
+
+	changes := current.Changes(&client)
+
+	newEmail, emailChanged := changes["email"].(string)
+	if emailChanged {
+		delete(changes, "email")
+		client.Email = current.Email
+	}
+
+	// check that the email is not taken before asking the new address to confirm it
+	if emailChanged {
+		clientChecker := &dao.Client{Email: newEmail}
+		clientExists, err := us.clientRepository.FindByEmail(ctx, clientChecker)
+		if err != nil {
+			log.Printf("Error finding client with email: %s. Error: %v\n", newEmail, err.Error())
+			return nil, errors.ErrInternalServerError("failed to fetch client details", err)
+		}
+
+		// if the email already exists, return an error saying the email is taken
+		if clientExists {
+			return nil, errors.ErrConflict("sorry, email is taken", nil)
+		}
+	}
+
+	if len(changes) > 0 {
+		// update the client with the new information if nobody else updated it since the version it was made against
+		client.UpdatedAt = time.Now()
+		updated, err := us.clientRepository.Update(ctx, &client, changes)
+		if err != nil {
+			log.Printf("Error updating client with id: %v. Error: %v\n", client.Id, err.Error())
+			return nil, errors.ErrInternalServerError("failed to update client information", nil)
+		}
+
+		if !updated {
+			return nil, errors.ErrPreconditionFailed("the profile was changed since it was read, fetch it again and retry", nil)
+		}
+
+		fields := make([]string, 0, len(changes))
+		for field := range changes {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		us.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditProfileUpdated, &client).With("fields", strings.Join(fields, ",")))
+	}
+
+	if emailChanged {
+		if err = us.emailChangeService.Request(ctx, &client, newEmail); err != nil {
+			return nil, err
+		}
 	}
 
-	clientChecker := &dao.Client{Email: client.Email}
+	return &client, nil
+}
 
-	// check that the email is not taken
-	clientExists, err := us.clientRepository.FindByEmail(ctx, clientChecker)
+// ChangePassword changes a client's password after checking their current password
+func (us *clientService) ChangePassword(ctx context.Context, clientId primitive.ObjectID, currentPassword, newPassword dto.Password) error {
+	client, err := us.GetClientByID(ctx, clientId)
 	if err != nil {
-		log.Printf("Error finding client with email: %s. Error: %v\n", client.Email, err.Error())
-		return errors.ErrInternalServerError("failed to fetch client details", err)
+		return err
+	}
+
+	// the current password has to be correct to change it
+	passwordCorrect, err := us.isPasswordCorrect(ctx, currentPassword, client)
+	if err != nil {
+		return err
+	}
+	if !passwordCorrect {
+		return errors.ErrUnauthorized("current password is incorrect", nil)
 	}
 
-	// if the email already exists, return an error saying the email is taken
-	if clientExists && client.Id != clientChecker.Id {
-		return errors.ErrBadRequest("sorry, email is taken", nil)
+	// check the new password against the password policy
+	failures, err := us.passwordService.Validate(ctx, newPassword, client)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return errors.ErrBadRequest("password does not meet the password policy", failures)
 	}
 
-	// update the client with the new information
-	err = us.clientRepository.Update(ctx, client)
+	// hash the password to hide its real value
+	hashedPassword, err := us.passwordHasher.Hash(ctx, string(newPassword))
 	if err != nil {
-		log.Printf("Error updating client with id: %v. Error: %v\n", client.Id, err.Error())
-		return errors.ErrInternalServerError("failed to update client information", nil)
+		log.Printf("Error hashing password for client with id: %v. Error: %v\n", client.Id, err.Error())
+		if re, ok := errors.AsRestError(err); ok {
+			return re
+		}
+		return errors.ErrInternalServerError("failed to change password", nil)
+	}
+
+	// remember the old password so it cannot be reused
+	us.passwordService.AddToHistory(client, client.Password)
+	client.Password = hashedPassword
+	client.UpdatedAt = time.Now()
+
+	if err = us.clientRepository.UpdatePassword(ctx, client); err != nil {
+		log.Printf("Error updating password for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to change password", nil)
 	}
 
+	us.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditPasswordChanged, client))
+
 	return nil
 }
+
+// isPasswordCorrect checks a password against the client's stored hash
+// it only returns an error if the password could not be checked, e.g. when the hasher is too busy
+func (us *clientService) isPasswordCorrect(ctx context.Context, password dto.Password, client *dao.Client) (bool, error) {
+	ok, err := us.passwordHasher.Verify(ctx, string(password), client.Password)
+	if err != nil {
+		if re, isRestErr := errors.AsRestError(err); isRestErr {
+			return false, re
+		}
+		log.Printf("Error verifying password for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return false, nil
+	}
+	return ok, nil
+}
+
+// rehashPassword replaces the client's stored hash with one made by the preferred algorithm
+// a failure is only logged since the client has already logged in successfully
+func (us *clientService) rehashPassword(ctx context.Context, client *dao.Client, password dto.Password) {
+	hashedPassword, err := us.passwordHasher.Hash(ctx, string(password))
+	if err != nil {
+		log.Printf("Error rehashing password for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+
+	client.Password = hashedPassword
+	if err = us.clientRepository.UpdatePassword(ctx, client); err != nil {
+		log.Printf("Error saving rehashed password for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+
+	us.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditPasswordRehashed, client))
+}
+
+// loginFailureEvent creates the audit event for a failed login to the email
+// the actor is anonymous since the attempt did not prove who made it
+func loginFailureEvent(email, reason string) *dao.AuditEvent {
+	return dao.NewAuditEvent(dao.AuditLoginFailure, dao.AuditTypeAnonymous, "", email).
+		With("reason", reason)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/templates"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+type emailChangeService struct {
+	clientRepository      interfaces.ClientRepositoryInterface
+	tokenRepository       interfaces.TokenRepositoryInterface
+	emailChangeRepository interfaces.EmailChangeRepositoryInterface
+	auditService          interfaces.AuditServiceInterface
+	mailer                interfaces.MailerInterface
+	confirmURL            string
+	cancelURL             string
+	expiresIn             time.Duration
+}
+
+// NewEmailChangeService returns an interface for the email change service methods
+func NewEmailChangeService(cfg *map[string]string, clientRepo interfaces.ClientRepositoryInterface, tokenRepo interfaces.TokenRepositoryInterface, emailChangeRepo interfaces.EmailChangeRepositoryInterface, auditService interfaces.AuditServiceInterface, mailer interfaces.MailerInterface) (interfaces.EmailChangeServiceInterface, error) {
+	expiresIn, err := strconv.Atoi((*cfg)[config.EmailChangeExpiresIn])
+	if err != nil || expiresIn <= 0 {
+		return nil, fmt.Errorf("%s must be a positive number of seconds", config.EmailChangeExpiresIn)
+	}
+
+	return &emailChangeService{
+		clientRepository:      clientRepo,
+		tokenRepository:       tokenRepo,
+		emailChangeRepository: emailChangeRepo,
+		auditService:          auditService,
+		mailer:                mailer,
+		confirmURL:            (*cfg)[config.EmailChangeConfirmURL],
+		cancelURL:             (*cfg)[config.EmailChangeCancelURL],
+		expiresIn:             time.Duration(expiresIn) * time.Second,
+	}, nil
+}
+
+// Request starts a change of the client's email to the new address
+// the new address is sent a link to confirm the change and the old address is sent a link to cancel it,
+// and a change that was requested before and not confirmed yet stops working
+func (es *emailChangeService) Request(ctx context.Context, client *dao.Client, newEmail string) error {
+	if err := es.emailChangeRepository.SupersedePending(ctx, client.Id); err != nil {
+		log.Printf("Error superseding email changes for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to request email change", nil)
+	}
+
+	confirmToken, err := utils.RandomToken(32)
+	if err != nil {
+		log.Printf("Error generating email change confirm token. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to request email change", nil)
+	}
+	cancelToken, err := utils.RandomToken(32)
+	if err != nil {
+		log.Printf("Error generating email change cancel token. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to request email change", nil)
+	}
+
+	change := dao.NewEmailChange(client, newEmail, confirmToken, cancelToken, es.expiresIn)
+	if err = es.emailChangeRepository.Create(ctx, change); err != nil {
+		log.Printf("Error creating email change for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to request email change", nil)
+	}
+
+	confirmData := dto.EmailChangeConfirmMail{
+		Name:        client.Name,
+		NewEmail:    newEmail,
+		ConfirmLink: tenantLink(ctx, config.EmailChangeConfirmURL, es.confirmURL, confirmToken),
+		ExpiresIn:   es.expiresIn.String(),
+	}
+	if err = es.mailer.Send(ctx, newEmail, client.Locale, templates.MailEmailChangeConfirm, confirmData); err != nil {
+		log.Printf("Error sending email change confirmation to email: %s. Error: %v\n", newEmail, err.Error())
+		return errors.ErrInternalServerError("failed to request email change", nil)
+	}
+
+	noticeData := dto.EmailChangeNoticeMail{
+		Name:       client.Name,
+		NewEmail:   newEmail,
+		CancelLink: tenantLink(ctx, config.EmailChangeCancelURL, es.cancelURL, cancelToken),
+		ExpiresIn:  es.expiresIn.String(),
+	}
+	if err = es.mailer.Send(ctx, client.Email, client.Locale, templates.MailEmailChangeNotice, noticeData); err != nil {
+		log.Printf("Error sending email change notice to email: %s. Error: %v\n", client.Email, err.Error())
+		return errors.ErrInternalServerError("failed to request email change", nil)
+	}
+
+	es.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditEmailChangeRequested, client).With("new_email", newEmail))
+
+	return nil
+}
+
+// Confirm switches the client's email to the new address of the change the confirm token was issued for
+// the new address is checked again since another client may have taken it after the change was requested
+func (es *emailChangeService) Confirm(ctx context.Context, token string) error {
+	change, err := es.emailChangeRepository.FindByConfirmToken(ctx, token)
+	if err != nil {
+		log.Printf("Error finding email change by confirm token. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to confirm email change", nil)
+	}
+	if change == nil || change.Status != dao.EmailChangePending {
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+
+	clientChecker := &dao.Client{Email: change.NewEmail}
+	emailTaken, err := es.clientRepository.FindByEmail(ctx, clientChecker)
+	if err != nil {
+		log.Printf("Error finding client with email: %s. Error: %v\n", change.NewEmail, err.Error())
+		return errors.ErrInternalServerError("failed to confirm email change", nil)
+	}
+	if emailTaken {
+		es.fail(ctx, change, dao.EmailChangePending)
+		return errors.ErrConflict("sorry, email is taken", nil)
+	}
+
+	// only one of a confirm and a cancel racing for the change gets to move it out of pending
+	confirmed, err := es.emailChangeRepository.Transition(ctx, change.Id, dao.EmailChangePending, dao.EmailChangeConfirmed)
+	if err != nil {
+		log.Printf("Error confirming email change with id: %v. Error: %v\n", change.Id, err.Error())
+		return errors.ErrInternalServerError("failed to confirm email change", nil)
+	}
+	if !confirmed {
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+
+	// the unique index on the email still rejects it if another client took it in the meantime,
+	// and the change is made against the profile version it was requested at like any other profile update
+	replaced, err := es.clientRepository.ReplaceEmail(ctx, change.ClientId, change.OldEmail, change.NewEmail, change.ClientVersion)
+	if mongo.IsDuplicateKeyError(err) {
+		es.fail(ctx, change, dao.EmailChangeConfirmed)
+		return errors.ErrConflict("sorry, email is taken", nil)
+	}
+	if err != nil {
+		log.Printf("Error replacing email of client with id: %v. Error: %v\n", change.ClientId, err.Error())
+		return errors.ErrInternalServerError("failed to confirm email change", nil)
+	}
+	if !replaced {
+		es.fail(ctx, change, dao.EmailChangeConfirmed)
+		return errors.ErrConflict("the profile was changed since the link was sent, request the email change again", nil)
+	}
+
+	es.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditEmailChangeConfirmed, dao.AuditTypeClient, change.ClientId.Hex(), change.NewEmail).
+		WithTarget(dao.AuditTypeClient, change.ClientId.Hex()).
+		With("old_email", change.OldEmail))
+
+	return nil
+}
+
+// Cancel stops the change the cancel token was issued for, and switches the client's email back if it was confirmed already
+// the change may have been made from a stolen session, so every session of the client is ended too
+func (es *emailChangeService) Cancel(ctx context.Context, token string) error {
+	change, err := es.emailChangeRepository.FindByCancelToken(ctx, token)
+	if err != nil {
+		log.Printf("Error finding email change by cancel token. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to cancel email change", nil)
+	}
+	if change == nil {
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+
+	cancelled, err := es.emailChangeRepository.Transition(ctx, change.Id, dao.EmailChangePending, dao.EmailChangeCancelled)
+	if err != nil {
+		log.Printf("Error cancelling email change with id: %v. Error: %v\n", change.Id, err.Error())
+		return errors.ErrInternalServerError("failed to cancel email change", nil)
+	}
+
+	reverted := false
+	if !cancelled {
+		// the change may already have been confirmed from the new address
+		reverted, err = es.emailChangeRepository.Transition(ctx, change.Id, dao.EmailChangeConfirmed, dao.EmailChangeReverted)
+		if err != nil {
+			log.Printf("Error reverting email change with id: %v. Error: %v\n", change.Id, err.Error())
+			return errors.ErrInternalServerError("failed to cancel email change", nil)
+		}
+		if !reverted {
+			return errors.ErrUnauthorized("invalid or expired link", nil)
+		}
+
+		// a cancel ends a change the client may not have made, so it is reverted whatever version the profile is at
+		replaced, err := es.clientRepository.ReplaceEmail(ctx, change.ClientId, change.NewEmail, change.OldEmail, 0)
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.ErrConflict("the old email was taken by another account", nil)
+		}
+		if err != nil {
+			log.Printf("Error reverting email of client with id: %v. Error: %v\n", change.ClientId, err.Error())
+			return errors.ErrInternalServerError("failed to cancel email change", nil)
+		}
+		if !replaced {
+			log.Printf("Email of client with id: %v was changed again before the change with id: %v was reverted\n", change.ClientId, change.Id)
+		}
+	}
+
+	if _, err = es.clientRepository.RevokeSessions(ctx, change.ClientId, time.Now()); err != nil {
+		log.Printf("Error revoking sessions for client with id: %v. Error: %v\n", change.ClientId, err.Error())
+		return errors.ErrInternalServerError("failed to sign out of all sessions", nil)
+	}
+	if _, err = es.tokenRepository.Delete(ctx, change.ClientId); err != nil {
+		log.Printf("Error revoking tokens for client with id: %v. Error: %v\n", change.ClientId, err.Error())
+		return errors.ErrInternalServerError("failed to sign out of all sessions", nil)
+	}
+
+	es.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditEmailChangeCancelled, dao.AuditTypeClient, change.ClientId.Hex(), change.OldEmail).
+		WithTarget(dao.AuditTypeClient, change.ClientId.Hex()).
+		With("new_email", change.NewEmail).
+		With("reverted", reverted))
+	es.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditTokenRevoked, dao.AuditTypeClient, change.ClientId.Hex(), change.OldEmail).
+		WithTarget(dao.AuditTypeClient, change.ClientId.Hex()).
+		With("reason", "email_change_cancelled"))
+
+	return nil
+}
+
+// fail marks an email change that can no longer be applied as failed, so its links stop working
+func (es *emailChangeService) fail(ctx context.Context, change *dao.EmailChange, from string) {
+	if _, err := es.emailChangeRepository.Transition(ctx, change.Id, from, dao.EmailChangeFailed); err != nil {
+		log.Printf("Error failing email change with id: %v. Error: %v\n", change.Id, err.Error())
+	}
+}
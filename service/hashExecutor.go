@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// hashMetrics are the password hashing metrics published on the expvar endpoint
+var hashMetrics = expvar.NewMap("password_hashing")
+
+// hashQueueWaitBuckets are the upper bounds of the cumulative queue wait time histogram
+var hashQueueWaitBuckets = []struct {
+	name  string
+	bound time.Duration
+}{
+	{"queue_wait_le_1ms", time.Millisecond},
+	{"queue_wait_le_10ms", 10 * time.Millisecond},
+	{"queue_wait_le_100ms", 100 * time.Millisecond},
+	{"queue_wait_le_1s", time.Second},
+}
+
+// hashExecutor runs password hashing on a bounded number of slots with a bounded queue
+// so a flood of logins or signups cannot take every CPU away from the rest of the service
+type hashExecutor struct {
+	slots      chan struct{}
+	queueDepth int64
+	queued     int64
+	retryAfter time.Duration
+}
+
+// boundedPasswordHasher is a password hasher that runs the hashing through a hash executor
+type boundedPasswordHasher struct {
+	hasher   interfaces.PasswordHasherInterface
+	executor *hashExecutor
+}
+
+// NewBoundedPasswordHasher returns a password hasher that limits how many hashes run at once
+// callers get a 503 with a Retry-After hint when the queue is full
+func NewBoundedPasswordHasher(cfg *map[string]string, hasher interfaces.PasswordHasherInterface) (interfaces.PasswordHasherInterface, error) {
+	concurrency, err := strconv.Atoi((*cfg)[config.PasswordHashConcurrency])
+	if err != nil {
+		return nil, err
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	queueDepth, err := strconv.Atoi((*cfg)[config.PasswordHashQueueDepth])
+	if err != nil {
+		return nil, err
+	}
+
+	retryAfter, err := strconv.Atoi((*cfg)[config.PasswordHashRetryAfter])
+	if err != nil {
+		return nil, err
+	}
+
+	executor := &hashExecutor{
+		slots:      make(chan struct{}, concurrency),
+		queueDepth: int64(queueDepth),
+		retryAfter: time.Duration(retryAfter) * time.Second,
+	}
+
+	hashMetrics.Set("concurrency", expvar.Func(func() interface{} { return cap(executor.slots) }))
+	hashMetrics.Set("active", expvar.Func(func() interface{} { return len(executor.slots) }))
+	hashMetrics.Set("queued", expvar.Func(func() interface{} { return atomic.LoadInt64(&executor.queued) }))
+
+	return &boundedPasswordHasher{
+		hasher:   hasher,
+		executor: executor,
+	}, nil
+}
+
+// Hash hashes a password once a hashing slot is free
+func (bh *boundedPasswordHasher) Hash(ctx context.Context, password string) (string, error) {
+	var hash string
+	var err error
+	if execErr := bh.executor.run(ctx, func() { hash, err = bh.hasher.Hash(ctx, password) }); execErr != nil {
+		return "", execErr
+	}
+	return hash, err
+}
+
+// Verify checks a password against a hash once a hashing slot is free
+func (bh *boundedPasswordHasher) Verify(ctx context.Context, password, hash string) (bool, error) {
+	var ok bool
+	var err error
+	if execErr := bh.executor.run(ctx, func() { ok, err = bh.hasher.Verify(ctx, password, hash) }); execErr != nil {
+		return false, execErr
+	}
+	return ok, err
+}
+
+// NeedsRehash checks if a hash is outdated, which is cheap enough to skip the executor
+func (bh *boundedPasswordHasher) NeedsRehash(hash string) bool {
+	return bh.hasher.NeedsRehash(hash)
+}
+
+// run waits for a free slot and runs fn on it
+// it fails straight away if the queue of callers waiting for a slot is full,
+// and stops waiting once the request is done, e.g. because the client went away
+func (he *hashExecutor) run(ctx context.Context, fn func()) error {
+	if atomic.AddInt64(&he.queued, 1) > he.queueDepth {
+		atomic.AddInt64(&he.queued, -1)
+		hashMetrics.Add("rejected", 1)
+		return errors.ErrServiceUnavailable("the service is busy, please try again shortly", he.retryAfter, nil)
+	}
+
+	start := time.Now()
+	select {
+	case he.slots <- struct{}{}:
+	case <-dto.RequestDone(ctx):
+		atomic.AddInt64(&he.queued, -1)
+		hashMetrics.Add("abandoned", 1)
+		return fmt.Errorf("stopped waiting for a hashing slot as the request is done")
+	}
+	atomic.AddInt64(&he.queued, -1)
+	recordQueueWait(time.Since(start))
+
+	defer func() { <-he.slots }()
+	fn()
+
+	return nil
+}
+
+// recordQueueWait records how long a caller waited for a hashing slot
+func recordQueueWait(wait time.Duration) {
+	hashMetrics.Add("queue_wait_count", 1)
+	hashMetrics.AddFloat("queue_wait_seconds_total", wait.Seconds())
+
+	for _, b := range hashQueueWaitBuckets {
+		if wait <= b.bound {
+			hashMetrics.Add(b.name, 1)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHashExecutorStopsWaitingWhenRequestIsDone(t *testing.T) {
+	he := &hashExecutor{slots: make(chan struct{}, 1), queueDepth: 1}
+	he.slots <- struct{}{} // every slot is taken
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ran := false
+	if err := he.run(ctx, func() { ran = true }); err == nil {
+		t.Fatalf("waiting for a slot did not stop when the request was done")
+	}
+	if ran {
+		t.Errorf("ran the hash of a request that was done")
+	}
+	if queued := atomic.LoadInt64(&he.queued); queued != 0 {
+		t.Errorf("%d callers are still queued, want none", queued)
+	}
+}
@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/templates"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// invitationAudience is the audience set on invitation tokens so they cannot be used as any other token
+const invitationAudience = "invitation"
+
+type invitationService struct {
+	clientRepository       interfaces.ClientRepositoryInterface
+	clientService          interfaces.ClientServiceInterface
+	organizationRepository interfaces.OrganizationRepositoryInterface
+	membershipRepository   interfaces.MembershipRepositoryInterface
+	invitationRepository   interfaces.InvitationRepositoryInterface
+	auditService           interfaces.AuditServiceInterface
+	mailer                 interfaces.MailerInterface
+	secret                 string
+	linkURL                string
+	expiresIn              time.Duration
+}
+
+// NewInvitationService returns an interface for the invitation service methods
+func NewInvitationService(cfg *map[string]string, clientRepo interfaces.ClientRepositoryInterface, clientService interfaces.ClientServiceInterface, organizationRepo interfaces.OrganizationRepositoryInterface, membershipRepo interfaces.MembershipRepositoryInterface, invitationRepo interfaces.InvitationRepositoryInterface, auditService interfaces.AuditServiceInterface, mailer interfaces.MailerInterface) (interfaces.InvitationServiceInterface, error) {
+	expiresIn, err := strconv.Atoi((*cfg)[config.InvitationExpiresIn])
+	if err != nil || expiresIn <= 0 {
+		return nil, fmt.Errorf("%s must be a positive number of seconds", config.InvitationExpiresIn)
+	}
+
+	return &invitationService{
+		clientRepository:       clientRepo,
+		clientService:          clientService,
+		organizationRepository: organizationRepo,
+		membershipRepository:   membershipRepo,
+		invitationRepository:   invitationRepo,
+		auditService:           auditService,
+		mailer:                 mailer,
+		secret:                 (*cfg)[config.InvitationSecretKey],
+		linkURL:                (*cfg)[config.InvitationURL],
+		expiresIn:              time.Duration(expiresIn) * time.Second,
+	}, nil
+}
+
+// Enabled reports whether organizations can invite members, which needs a key to sign the invitation links with
+func (is *invitationService) Enabled() bool {
+	return is.secret != ""
+}
+
+// manager finds the membership of a client that invites members to an organization
+// only owners and admins can, and they cannot hand out a higher role than their own
+func (is *invitationService) manager(ctx context.Context, clientId, organizationId primitive.ObjectID, role dao.Role) (*dao.Membership, error) {
+	membership, err := findMembership(ctx, is.membershipRepository, clientId, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	if !membership.Role.AtLeast(dao.RoleAdmin) {
+		return nil, errors.ErrForbidden("only owners and admins can manage invitations", nil)
+	}
+	if !membership.Role.AtLeast(role) {
+		return nil, errors.ErrForbidden("only owners can manage invitations for owners", nil)
+	}
+	return membership, nil
+}
+
+// Invite invites the email to join the organization with the role
+func (is *invitationService) Invite(ctx context.Context, client *dao.Client, organizationId primitive.ObjectID, request *dto.InvitationRequest) (*dao.Invitation, error) {
+	if _, err := is.manager(ctx, client.Id, organizationId, request.Role); err != nil {
+		return nil, err
+	}
+	email := string(request.Email)
+
+	// an email that already belongs to a member does not need an invitation
+	invitee := &dao.Client{Email: email}
+	exists, err := is.clientRepository.FindByEmail(ctx, invitee)
+	if err != nil {
+		log.Printf("Error finding client with email: %s. Error: %v\n", email, err.Error())
+		return nil, errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+	if exists {
+		member, err := is.membershipRepository.Find(ctx, &dao.Membership{OrganizationId: organizationId, ClientId: invitee.Id})
+		if err != nil {
+			log.Printf("Error finding membership of client with id: %v in organization: %v. Error: %v\n", invitee.Id, organizationId, err.Error())
+			return nil, errors.ErrInternalServerError("failed to send invitation", nil)
+		}
+		if member {
+			return nil, errors.ErrConflict("the email already belongs to a member of the organization", nil)
+		}
+	}
+
+	pending, err := is.invitationRepository.HasPending(ctx, organizationId, email)
+	if err != nil {
+		log.Printf("Error finding invitations for email: %s. Error: %v\n", email, err.Error())
+		return nil, errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+	if pending {
+		return nil, errors.ErrConflict("the email has already been invited, resend the invitation instead", nil)
+	}
+
+	tokenId, err := utils.RandomToken(16)
+	if err != nil {
+		log.Printf("Error generating invitation token id. Error: %v\n", err.Error())
+		return nil, errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+
+	invitation := dao.NewInvitation(organizationId, email, request.Role, client.Id, tokenId, is.expiresIn)
+	if err = is.invitationRepository.Create(ctx, invitation); err != nil {
+		log.Printf("Error creating invitation for email: %s. Error: %v\n", email, err.Error())
+		return nil, errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+
+	// an invitation that was never sent is revoked, so it does not stop the email from being invited again
+	if err = is.send(ctx, client, invitation); err != nil {
+		if _, revokeErr := is.invitationRepository.Transition(ctx, invitation.Id, dao.InvitationPending, dao.InvitationRevoked); revokeErr != nil {
+			log.Printf("Error revoking unsent invitation with id: %v. Error: %v\n", invitation.Id, revokeErr.Error())
+		}
+		return nil, err
+	}
+
+	is.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditInvitationSent, client).
+		WithTarget(dao.AuditTypeInvitation, invitation.Id.Hex()).
+		With("organization_id", organizationId.Hex()).
+		With("email", email).
+		With("role", string(invitation.Role)))
+
+	return invitation, nil
+}
+
+// List returns the pending invitations of the organization, newest first
+func (is *invitationService) List(ctx context.Context, clientId, organizationId primitive.ObjectID) ([]dao.Invitation, error) {
+	if _, err := is.manager(ctx, clientId, organizationId, dao.RoleMember); err != nil {
+		return nil, err
+	}
+
+	invitations, err := is.invitationRepository.FindPending(ctx, organizationId)
+	if err != nil {
+		log.Printf("Error finding invitations of organization with id: %v. Error: %v\n", organizationId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to list invitations", nil)
+	}
+	return invitations, nil
+}
+
+// pending finds a pending invitation of the organization that the client can manage
+func (is *invitationService) pending(ctx context.Context, clientId, organizationId, invitationId primitive.ObjectID) (*dao.Invitation, error) {
+	invitation, err := is.invitationRepository.FindByID(ctx, invitationId)
+	if err != nil {
+		log.Printf("Error finding invitation with id: %v. Error: %v\n", invitationId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to find invitation", nil)
+	}
+
+	// the invitations of other organizations are not found, so they cannot be probed by id
+	if invitation == nil || invitation.OrganizationId != organizationId {
+		if _, err = findMembership(ctx, is.membershipRepository, clientId, organizationId); err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrNotFound("invitation does not exist", nil)
+	}
+
+	if _, err = is.manager(ctx, clientId, organizationId, invitation.Role); err != nil {
+		return nil, err
+	}
+	if invitation.Status != dao.InvitationPending {
+		return nil, errors.ErrConflict(fmt.Sprintf("the invitation has already been %s", invitation.Status), nil)
+	}
+	return invitation, nil
+}
+
+// Resend sends a pending invitation again with a new link and expiry, the links sent for it before stop working
+func (is *invitationService) Resend(ctx context.Context, client *dao.Client, organizationId, invitationId primitive.ObjectID) (*dao.Invitation, error) {
+	invitation, err := is.pending(ctx, client.Id, organizationId, invitationId)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenId, err := utils.RandomToken(16)
+	if err != nil {
+		log.Printf("Error generating invitation token id. Error: %v\n", err.Error())
+		return nil, errors.ErrInternalServerError("failed to resend invitation", nil)
+	}
+
+	expiresAt := time.Now().Add(is.expiresIn)
+	ok, err := is.invitationRepository.Renew(ctx, invitation.Id, tokenId, expiresAt)
+	if err != nil {
+		log.Printf("Error renewing invitation with id: %v. Error: %v\n", invitation.Id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to resend invitation", nil)
+	}
+	if !ok {
+		return nil, errors.ErrConflict("the invitation is no longer pending", nil)
+	}
+	invitation.TokenId, invitation.ExpiresAt, invitation.UpdatedAt = tokenId, expiresAt, time.Now()
+
+	if err = is.send(ctx, client, invitation); err != nil {
+		return nil, err
+	}
+
+	is.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditInvitationResent, client).
+		WithTarget(dao.AuditTypeInvitation, invitation.Id.Hex()).
+		With("organization_id", organizationId.Hex()).
+		With("email", invitation.Email))
+
+	return invitation, nil
+}
+
+// Revoke withdraws a pending invitation so its link stops working
+func (is *invitationService) Revoke(ctx context.Context, client *dao.Client, organizationId, invitationId primitive.ObjectID) error {
+	invitation, err := is.pending(ctx, client.Id, organizationId, invitationId)
+	if err != nil {
+		return err
+	}
+
+	ok, err := is.invitationRepository.Transition(ctx, invitation.Id, dao.InvitationPending, dao.InvitationRevoked)
+	if err != nil {
+		log.Printf("Error revoking invitation with id: %v. Error: %v\n", invitation.Id, err.Error())
+		return errors.ErrInternalServerError("failed to revoke invitation", nil)
+	}
+	if !ok {
+		return errors.ErrConflict("the invitation is no longer pending", nil)
+	}
+
+	is.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditInvitationRevoked, client).
+		WithTarget(dao.AuditTypeInvitation, invitation.Id.Hex()).
+		With("organization_id", organizationId.Hex()).
+		With("email", invitation.Email))
+
+	return nil
+}
+
+// AcceptWithSignup signs up a new client with the email an invitation was sent to and makes them a member
+// the id of the organization they joined is returned
+func (is *invitationService) AcceptWithSignup(ctx context.Context, request *dto.AcceptInvitationRequest) (*dao.Client, primitive.ObjectID, error) {
+	invitation, err := is.verify(ctx, request.Token)
+	if err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+	if !strings.EqualFold(string(request.Email), invitation.Email) {
+		return nil, primitive.NilObjectID, errors.ErrBadRequest("the email must be the one the invitation was sent to", nil)
+	}
+
+	// a client that already has an account accepts the invitation after logging in
+	existing := &dao.Client{Email: invitation.Email}
+	exists, err := is.clientRepository.FindByEmail(ctx, existing)
+	if err != nil {
+		log.Printf("Error finding client with email: %s. Error: %v\n", invitation.Email, err.Error())
+		return nil, primitive.NilObjectID, errors.ErrInternalServerError("failed to accept invitation", nil)
+	}
+	if exists {
+		return nil, primitive.NilObjectID, errors.ErrConflict("an account with this email exists, log in to accept the invitation", nil)
+	}
+
+	organization := &dao.Organization{Id: invitation.OrganizationId}
+	ok, err := is.organizationRepository.FindByID(ctx, organization)
+	if err != nil {
+		log.Printf("Error finding organization with id: %v. Error: %v\n", invitation.OrganizationId, err.Error())
+		return nil, primitive.NilObjectID, errors.ErrInternalServerError("failed to accept invitation", nil)
+	}
+	if !ok {
+		return nil, primitive.NilObjectID, errors.ErrNotFound("organization does not exist", nil)
+	}
+
+	client := dao.NewClient(request.Name, invitation.Email, string(request.Password))
+	client.Locale = request.Locale
+
+	// the invitation is only used up once the account is created, so a password the policy rejects can be retried
+	if _, err = is.clientService.Signup(ctx, client, request.Password); err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+
+	// the account is removed again if it cannot join, so the invitation can be accepted with the email later
+	if err = is.join(ctx, client, invitation); err != nil {
+		if revertErr := is.clientService.RevertSignup(ctx, client); revertErr != nil {
+			log.Printf("Error reverting signup of client with id: %v. Error: %v\n", client.Id, revertErr.Error())
+		}
+		return nil, primitive.NilObjectID, err
+	}
+
+	return client, invitation.OrganizationId, nil
+}
+
+// Accept makes the logged-in client a member of the organization an invitation to their email was sent for
+func (is *invitationService) Accept(ctx context.Context, client *dao.Client, token string) (primitive.ObjectID, error) {
+	invitation, err := is.verify(ctx, token)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if !strings.EqualFold(client.Email, invitation.Email) {
+		return primitive.NilObjectID, errors.ErrForbidden("the invitation was sent to another email", nil)
+	}
+
+	member, err := is.membershipRepository.Find(ctx, &dao.Membership{OrganizationId: invitation.OrganizationId, ClientId: client.Id})
+	if err != nil {
+		log.Printf("Error finding membership of client with id: %v in organization: %v. Error: %v\n", client.Id, invitation.OrganizationId, err.Error())
+		return primitive.NilObjectID, errors.ErrInternalServerError("failed to accept invitation", nil)
+	}
+	if member {
+		return primitive.NilObjectID, errors.ErrConflict("you are already a member of this organization", nil)
+	}
+
+	if err = is.join(ctx, client, invitation); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return invitation.OrganizationId, nil
+}
+
+// join uses up the invitation and makes the client a member with the role it was sent with
+func (is *invitationService) join(ctx context.Context, client *dao.Client, invitation *dao.Invitation) error {
+	ok, err := is.invitationRepository.Transition(ctx, invitation.Id, dao.InvitationPending, dao.InvitationAccepted)
+	if err != nil {
+		log.Printf("Error accepting invitation with id: %v. Error: %v\n", invitation.Id, err.Error())
+		return errors.ErrInternalServerError("failed to accept invitation", nil)
+	}
+	if !ok {
+		return errors.ErrUnauthorized("invalid or expired invitation", nil)
+	}
+
+	err = is.membershipRepository.Create(ctx, dao.NewMembership(invitation.OrganizationId, client.Id, invitation.Role))
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.ErrConflict("you are already a member of this organization", nil)
+	}
+	if err != nil {
+		log.Printf("Error adding client with id: %v to organization: %v. Error: %v\n", client.Id, invitation.OrganizationId, err.Error())
+		return errors.ErrInternalServerError("failed to accept invitation", nil)
+	}
+
+	is.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditInvitationAccepted, client).
+		WithTarget(dao.AuditTypeInvitation, invitation.Id.Hex()).
+		With("organization_id", invitation.OrganizationId.Hex()).
+		With("role", string(invitation.Role)))
+
+	return nil
+}
+
+// send emails the invitation link to the invitee
+// it is written in the inviter's locale since the invitee's is not known until they sign up
+func (is *invitationService) send(ctx context.Context, inviter *dao.Client, invitation *dao.Invitation) error {
+	organization := &dao.Organization{Id: invitation.OrganizationId}
+	ok, err := is.organizationRepository.FindByID(ctx, organization)
+	if err != nil || !ok {
+		log.Printf("Error finding organization with id: %v. Error: %v\n", invitation.OrganizationId, err)
+		return errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+
+	token, err := is.sign(inviter.TenantId, invitation)
+	if err != nil {
+		log.Printf("Error signing invitation with id: %v. Error: %v\n", invitation.Id, err.Error())
+		return errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+
+	data := dto.InvitationMail{
+		InviterName:      inviter.Name,
+		OrganizationName: organization.Name,
+		Role:             string(invitation.Role),
+		Link:             tenantLink(ctx, config.InvitationURL, is.linkURL, token),
+		ExpiresIn:        is.expiresIn.String(),
+	}
+
+	if err = is.mailer.Send(ctx, invitation.Email, inviter.Locale, templates.MailInvitation, data); err != nil {
+		log.Printf("Error sending invitation to email: %s. Error: %v\n", invitation.Email, err.Error())
+		return errors.ErrInternalServerError("failed to send invitation", nil)
+	}
+	return nil
+}
+
+type invitationClaims struct {
+	Email          string `json:"email"`
+	OrganizationId string `json:"org_id"`
+	// TenantId is the tenant of the inviter, the invitation can only be accepted through it
+	TenantId string `json:"tid,omitempty"`
+	jwt.StandardClaims
+}
+
+// sign creates the signed token that is embedded in the invitation link
+func (is *invitationService) sign(tenantId string, invitation *dao.Invitation) (string, error) {
+	claims := invitationClaims{
+		Email:          invitation.Email,
+		OrganizationId: invitation.OrganizationId.Hex(),
+		TenantId:       tenantId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        invitation.TokenId,
+			Audience:  invitationAudience,
+			ExpiresAt: invitation.ExpiresAt.Unix(),
+			IssuedAt:  invitation.UpdatedAt.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(is.secret))
+}
+
+// verify checks the signature, expiry and audience of an invitation token and returns its pending invitation
+func (is *invitationService) verify(ctx context.Context, tokenString string) (*dao.Invitation, error) {
+	claims := &invitationClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(is.secret), nil
+	})
+	if err != nil || !token.Valid || !claims.VerifyAudience(invitationAudience, true) || claims.Id == "" {
+		log.Printf("Unable to validate or parse invitation token. Error: %v\n", err)
+		return nil, errors.ErrUnauthorized("invalid or expired invitation", nil)
+	}
+
+	// an invitation from one tenant cannot bring a client of another tenant into the organization
+	if !issuedByTenant(ctx, claims.TenantId) {
+		return nil, errors.ErrUnauthorized("invalid or expired invitation", nil)
+	}
+
+	invitation, err := is.invitationRepository.FindByTokenId(ctx, claims.Id)
+	if err != nil {
+		log.Printf("Error finding invitation with token id: %s. Error: %v\n", claims.Id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to accept invitation", nil)
+	}
+	if invitation == nil || invitation.Status != dao.InvitationPending || invitation.Expired() ||
+		invitation.OrganizationId.Hex() != claims.OrganizationId {
+		return nil, errors.ErrUnauthorized("invalid or expired invitation", nil)
+	}
+
+	return invitation, nil
+}
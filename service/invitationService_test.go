@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// ownerMemberships makes every client an owner of every organization
+type ownerMemberships struct {
+	interfaces.MembershipRepositoryInterface
+}
+
+func (ownerMemberships) Find(ctx context.Context, membership *dao.Membership) (bool, error) {
+	membership.Role = dao.RoleOwner
+	return true, nil
+}
+
+// noOrganizations finds no organization, so no invitation can be sent
+type noOrganizations struct {
+	interfaces.OrganizationRepositoryInterface
+}
+
+func (noOrganizations) FindByID(ctx context.Context, organization *dao.Organization) (bool, error) {
+	return false, nil
+}
+
+// createdInvitations keeps the invitations created in memory by their id
+type createdInvitations struct {
+	interfaces.InvitationRepositoryInterface
+	invitations map[primitive.ObjectID]*dao.Invitation
+}
+
+func (m *createdInvitations) Create(ctx context.Context, invitation *dao.Invitation) error {
+	invitation.Id = primitive.NewObjectID()
+	m.invitations[invitation.Id] = invitation
+	return nil
+}
+
+func (m *createdInvitations) HasPending(ctx context.Context, organizationId primitive.ObjectID, email string) (bool, error) {
+	for _, invitation := range m.invitations {
+		if invitation.OrganizationId == organizationId && invitation.Email == email && invitation.Status == dao.InvitationPending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *createdInvitations) Transition(ctx context.Context, id primitive.ObjectID, from, to string) (bool, error) {
+	invitation, ok := m.invitations[id]
+	if !ok || invitation.Status != from {
+		return false, nil
+	}
+	invitation.Status = to
+	return true, nil
+}
+
+func TestInviteRevokesInvitationThatWasNotSent(t *testing.T) {
+	invitations := &createdInvitations{invitations: make(map[primitive.ObjectID]*dao.Invitation)}
+	is := &invitationService{
+		clientRepository:       noClients{},
+		organizationRepository: noOrganizations{},
+		membershipRepository:   ownerMemberships{},
+		invitationRepository:   invitations,
+		secret:                 "invitation-secret",
+		expiresIn:              time.Hour,
+	}
+	ctx := dto.WithTenant(context.Background(), tenantA)
+	inviter := &dao.Client{Id: primitive.NewObjectID(), TenantId: tenantA.Id}
+	request := &dto.InvitationRequest{Email: "b@tenant-a.test", Role: dao.RoleMember}
+
+	organizationId := primitive.NewObjectID()
+
+	if _, err := is.Invite(ctx, inviter, organizationId, request); err == nil {
+		t.Fatalf("invitation that could not be sent was reported as sent")
+	}
+	for _, invitation := range invitations.invitations {
+		if invitation.Status != dao.InvitationRevoked {
+			t.Errorf("invitation that was not sent is %s, want %s", invitation.Status, dao.InvitationRevoked)
+		}
+	}
+	// inviting the email again must not be refused because of the invitation that was not sent
+	if _, err := is.Invite(ctx, inviter, organizationId, request); errors.Status(err) == http.StatusConflict {
+		t.Errorf("inviting the email again was refused: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// jobMetrics are the background job metrics published on the expvar endpoint
+var jobMetrics = expvar.NewMap("jobs")
+
+type jobQueue struct {
+	jobRepository interfaces.JobRepositoryInterface
+	maxAttempts   int
+}
+
+// jobWorkerPool runs queued jobs on a fixed number of workers
+type jobWorkerPool struct {
+	jobRepository interfaces.JobRepositoryInterface
+	handlers      map[string]interfaces.JobHandler
+	workers       int
+	pollInterval  time.Duration
+	lease         time.Duration
+	backoffBase   time.Duration
+	backoffMax    time.Duration
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewJobQueue returns an interface for the job queue methods
+func NewJobQueue(cfg *map[string]string, jobRepo interfaces.JobRepositoryInterface) (interfaces.JobQueueInterface, error) {
+	maxAttempts, err := strconv.Atoi((*cfg)[config.JobMaxAttempts])
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobQueue{
+		jobRepository: jobRepo,
+		maxAttempts:   maxAttempts,
+	}, nil
+}
+
+// Enqueue adds a job to be run as soon as a worker is free
+func (jq *jobQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) error {
+	return jq.Schedule(ctx, jobType, payload, time.Now())
+}
+
+// Schedule adds a job to be run once runAt has passed
+func (jq *jobQueue) Schedule(ctx context.Context, jobType string, payload interface{}, runAt time.Time) error {
+	job, err := dao.NewJob(jobType, payload, runAt, jq.maxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to encode job payload: %v", err)
+	}
+
+	if err = jq.jobRepository.Create(ctx, job); err != nil {
+		return fmt.Errorf("failed to enqueue job: %v", err)
+	}
+
+	jobMetrics.Add("enqueued", 1)
+	return nil
+}
+
+// NewJobWorkerPool returns an interface for the job worker pool methods
+func NewJobWorkerPool(cfg *map[string]string, jobRepo interfaces.JobRepositoryInterface) (interfaces.JobWorkerPoolInterface, error) {
+	workers, err := strconv.Atoi((*cfg)[config.JobWorkers])
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval, err := strconv.Atoi((*cfg)[config.JobPollInterval])
+	if err != nil {
+		return nil, err
+	}
+
+	lease, err := strconv.Atoi((*cfg)[config.JobLease])
+	if err != nil {
+		return nil, err
+	}
+
+	backoffBase, err := strconv.Atoi((*cfg)[config.JobBackoffBase])
+	if err != nil {
+		return nil, err
+	}
+
+	backoffMax, err := strconv.Atoi((*cfg)[config.JobBackoffMax])
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobWorkerPool{
+		jobRepository: jobRepo,
+		handlers:      make(map[string]interfaces.JobHandler),
+		workers:       workers,
+		pollInterval:  time.Duration(pollInterval) * time.Second,
+		lease:         time.Duration(lease) * time.Second,
+		backoffBase:   time.Duration(backoffBase) * time.Second,
+		backoffMax:    time.Duration(backoffMax) * time.Second,
+		stop:          make(chan struct{}),
+	}, nil
+}
+
+// Register sets the handler that runs jobs of the type
+// handlers have to be registered before the pool is started
+func (wp *jobWorkerPool) Register(jobType string, handler interfaces.JobHandler) {
+	wp.handlers[jobType] = handler
+}
+
+// Start starts the workers in the background
+func (wp *jobWorkerPool) Start() {
+	host, _ := os.Hostname()
+	for i := 0; i < wp.workers; i++ {
+		workerId := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i)
+		wp.wg.Add(1)
+		go wp.work(workerId)
+	}
+	log.Printf("Started %d job workers\n", wp.workers)
+}
+
+// Shutdown stops the workers from claiming new jobs and waits for the running ones to finish
+// jobs still running when the context is done are run again by another worker once their lease runs out
+func (wp *jobWorkerPool) Shutdown(ctx context.Context) error {
+	close(wp.stop)
+
+	done := make(chan struct{})
+	go func() {
+		wp.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job workers did not stop in time: %v", ctx.Err())
+	}
+}
+
+// work claims and runs jobs until the pool is stopped, waiting for the poll interval when the queue is empty
+func (wp *jobWorkerPool) work(workerId string) {
+	defer wp.wg.Done()
+
+	for {
+		select {
+		case <-wp.stop:
+			return
+		default:
+		}
+
+		job, found, err := wp.jobRepository.Claim(context.Background(), workerId, wp.lease)
+		if err != nil {
+			log.Printf("Error claiming job for worker: %s. Error: %v\n", workerId, err.Error())
+		}
+
+		if !found {
+			select {
+			case <-wp.stop:
+				return
+			case <-time.After(wp.pollInterval):
+			}
+			continue
+		}
+
+		wp.run(workerId, job)
+	}
+}
+
+// run runs a claimed job and then completes, retries or kills it
+func (wp *jobWorkerPool) run(workerId string, job *dao.Job) {
+	ctx := context.Background()
+
+	handler, ok := wp.handlers[job.Type]
+	if !ok {
+		wp.kill(ctx, workerId, job, fmt.Sprintf("no handler for job type: %s", job.Type))
+		return
+	}
+
+	// a job that used up its attempts without finishing, e.g. because its worker died, is not run again
+	if job.Attempts > job.MaxAttempts {
+		wp.kill(ctx, workerId, job, fmt.Sprintf("gave up after %d attempts. Last error: %s", job.MaxAttempts, job.LastError))
+		return
+	}
+
+	// the job has to finish before its lease runs out and another worker claims it
+	runCtx, cancel := context.WithTimeout(ctx, wp.lease)
+	err := handler(runCtx, job)
+	cancel()
+
+	if err == nil {
+		if err = wp.jobRepository.Complete(ctx, job.Id, workerId); err != nil {
+			log.Printf("Error completing job: %v. Error: %v\n", job.Id, err.Error())
+		}
+		jobMetrics.Add("completed", 1)
+		return
+	}
+
+	log.Printf("Error running job: %v of type: %s on attempt %d. Error: %v\n", job.Id, job.Type, job.Attempts, err.Error())
+
+	if job.Attempts >= job.MaxAttempts {
+		wp.kill(ctx, workerId, job, err.Error())
+		return
+	}
+
+	runAt := time.Now().Add(wp.backoff(job.Attempts))
+	if err = wp.jobRepository.Retry(ctx, job.Id, workerId, runAt, err.Error()); err != nil {
+		log.Printf("Error retrying job: %v. Error: %v\n", job.Id, err.Error())
+	}
+	jobMetrics.Add("retried", 1)
+}
+
+// kill moves a job to the dead letter state
+func (wp *jobWorkerPool) kill(ctx context.Context, workerId string, job *dao.Job, reason string) {
+	log.Printf("Job: %v of type: %s is dead. Reason: %s\n", job.Id, job.Type, reason)
+	if err := wp.jobRepository.Kill(ctx, job.Id, workerId, reason); err != nil {
+		log.Printf("Error killing job: %v. Error: %v\n", job.Id, err.Error())
+	}
+	jobMetrics.Add("dead", 1)
+}
+
+// backoff returns how long to wait before retrying a job after the given number of attempts
+func (wp *jobWorkerPool) backoff(attempts int) time.Duration {
+	delay := wp.backoffBase
+	for i := 1; i < attempts && delay < wp.backoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > wp.backoffMax {
+		return wp.backoffMax
+	}
+	return delay
+}
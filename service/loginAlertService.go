@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/templates"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// revokeSessionsAudience is the audience set on revoke links so they cannot be used as any other token
+const revokeSessionsAudience = "revoke_sessions"
+
+type loginAlertService struct {
+	tenants                interfaces.TenantRegistryInterface
+	clientRepository       interfaces.ClientRepositoryInterface
+	tokenRepository        interfaces.TokenRepositoryInterface
+	loginHistoryRepository interfaces.LoginHistoryRepositoryInterface
+	revokeLinkRepository   interfaces.RevokeLinkRepositoryInterface
+	auditService           interfaces.AuditServiceInterface
+	enabled                bool
+	secret                 string
+	revokeURL              string
+	linkExpiresIn          time.Duration
+	mailer                 interfaces.MailerInterface
+}
+
+// NewLoginAlertService returns an interface for the login alert service methods
+func NewLoginAlertService(cfg *map[string]string, tenants interfaces.TenantRegistryInterface, clientRepo interfaces.ClientRepositoryInterface, tokenRepo interfaces.TokenRepositoryInterface, loginHistoryRepo interfaces.LoginHistoryRepositoryInterface, revokeLinkRepo interfaces.RevokeLinkRepositoryInterface, auditService interfaces.AuditServiceInterface, mailer interfaces.MailerInterface) (interfaces.LoginAlertServiceInterface, error) {
+	enabled, err := strconv.ParseBool((*cfg)[config.LoginAlertsEnabled])
+	if err != nil {
+		return nil, err
+	}
+
+	linkExpiresIn, err := strconv.Atoi((*cfg)[config.LoginAlertLinkExpiresIn])
+	if err != nil {
+		return nil, err
+	}
+
+	// a revoke link cannot be signed without a secret key
+	if enabled && (*cfg)[config.LoginAlertSecretKey] == "" {
+		return nil, fmt.Errorf("login alerts are enabled but %s is not set", config.LoginAlertSecretKey)
+	}
+
+	return &loginAlertService{
+		tenants:                tenants,
+		clientRepository:       clientRepo,
+		tokenRepository:        tokenRepo,
+		loginHistoryRepository: loginHistoryRepo,
+		revokeLinkRepository:   revokeLinkRepo,
+		auditService:           auditService,
+		enabled:                enabled,
+		secret:                 (*cfg)[config.LoginAlertSecretKey],
+		revokeURL:              (*cfg)[config.LoginAlertRevokeURL],
+		linkExpiresIn:          time.Duration(linkExpiresIn) * time.Second,
+		mailer:                 mailer,
+	}, nil
+}
+
+// Enabled reports whether login alerts are turned on for this deployment
+func (las *loginAlertService) Enabled() bool {
+	return las.enabled
+}
+
+// CheckLogin emails the client if they just logged in from a device or ip they have not logged in from before
+// it has to be called before the login is added to the login history, and a client's first login is not alerted
+func (las *loginAlertService) CheckLogin(ctx context.Context, client *dao.Client) {
+	if !las.enabled {
+		return
+	}
+
+	meta := dto.RequestMetaFromContext(ctx)
+
+	previousLogins, err := las.loginHistoryRepository.CountSuccessful(ctx, client.Id, "", "")
+	if err != nil {
+		log.Printf("Error counting logins for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+	if previousLogins == 0 {
+		return
+	}
+
+	ipLogins, err := las.loginHistoryRepository.CountSuccessful(ctx, client.Id, meta.IP, "")
+	if err != nil {
+		log.Printf("Error counting logins for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+
+	deviceLogins, err := las.loginHistoryRepository.CountSuccessful(ctx, client.Id, "", meta.UserAgent)
+	if err != nil {
+		log.Printf("Error counting logins for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+
+	if ipLogins > 0 && deviceLogins > 0 {
+		return
+	}
+
+	tokenId, err := utils.RandomToken(16)
+	if err != nil {
+		log.Printf("Error generating revoke link token id. Error: %v\n", err.Error())
+		return
+	}
+
+	link := dao.NewRevokeLink(client.Id, tokenId, las.linkExpiresIn)
+	if err = las.revokeLinkRepository.Create(ctx, link); err != nil {
+		log.Printf("Error creating revoke link for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+
+	token, err := las.signRevokeLink(client.TenantId, link)
+	if err != nil {
+		log.Printf("Error signing revoke link for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return
+	}
+
+	data := dto.LoginAlertMail{
+		Name:       client.Name,
+		Device:     utils.DescribeUserAgent(meta.UserAgent),
+		IP:         meta.IP,
+		Time:       time.Now().UTC().Format(time.RFC1123),
+		RevokeLink: tenantLink(ctx, config.LoginAlertRevokeURL, las.revokeURL, token),
+	}
+
+	if err = las.mailer.Send(ctx, client.Email, client.Locale, templates.MailLoginAlert, data); err != nil {
+		log.Printf("Error sending login alert to email: %s. Error: %v\n", client.Email, err.Error())
+		return
+	}
+
+	las.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditLoginAlertSent, client).
+		With("new_ip", ipLogins == 0).
+		With("new_device", deviceLogins == 0))
+}
+
+// RevokeSessions signs the client a login alert was sent to out of every session, the access tokens already issued
+// are refused from now on and the refresh token is revoked so no session can be renewed
+// the link is consumed so it cannot be used again, and the tokens are revoked in the tenant the link names,
+// whichever tenant the request belongs to, since the link is signed and only revokes
+func (las *loginAlertService) RevokeSessions(ctx context.Context, token string) error {
+	claims, err := las.verifyRevokeLink(token)
+	if err != nil {
+		log.Printf("Unable to validate or parse revoke link token. Error: %v\n", err)
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+
+	clientId, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil || claims.Id == "" {
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+
+	// links sent before there were tenants do not name one and belong to the default tenant
+	tenantId := claims.TenantId
+	if tenantId == "" {
+		tenantId = dto.DefaultTenantId
+	}
+	tenant, ok := las.tenants.Find(tenantId)
+	if !ok {
+		log.Printf("Revoke link with token id: %s names unknown tenant %q\n", claims.Id, tenantId)
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+	ctx = dto.WithTenant(ctx, tenant)
+
+	consumed, err := las.revokeLinkRepository.Consume(ctx, &dao.RevokeLink{ClientId: clientId, TokenId: claims.Id})
+	if err != nil {
+		log.Printf("Error consuming revoke link with token id: %s. Error: %v\n", claims.Id, err.Error())
+		return errors.ErrInternalServerError("failed to sign out of all sessions", nil)
+	}
+	if !consumed {
+		return errors.ErrUnauthorized("invalid or expired link", nil)
+	}
+
+	// the access tokens are refused from now on, and the refresh token cannot be exchanged for new ones
+	// a client with no refresh token is signed out already, which is what the link asks for
+	if _, err = las.clientRepository.RevokeSessions(ctx, clientId, time.Now()); err != nil {
+		log.Printf("Error revoking sessions for client with id: %v. Error: %v\n", clientId, err.Error())
+		return errors.ErrInternalServerError("failed to sign out of all sessions", nil)
+	}
+	if _, err = las.tokenRepository.Delete(ctx, clientId); err != nil {
+		log.Printf("Error revoking tokens for client with id: %v. Error: %v\n", clientId, err.Error())
+		return errors.ErrInternalServerError("failed to sign out of all sessions", nil)
+	}
+
+	las.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditTokenRevoked, dao.AuditTypeClient, clientId.Hex(), "").
+		WithTarget(dao.AuditTypeClient, clientId.Hex()).
+		With("reason", "login_alert"))
+
+	return nil
+}
+
+type revokeLinkClaims struct {
+	// TenantId is the tenant of the client the link was sent to, whose sessions the link revokes
+	TenantId string `json:"tid,omitempty"`
+	jwt.StandardClaims
+}
+
+// signRevokeLink creates the signed token that is embedded in the revoke link
+func (las *loginAlertService) signRevokeLink(tenantId string, link *dao.RevokeLink) (string, error) {
+	claims := revokeLinkClaims{
+		TenantId: tenantId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        link.TokenId,
+			Subject:   link.ClientId.Hex(),
+			Audience:  revokeSessionsAudience,
+			ExpiresAt: link.ExpiresAt.Unix(),
+			IssuedAt:  link.CreatedAt.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(las.secret))
+}
+
+// verifyRevokeLink verifies the signature, expiry and audience of a revoke link token
+func (las *loginAlertService) verifyRevokeLink(tokenString string) (*revokeLinkClaims, error) {
+	claims := &revokeLinkClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(las.secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || !claims.VerifyAudience(revokeSessionsAudience, true) {
+		return nil, fmt.Errorf("token is invalid")
+	}
+
+	return claims, nil
+}
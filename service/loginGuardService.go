@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/templates"
+)
+
+type loginGuardService struct {
+	clientRepository       interfaces.ClientRepositoryInterface
+	loginAttemptRepository interfaces.LoginAttemptRepositoryInterface
+	auditService           interfaces.AuditServiceInterface
+	maxAttempts            int
+	ipMaxAttempts          int
+	backoffBase            time.Duration
+	backoffMax             time.Duration
+	lockoutDuration        time.Duration
+	mailer                 interfaces.MailerInterface
+}
+
+// NewLoginGuardService returns an interface for the login guard service methods
+func NewLoginGuardService(cfg *map[string]string, clientRepo interfaces.ClientRepositoryInterface, loginAttemptRepo interfaces.LoginAttemptRepositoryInterface, auditService interfaces.AuditServiceInterface, mailer interfaces.MailerInterface) (interfaces.LoginGuardServiceInterface, error) {
+	maxAttempts, err := strconv.Atoi((*cfg)[config.LoginMaxAttempts])
+	if err != nil {
+		return nil, err
+	}
+
+	ipMaxAttempts, err := strconv.Atoi((*cfg)[config.LoginIPMaxAttempts])
+	if err != nil {
+		return nil, err
+	}
+
+	backoffBase, err := strconv.Atoi((*cfg)[config.LoginBackoffBase])
+	if err != nil {
+		return nil, err
+	}
+
+	backoffMax, err := strconv.Atoi((*cfg)[config.LoginBackoffMax])
+	if err != nil {
+		return nil, err
+	}
+
+	lockoutDuration, err := strconv.Atoi((*cfg)[config.LoginLockoutDuration])
+	if err != nil {
+		return nil, err
+	}
+
+	return &loginGuardService{
+		clientRepository:       clientRepo,
+		loginAttemptRepository: loginAttemptRepo,
+		auditService:           auditService,
+		maxAttempts:            maxAttempts,
+		ipMaxAttempts:          ipMaxAttempts,
+		backoffBase:            time.Duration(backoffBase) * time.Second,
+		backoffMax:             time.Duration(backoffMax) * time.Second,
+		lockoutDuration:        time.Duration(lockoutDuration) * time.Second,
+		mailer:                 mailer,
+	}, nil
+}
+
+// Check returns an error if a login for the email from the ip should not be attempted yet
+// a locked account gets a 423 and an account or ip that is backing off gets a 429
+func (lg *loginGuardService) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	emailKey, err := emailAttemptKey(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	emailAttempt := &dao.LoginAttempt{Key: emailKey}
+	exists, err := lg.loginAttemptRepository.FindByKey(ctx, emailAttempt)
+	if err != nil {
+		log.Printf("Error finding login attempts for email: %s. Error: %v\n", email, err.Error())
+		return errors.ErrInternalServerError("failed to fetch client details", nil)
+	}
+
+	if exists {
+		// the account is locked after too many failures
+		if now.Before(emailAttempt.LockedUntil) {
+			return errors.ErrLocked("account is temporarily locked after too many failed logins", emailAttempt.LockedUntil.Sub(now), nil)
+		}
+
+		// each failure doubles the time the account has to wait before the next attempt
+		if nextAttempt := emailAttempt.LastFailureAt.Add(lg.backoff(emailAttempt.Failures)); now.Before(nextAttempt) {
+			return errors.ErrTooManyRequests("too many failed logins, please try again later", nextAttempt.Sub(now), nil)
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	ipAttempt := &dao.LoginAttempt{Key: dao.IPAttemptKey(ip)}
+	exists, err = lg.loginAttemptRepository.FindByKey(ctx, ipAttempt)
+	if err != nil {
+		log.Printf("Error finding login attempts for ip: %s. Error: %v\n", ip, err.Error())
+		return errors.ErrInternalServerError("failed to fetch client details", nil)
+	}
+
+	// the ip is blocked after too many failures across any accounts
+	if exists && now.Before(ipAttempt.LockedUntil) {
+		return errors.ErrTooManyRequests("too many failed logins, please try again later", ipAttempt.LockedUntil.Sub(now), nil)
+	}
+
+	return nil
+}
+
+// RecordFailure records a failed login for the email and the ip
+// it locks the account or blocks the ip once they reach their thresholds
+func (lg *loginGuardService) RecordFailure(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	resetBefore := now.Add(-lg.lockoutDuration)
+
+	emailKey, err := emailAttemptKey(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	emailAttempt, err := lg.loginAttemptRepository.RecordFailure(ctx, emailKey, resetBefore)
+	if err != nil {
+		log.Printf("Error recording login failure for email: %s. Error: %v\n", email, err.Error())
+		return err
+	}
+
+	if emailAttempt.Failures >= lg.maxAttempts && !now.Before(emailAttempt.LockedUntil) {
+		lockedUntil := now.Add(lg.lockoutDuration)
+		if err = lg.loginAttemptRepository.Lock(ctx, emailAttempt.Key, lockedUntil); err != nil {
+			log.Printf("Error locking login for email: %s. Error: %v\n", email, err.Error())
+			return err
+		}
+		lg.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditLoginLocked, dao.AuditTypeAnonymous, "", email).
+			With("failures", emailAttempt.Failures).
+			With("locked_until", lockedUntil.Format(time.RFC3339)))
+		lg.notifyLockout(ctx, email, lockedUntil)
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	ipAttempt, err := lg.loginAttemptRepository.RecordFailure(ctx, dao.IPAttemptKey(ip), resetBefore)
+	if err != nil {
+		log.Printf("Error recording login failure for ip: %s. Error: %v\n", ip, err.Error())
+		return err
+	}
+
+	if ipAttempt.Failures >= lg.ipMaxAttempts && !now.Before(ipAttempt.LockedUntil) {
+		lockedUntil := now.Add(lg.lockoutDuration)
+		if err = lg.loginAttemptRepository.Lock(ctx, ipAttempt.Key, lockedUntil); err != nil {
+			log.Printf("Error locking login for ip: %s. Error: %v\n", ip, err.Error())
+			return err
+		}
+		lg.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditLoginLocked, dao.AuditTypeAnonymous, "", "").
+			With("failures", ipAttempt.Failures).
+			With("locked_until", lockedUntil.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the failed logins recorded for the email
+func (lg *loginGuardService) RecordSuccess(ctx context.Context, email string) error {
+	emailKey, err := emailAttemptKey(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if err = lg.loginAttemptRepository.Delete(ctx, emailKey); err != nil {
+		log.Printf("Error clearing login failures for email: %s. Error: %v\n", email, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Unlock lifts the lockout and clears the failed logins for the email and/or the ip
+// the email is unlocked in the tenant the request belongs to
+func (lg *loginGuardService) Unlock(ctx context.Context, email, ip string) error {
+	if email != "" {
+		emailKey, err := emailAttemptKey(ctx, email)
+		if err != nil {
+			return err
+		}
+		if err = lg.loginAttemptRepository.Delete(ctx, emailKey); err != nil {
+			log.Printf("Error unlocking login for email: %s. Error: %v\n", email, err.Error())
+			return errors.ErrInternalServerError("failed to unlock login", nil)
+		}
+	}
+
+	if ip != "" {
+		if err := lg.loginAttemptRepository.Delete(ctx, dao.IPAttemptKey(ip)); err != nil {
+			log.Printf("Error unlocking login for ip: %s. Error: %v\n", ip, err.Error())
+			return errors.ErrInternalServerError("failed to unlock login", nil)
+		}
+	}
+
+	return nil
+}
+
+// emailAttemptKey returns the login attempt key for the email in the tenant in the context
+func emailAttemptKey(ctx context.Context, email string) (string, error) {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		log.Printf("Error finding tenant of login attempts for email: %s. Error: %v\n", email, err.Error())
+		return "", errors.ErrInternalServerError("failed to fetch client details", nil)
+	}
+	return dao.EmailAttemptKey(tenantId, email), nil
+}
+
+// backoff returns how long to wait after the given number of consecutive failures
+func (lg *loginGuardService) backoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	delay := lg.backoffBase
+	for i := 1; i < failures && delay < lg.backoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > lg.backoffMax {
+		return lg.backoffMax
+	}
+	return delay
+}
+
+// notifyLockout emails the client whose account was just locked
+func (lg *loginGuardService) notifyLockout(ctx context.Context, email string, lockedUntil time.Time) {
+	client := &dao.Client{Email: email}
+	clientExists, err := lg.clientRepository.FindByEmail(ctx, client)
+	if err != nil {
+		log.Printf("Error finding client with email: %s. Error: %v\n", email, err.Error())
+		return
+	}
+
+	// nobody to notify if the email is not registered
+	if !clientExists {
+		return
+	}
+
+	data := dto.AccountLockedMail{
+		Name:        client.Name,
+		MaxAttempts: lg.maxAttempts,
+		LockedUntil: lockedUntil.UTC().Format(time.RFC1123),
+	}
+
+	if err = lg.mailer.Send(ctx, email, client.Locale, templates.MailAccountLocked, data); err != nil {
+		log.Printf("Error sending lockout notification to email: %s. Error: %v\n", email, err.Error())
+	}
+}
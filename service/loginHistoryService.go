@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type loginHistoryService struct {
+	loginHistoryRepository interfaces.LoginHistoryRepositoryInterface
+}
+
+// NewLoginHistoryService returns an interface for the login history service methods
+func NewLoginHistoryService(loginHistoryRepo interfaces.LoginHistoryRepositoryInterface) interfaces.LoginHistoryServiceInterface {
+	return &loginHistoryService{
+		loginHistoryRepository: loginHistoryRepo,
+	}
+}
+
+// Record stores a login history entry with the details of the request it happened in
+// a failure to store the entry is logged rather than failing the sign-in
+func (lhs *loginHistoryService) Record(ctx context.Context, entry *dao.LoginHistoryEntry) {
+	meta := dto.RequestMetaFromContext(ctx)
+	entry.IP = meta.IP
+	entry.UserAgent = meta.UserAgent
+	entry.CreatedAt = time.Now()
+
+	if err := lhs.loginHistoryRepository.Create(ctx, entry); err != nil {
+		log.Printf("Error recording login history for client with id: %v. Error: %v\n", entry.ClientId, err.Error())
+	}
+}
+
+// ListForClient returns a page of the client's login history, newest first
+func (lhs *loginHistoryService) ListForClient(ctx context.Context, clientId primitive.ObjectID, pagination dto.Pagination) (*dto.PaginatedResponse, error) {
+	entries, total, err := lhs.loginHistoryRepository.FindByClientId(ctx, clientId, pagination)
+	if err != nil {
+		log.Printf("Error finding login history for client with id: %v. Error: %v\n", clientId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to fetch login history", nil)
+	}
+
+	return dto.NewPaginatedResponse(entries, pagination, total), nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/templates"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// magicLinkAudience is the audience set on magic link tokens so they cannot be used as any other token
+const magicLinkAudience = "magic_link"
+
+type magicLinkService struct {
+	clientRepository    interfaces.ClientRepositoryInterface
+	magicLinkRepository interfaces.MagicLinkRepositoryInterface
+	auditService        interfaces.AuditServiceInterface
+	enabled             bool
+	secret              string
+	expiresIn           time.Duration
+	linkURL             string
+	rateLimit           int64
+	rateWindow          time.Duration
+	mailer              interfaces.MailerInterface
+}
+
+// NewMagicLinkService returns an interface for the magic link service methods
+func NewMagicLinkService(cfg *map[string]string, clientRepo interfaces.ClientRepositoryInterface, magicLinkRepo interfaces.MagicLinkRepositoryInterface, auditService interfaces.AuditServiceInterface, mailer interfaces.MailerInterface) (interfaces.MagicLinkServiceInterface, error) {
+	enabled, err := strconv.ParseBool((*cfg)[config.MagicLinkEnabled])
+	if err != nil {
+		return nil, err
+	}
+
+	expiresIn, err := strconv.Atoi((*cfg)[config.MagicLinkExpiresIn])
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := strconv.Atoi((*cfg)[config.MagicLinkRateLimit])
+	if err != nil {
+		return nil, err
+	}
+
+	rateWindow, err := strconv.Atoi((*cfg)[config.MagicLinkRateWindow])
+	if err != nil {
+		return nil, err
+	}
+
+	// a magic link cannot be signed without a secret key
+	if enabled && (*cfg)[config.MagicLinkSecretKey] == "" {
+		return nil, fmt.Errorf("magic links are enabled but %s is not set", config.MagicLinkSecretKey)
+	}
+
+	return &magicLinkService{
+		clientRepository:    clientRepo,
+		magicLinkRepository: magicLinkRepo,
+		auditService:        auditService,
+		enabled:             enabled,
+		secret:              (*cfg)[config.MagicLinkSecretKey],
+		expiresIn:           time.Duration(expiresIn) * time.Second,
+		linkURL:             (*cfg)[config.MagicLinkURL],
+		rateLimit:           int64(rateLimit),
+		rateWindow:          time.Duration(rateWindow) * time.Second,
+		mailer:              mailer,
+	}, nil
+}
+
+// Enabled reports whether passwordless login is turned on for this deployment
+func (ms *magicLinkService) Enabled() bool {
+	return ms.enabled
+}
+
+// SendLink emails a single-use login link to the client with the given email
+// it does not report whether the email belongs to a client to avoid leaking registered emails
+func (ms *magicLinkService) SendLink(ctx context.Context, email string) error {
+	// check that the email has not requested too many links within the window
+	count, err := ms.magicLinkRepository.CountByEmailSince(ctx, email, time.Now().Add(-ms.rateWindow))
+	if err != nil {
+		log.Printf("Error counting magic links for email: %s. Error: %v\n", email, err.Error())
+		return errors.ErrInternalServerError("failed to send magic link", nil)
+	}
+	if count >= ms.rateLimit {
+		return errors.ErrTooManyRequests("too many magic link requests, please try again later", ms.rateWindow, nil)
+	}
+
+	tokenId, err := utils.RandomToken(16)
+	if err != nil {
+		log.Printf("Error generating magic link token id. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to send magic link", nil)
+	}
+
+	// the link is recorded before the email is looked up, so requests for emails without a client count towards
+	// the limit as well and the limit does not tell them apart, their links are never sent and expire unused
+	link := dao.NewMagicLink(email, tokenId, ms.expiresIn)
+	if err = ms.magicLinkRepository.Create(ctx, link); err != nil {
+		log.Printf("Error creating magic link for email: %s. Error: %v\n", email, err.Error())
+		return errors.ErrInternalServerError("failed to send magic link", nil)
+	}
+
+	client := &dao.Client{Email: email}
+	clientExists, err := ms.clientRepository.FindByEmail(ctx, client)
+	if err != nil {
+		log.Printf("Error finding client with email: %s. Error: %v\n", email, err.Error())
+		return errors.ErrInternalServerError("failed to send magic link", nil)
+	}
+
+	// pretend the link was sent if there is no client with the email
+	if !clientExists {
+		return nil
+	}
+
+	token, err := ms.signLink(client.TenantId, link)
+	if err != nil {
+		log.Printf("Error signing magic link for email: %s. Error: %v\n", email, err.Error())
+		return errors.ErrInternalServerError("failed to send magic link", nil)
+	}
+
+	data := dto.MagicLinkMail{
+		Name:      client.Name,
+		Link:      tenantLink(ctx, config.MagicLinkURL, ms.linkURL, token),
+		ExpiresIn: ms.expiresIn.String(),
+	}
+
+	if err = ms.mailer.Send(ctx, email, client.Locale, templates.MailMagicLink, data); err != nil {
+		log.Printf("Error sending magic link to email: %s. Error: %v\n", email, err.Error())
+		return errors.ErrInternalServerError("failed to send magic link", nil)
+	}
+
+	ms.auditService.Record(ctx, dao.NewAuditEvent(dao.AuditMagicLinkSent, dao.AuditTypeAnonymous, "", email).
+		WithTarget(dao.AuditTypeClient, client.Id.Hex()))
+
+	return nil
+}
+
+// VerifyLink exchanges a magic link token for the client it was issued to
+// the link is consumed so it cannot be used again
+func (ms *magicLinkService) VerifyLink(ctx context.Context, token string) (*dao.Client, error) {
+	claims, err := ms.verifyLink(token)
+	if err == nil && !issuedByTenant(ctx, claims.TenantId) {
+		err = fmt.Errorf("link was issued for another tenant")
+	}
+	if err != nil {
+		log.Printf("Unable to validate or parse magic link token. Error: %v\n", err)
+		return nil, errors.ErrUnauthorized("invalid or expired login link", nil)
+	}
+
+	consumed, err := ms.magicLinkRepository.Consume(ctx, claims.Id)
+	if err != nil {
+		log.Printf("Error consuming magic link with token id: %s. Error: %v\n", claims.Id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to verify login link", nil)
+	}
+	if !consumed {
+		return nil, errors.ErrUnauthorized("invalid or expired login link", nil)
+	}
+
+	client := &dao.Client{Email: claims.Email}
+	clientExists, err := ms.clientRepository.FindByEmail(ctx, client)
+	if err != nil {
+		log.Printf("Error finding client with email: %s. Error: %v\n", claims.Email, err.Error())
+		return nil, errors.ErrInternalServerError("failed to fetch client details", nil)
+	}
+	if !clientExists {
+		return nil, errors.ErrUnauthorized("invalid or expired login link", nil)
+	}
+
+	return client, nil
+}
+
+type magicLinkClaims struct {
+	Email string `json:"email"`
+	// TenantId is the tenant of the client the link was sent to, the link only logs in through it
+	TenantId string `json:"tid,omitempty"`
+	jwt.StandardClaims
+}
+
+// signLink creates the signed token that is embedded in the magic link
+func (ms *magicLinkService) signLink(tenantId string, link *dao.MagicLink) (string, error) {
+	claims := magicLinkClaims{
+		Email:    link.Email,
+		TenantId: tenantId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        link.TokenId,
+			Audience:  magicLinkAudience,
+			ExpiresAt: link.ExpiresAt.Unix(),
+			IssuedAt:  link.CreatedAt.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(ms.secret))
+}
+
+// verifyLink verifies the signature, expiry and audience of a magic link token
+func (ms *magicLinkService) verifyLink(tokenString string) (*magicLinkClaims, error) {
+	claims := &magicLinkClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(ms.secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || !claims.VerifyAudience(magicLinkAudience, true) || claims.Id == "" {
+		return nil, fmt.Errorf("token is invalid")
+	}
+
+	return claims, nil
+}
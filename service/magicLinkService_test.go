@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// noClients finds no client by email
+type noClients struct {
+	interfaces.ClientRepositoryInterface
+}
+
+func (noClients) FindByEmail(ctx context.Context, client *dao.Client) (bool, error) {
+	return false, nil
+}
+
+// requestedMagicLinks keeps the magic links created in memory
+type requestedMagicLinks struct {
+	interfaces.MagicLinkRepositoryInterface
+	links []*dao.MagicLink
+}
+
+func (m *requestedMagicLinks) Create(ctx context.Context, link *dao.MagicLink) error {
+	m.links = append(m.links, link)
+	return nil
+}
+
+func (m *requestedMagicLinks) CountByEmailSince(ctx context.Context, email string, since time.Time) (int64, error) {
+	var count int64
+	for _, link := range m.links {
+		if link.Email == email && !link.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestSendLinkLimitsEmailsWithoutClient(t *testing.T) {
+	ms := &magicLinkService{
+		clientRepository:    noClients{},
+		magicLinkRepository: &requestedMagicLinks{},
+		secret:              "magic-link-secret",
+		expiresIn:           time.Minute,
+		rateLimit:           2,
+		rateWindow:          time.Hour,
+	}
+	ctx := dto.WithTenant(context.Background(), tenantA)
+
+	for i := 0; i < 2; i++ {
+		if err := ms.SendLink(ctx, "unknown@tenant-a.test"); err != nil {
+			t.Fatalf("request %d for an email without a client failed: %v", i+1, err)
+		}
+	}
+
+	// an email without a client is limited like any other, so the limit does not reveal which emails are registered
+	if err := ms.SendLink(ctx, "unknown@tenant-a.test"); errors.Status(err) != http.StatusTooManyRequests {
+		t.Errorf("request over the limit for an email without a client got %v, want too many requests", err)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/templates"
+)
+
+// JobTypeDeliverMail is the job type that delivers a rendered mail through the mail transport
+const JobTypeDeliverMail = "mail.deliver"
+
+type mailer struct {
+	jobQueue  interfaces.JobQueueInterface
+	templates *templates.MailTemplates
+	from      string
+	// tenantTemplates and tenantFrom hold the templates and sender of the tenants that have their own
+	tenantTemplates map[string]*templates.MailTemplates
+	tenantFrom      map[string]string
+}
+
+// NewMailer returns an interface for the mailer methods
+// mail is rendered when it is sent and delivered in the background by the job queue
+func NewMailer(cfg *map[string]string, tenants interfaces.TenantRegistryInterface, jobQueue interfaces.JobQueueInterface) (interfaces.MailerInterface, error) {
+	defaultLocale := (*cfg)[config.MailDefaultLocale]
+	mailTemplates, err := templates.LoadMailTemplates(defaultLocale, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mailer{
+		jobQueue:        jobQueue,
+		templates:       mailTemplates,
+		from:            (*cfg)[config.MailFrom],
+		tenantTemplates: make(map[string]*templates.MailTemplates),
+		tenantFrom:      make(map[string]string),
+	}
+
+	for _, tenant := range tenants.All() {
+		if tenant.MailTemplatesDir != "" {
+			if m.tenantTemplates[tenant.Id], err = templates.LoadMailTemplates(defaultLocale, os.DirFS(tenant.MailTemplatesDir)); err != nil {
+				return nil, fmt.Errorf("failed to load mail templates for tenant %q: %v", tenant.Id, err)
+			}
+		}
+		if tenant.MailFrom != "" {
+			m.tenantFrom[tenant.Id] = tenant.MailFrom
+		}
+	}
+
+	return m, nil
+}
+
+// Send renders the mail template in the recipient's locale and queues it for delivery
+// the mail uses the templates and sender of the tenant in the context, if it has its own
+func (m *mailer) Send(ctx context.Context, to, locale, template string, data interface{}) error {
+	mailTemplates, from := m.templates, m.from
+	if tenant := dto.TenantFromContext(ctx); tenant != nil {
+		if t, ok := m.tenantTemplates[tenant.Id]; ok {
+			mailTemplates = t
+		}
+		if f, ok := m.tenantFrom[tenant.Id]; ok {
+			from = f
+		}
+	}
+
+	subject, text, html, err := mailTemplates.Render(template, locale, data)
+	if err != nil {
+		return fmt.Errorf("failed to render mail: %v", err)
+	}
+
+	return m.jobQueue.Enqueue(ctx, JobTypeDeliverMail, &dto.Mail{
+		From:    from,
+		To:      to,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+// DeliverMailJob returns the job handler that delivers queued mail through the transport
+func DeliverMailJob(transport interfaces.MailTransportInterface) interfaces.JobHandler {
+	return func(ctx context.Context, job *dao.Job) error {
+		var mail dto.Mail
+		if err := job.DecodePayload(&mail); err != nil {
+			return fmt.Errorf("failed to decode mail: %v", err)
+		}
+		return transport.Deliver(ctx, &mail)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// maintenanceMetrics are the maintenance sweep metrics published on the expvar endpoint
+var maintenanceMetrics = expvar.NewMap("maintenance")
+
+// maintenanceSweepTimeout is how long a single sweep may run for
+const maintenanceSweepTimeout = 5 * time.Minute
+
+// maintenanceSweep removes one kind of data that has expired and returns how many documents it removed
+type maintenanceSweep struct {
+	name string
+	run  func(ctx context.Context, now time.Time) (int64, error)
+}
+
+// maintenanceScheduler runs the maintenance sweeps in the background
+// data with a fixed expiry is removed by the TTL indexes the migrations create instead,
+// the sweeps cover what a TTL index cannot, such as data kept for a configurable time
+// or only removed once it is in a given state
+// every replica runs the sweeps, which is safe because each one only deletes what has already expired
+type maintenanceScheduler struct {
+	sweeps   []maintenanceSweep
+	interval time.Duration
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewMaintenanceScheduler returns an interface for the maintenance scheduler methods
+func NewMaintenanceScheduler(
+	cfg *map[string]string,
+	magicLinkRepo interfaces.MagicLinkRepositoryInterface,
+	loginAttemptRepo interfaces.LoginAttemptRepositoryInterface,
+	loginHistoryRepo interfaces.LoginHistoryRepositoryInterface,
+	jobRepo interfaces.JobRepositoryInterface,
+	auditService interfaces.AuditServiceInterface,
+) (interfaces.MaintenanceSchedulerInterface, error) {
+	seconds := make(map[string]time.Duration)
+	for _, c := range []string{
+		config.MaintenanceInterval, config.MagicLinkExpiresIn, config.MagicLinkRateWindow,
+		config.LoginLockoutDuration, config.LoginHistoryRetention, config.JobRetention, config.AuditRetention,
+	} {
+		v, err := strconv.Atoi((*cfg)[c])
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%s must be a number of seconds", c)
+		}
+		seconds[c] = time.Duration(v) * time.Second
+	}
+
+	var sweeps []maintenanceSweep
+
+	// magic links are counted for rate limiting, so they are kept for the whole rate window even after they expire
+	magicLinkRetention := seconds[config.MagicLinkExpiresIn]
+	if seconds[config.MagicLinkRateWindow] > magicLinkRetention {
+		magicLinkRetention = seconds[config.MagicLinkRateWindow]
+	}
+	sweeps = append(sweeps, maintenanceSweep{name: "magic_links", run: func(ctx context.Context, now time.Time) (int64, error) {
+		return magicLinkRepo.DeleteBefore(ctx, now.Add(-magicLinkRetention))
+	}})
+
+	// failures older than the lockout duration no longer count towards a lockout
+	sweeps = append(sweeps, maintenanceSweep{name: "login_attempts", run: func(ctx context.Context, now time.Time) (int64, error) {
+		return loginAttemptRepo.DeleteStale(ctx, now.Add(-seconds[config.LoginLockoutDuration]))
+	}})
+
+	if retention := seconds[config.LoginHistoryRetention]; retention > 0 {
+		sweeps = append(sweeps, maintenanceSweep{name: "login_history", run: func(ctx context.Context, now time.Time) (int64, error) {
+			return loginHistoryRepo.DeleteBefore(ctx, now.Add(-retention))
+		}})
+	}
+
+	if retention := seconds[config.JobRetention]; retention > 0 {
+		sweeps = append(sweeps, maintenanceSweep{name: "jobs", run: func(ctx context.Context, now time.Time) (int64, error) {
+			return jobRepo.DeleteFinishedBefore(ctx, now.Add(-retention))
+		}})
+	}
+
+	if retention := seconds[config.AuditRetention]; retention > 0 {
+		sweeps = append(sweeps, maintenanceSweep{name: "audit_events", run: func(ctx context.Context, now time.Time) (int64, error) {
+			return auditService.Prune(ctx, now.Add(-retention))
+		}})
+	}
+
+	return &maintenanceScheduler{
+		sweeps:   sweeps,
+		interval: seconds[config.MaintenanceInterval],
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Start runs the sweeps in the background straight away and then once every interval
+func (ms *maintenanceScheduler) Start() {
+	if ms.interval == 0 {
+		log.Println("Maintenance sweeps are turned off")
+		return
+	}
+
+	ms.wg.Add(1)
+	go func() {
+		defer ms.wg.Done()
+
+		ticker := time.NewTicker(ms.interval)
+		defer ticker.Stop()
+
+		for {
+			ms.RunOnce(context.Background())
+
+			select {
+			case <-ms.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Started %d maintenance sweeps every %v\n", len(ms.sweeps), ms.interval)
+}
+
+// RunOnce runs every sweep one after the other, a sweep that fails does not stop the ones after it
+func (ms *maintenanceScheduler) RunOnce(ctx context.Context) {
+	for _, sweep := range ms.sweeps {
+		select {
+		case <-ms.stop:
+			return
+		default:
+		}
+
+		ms.runSweep(ctx, sweep)
+	}
+}
+
+// Shutdown stops the sweeps and waits for the one that is running to finish
+func (ms *maintenanceScheduler) Shutdown(ctx context.Context) error {
+	close(ms.stop)
+
+	done := make(chan struct{})
+	go func() {
+		ms.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("maintenance sweeps did not stop in time: %v", ctx.Err())
+	}
+}
+
+// runSweep runs a sweep, then logs and records how it went
+func (ms *maintenanceScheduler) runSweep(ctx context.Context, sweep maintenanceSweep) {
+	ctx, cancel := context.WithTimeout(ctx, maintenanceSweepTimeout)
+	defer cancel()
+
+	start := time.Now()
+	count, err := sweep.run(ctx, start)
+	duration := time.Since(start)
+
+	maintenanceMetrics.Add(sweep.name+"_runs", 1)
+	maintenanceMetrics.AddFloat(sweep.name+"_seconds_total", duration.Seconds())
+
+	if err != nil {
+		log.Printf("Error running maintenance sweep: %s. Error: %v\n", sweep.name, err.Error())
+		maintenanceMetrics.Add(sweep.name+"_errors", 1)
+		return
+	}
+
+	log.Printf("Maintenance sweep: %s removed %d documents in %v\n", sweep.name, count, duration)
+	maintenanceMetrics.Add(sweep.name+"_removed", count)
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type organizationService struct {
+	organizationRepository interfaces.OrganizationRepositoryInterface
+	membershipRepository   interfaces.MembershipRepositoryInterface
+	clientRepository       interfaces.ClientRepositoryInterface
+	auditService           interfaces.AuditServiceInterface
+	businessTypeService    interfaces.BusinessTypeServiceInterface
+}
+
+// NewOrganizationService returns an interface for the organization service methods
+func NewOrganizationService(organizationRepo interfaces.OrganizationRepositoryInterface, membershipRepo interfaces.MembershipRepositoryInterface, clientRepo interfaces.ClientRepositoryInterface, auditService interfaces.AuditServiceInterface, businessTypeService interfaces.BusinessTypeServiceInterface) interfaces.OrganizationServiceInterface {
+	return &organizationService{
+		organizationRepository: organizationRepo,
+		membershipRepository:   membershipRepo,
+		clientRepository:       clientRepo,
+		auditService:           auditService,
+		businessTypeService:    businessTypeService,
+	}
+}
+
+// Create creates an organization with the client as its owner
+func (ors *organizationService) Create(ctx context.Context, owner *dao.Client, organization *dao.Organization) error {
+	if err := ors.businessTypeService.Validate(ctx, organization.BusinessType); err != nil {
+		return err
+	}
+
+	if err := ors.organizationRepository.Create(ctx, organization); err != nil {
+		log.Printf("Error creating organization for client with id: %v. Error: %v\n", owner.Id, err.Error())
+		return errors.ErrInternalServerError("failed to create organization", nil)
+	}
+
+	// an organization without an owner cannot be reached, so it is removed again if the owner cannot be added
+	if err := ors.membershipRepository.Create(ctx, dao.NewMembership(organization.Id, owner.Id, dao.RoleOwner)); err != nil {
+		log.Printf("Error adding owner with id: %v to organization: %v. Error: %v\n", owner.Id, organization.Id, err.Error())
+		if _, err = ors.organizationRepository.Delete(ctx, organization.Id); err != nil {
+			log.Printf("Error removing organization: %v without an owner. Error: %v\n", organization.Id, err.Error())
+		}
+		return errors.ErrInternalServerError("failed to create organization", nil)
+	}
+
+	ors.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditOrganizationCreated, owner).
+		WithTarget(dao.AuditTypeOrganization, organization.Id.Hex()).
+		With("name", organization.Name))
+
+	return nil
+}
+
+// List returns the organizations the client is a member of, with their role in each
+func (ors *organizationService) List(ctx context.Context, clientId primitive.ObjectID) ([]dto.OrganizationProfile, error) {
+	memberships, err := ors.membershipRepository.FindByClient(ctx, clientId)
+	if err != nil {
+		log.Printf("Error finding memberships of client with id: %v. Error: %v\n", clientId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to list organizations", nil)
+	}
+
+	roles := make(map[primitive.ObjectID]dao.Role, len(memberships))
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.OrganizationId] = membership.Role
+		ids = append(ids, membership.OrganizationId)
+	}
+
+	profiles := make([]dto.OrganizationProfile, 0, len(ids))
+	if len(ids) == 0 {
+		return profiles, nil
+	}
+
+	organizations, err := ors.organizationRepository.FindByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Error finding organizations of client with id: %v. Error: %v\n", clientId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to list organizations", nil)
+	}
+	for _, organization := range organizations {
+		profiles = append(profiles, dto.NewOrganizationProfile(organization, roles[organization.Id]))
+	}
+
+	return profiles, nil
+}
+
+// findMembership finds the membership of the client in the organization
+// it returns a forbidden error if the client is not a member, so organizations cannot be probed by id
+func findMembership(ctx context.Context, membershipRepo interfaces.MembershipRepositoryInterface, clientId, organizationId primitive.ObjectID) (*dao.Membership, error) {
+	membership := &dao.Membership{OrganizationId: organizationId, ClientId: clientId}
+	ok, err := membershipRepo.Find(ctx, membership)
+	if err != nil {
+		log.Printf("Error finding membership of client with id: %v in organization: %v. Error: %v\n", clientId, organizationId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to find organization membership", nil)
+	}
+	if !ok {
+		return nil, errors.ErrForbidden("you are not a member of this organization", nil)
+	}
+	return membership, nil
+}
+
+// organization finds an organization by its id
+func (ors *organizationService) organization(ctx context.Context, organizationId primitive.ObjectID) (*dao.Organization, error) {
+	organization := &dao.Organization{Id: organizationId}
+	ok, err := ors.organizationRepository.FindByID(ctx, organization)
+	if err != nil {
+		log.Printf("Error finding organization with id: %v. Error: %v\n", organizationId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to find organization", nil)
+	}
+	if !ok {
+		return nil, errors.ErrNotFound("organization does not exist", nil)
+	}
+	return organization, nil
+}
+
+// Get returns an organization the client is a member of
+func (ors *organizationService) Get(ctx context.Context, clientId, organizationId primitive.ObjectID) (*dto.OrganizationProfile, error) {
+	membership, err := findMembership(ctx, ors.membershipRepository, clientId, organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	organization, err := ors.organization(ctx, organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := dto.NewOrganizationProfile(*organization, membership.Role)
+	return &profile, nil
+}
+
+// Update changes the business profile of an organization, which only its owners and admins can do
+func (ors *organizationService) Update(ctx context.Context, client *dao.Client, organizationId primitive.ObjectID, update *dto.OrganizationUpdate) (*dto.OrganizationProfile, error) {
+	membership, err := findMembership(ctx, ors.membershipRepository, client.Id, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	if !membership.Role.AtLeast(dao.RoleAdmin) {
+		return nil, errors.ErrForbidden("only owners and admins can edit the organization", nil)
+	}
+
+	organization, err := ors.organization(ctx, organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, errs := update.Apply(organization)
+	if len(errs) > 0 {
+		return nil, errors.ErrBadRequest("invalid organization update", errors.ErrorToStringSlice(errs))
+	}
+	for _, field := range changed {
+		if field != "business_type" {
+			continue
+		}
+		if err = ors.businessTypeService.Validate(ctx, organization.BusinessType); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changed) > 0 {
+		organization.UpdatedAt = time.Now()
+		ok, err := ors.organizationRepository.Update(ctx, organization)
+		if err != nil {
+			log.Printf("Error updating organization with id: %v. Error: %v\n", organizationId, err.Error())
+			return nil, errors.ErrInternalServerError("failed to update organization", nil)
+		}
+		if !ok {
+			return nil, errors.ErrNotFound("organization does not exist", nil)
+		}
+
+		// the fields are recorded without their values since the api key is among them
+		ors.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditOrganizationUpdated, client).
+			WithTarget(dao.AuditTypeOrganization, organizationId.Hex()).
+			With("fields", strings.Join(changed, ",")))
+	}
+
+	profile := dto.NewOrganizationProfile(*organization, membership.Role)
+	return &profile, nil
+}
+
+// Members returns the members of an organization the client is a member of, oldest first
+func (ors *organizationService) Members(ctx context.Context, clientId, organizationId primitive.ObjectID) ([]dto.MemberProfile, error) {
+	if _, err := findMembership(ctx, ors.membershipRepository, clientId, organizationId); err != nil {
+		return nil, err
+	}
+
+	memberships, err := ors.membershipRepository.FindByOrganization(ctx, organizationId)
+	if err != nil {
+		log.Printf("Error finding members of organization with id: %v. Error: %v\n", organizationId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to list members", nil)
+	}
+
+	members := make([]dto.MemberProfile, 0, len(memberships))
+	for _, membership := range memberships {
+		member := &dao.Client{Id: membership.ClientId}
+		ok, err := ors.clientRepository.FindByID(ctx, member)
+		if err != nil {
+			log.Printf("Error finding member with id: %v. Error: %v\n", membership.ClientId, err.Error())
+			return nil, errors.ErrInternalServerError("failed to list members", nil)
+		}
+		if !ok {
+			continue
+		}
+		members = append(members, dto.NewMemberProfile(*member, membership))
+	}
+
+	return members, nil
+}
+
+// manage checks that the client can change the membership of another member of the organization
+// owners and admins manage the members, but only owners can manage owners or make a member an owner,
+// and an organization always keeps at least one owner
+func (ors *organizationService) manage(ctx context.Context, client *dao.Client, organizationId, memberId primitive.ObjectID, role *dao.Role) (*dao.Membership, error) {
+	actor, err := findMembership(ctx, ors.membershipRepository, client.Id, organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &dao.Membership{OrganizationId: organizationId, ClientId: memberId}
+	if memberId == client.Id {
+		target = actor
+	} else {
+		ok, err := ors.membershipRepository.Find(ctx, target)
+		if err != nil {
+			log.Printf("Error finding membership of client with id: %v in organization: %v. Error: %v\n", memberId, organizationId, err.Error())
+			return nil, errors.ErrInternalServerError("failed to find organization membership", nil)
+		}
+		if !ok {
+			return nil, errors.ErrNotFound("member does not exist", nil)
+		}
+
+		if !actor.Role.AtLeast(dao.RoleAdmin) {
+			return nil, errors.ErrForbidden("only owners and admins can manage members", nil)
+		}
+	}
+
+	// members can step down or leave on their own, which the role check below allows
+	if (target.Role == dao.RoleOwner || (role != nil && *role == dao.RoleOwner)) && actor.Role != dao.RoleOwner {
+		return nil, errors.ErrForbidden("only owners can manage owners", nil)
+	}
+	if role != nil && memberId == client.Id && !actor.Role.AtLeast(*role) {
+		return nil, errors.ErrForbidden("you cannot give yourself a higher role", nil)
+	}
+
+	if target.Role == dao.RoleOwner && (role == nil || *role != dao.RoleOwner) {
+		owners, err := ors.membershipRepository.CountRole(ctx, organizationId, dao.RoleOwner)
+		if err != nil {
+			log.Printf("Error counting owners of organization with id: %v. Error: %v\n", organizationId, err.Error())
+			return nil, errors.ErrInternalServerError("failed to count organization owners", nil)
+		}
+		if owners <= 1 {
+			return nil, errors.ErrConflict("an organization needs at least one owner, make another member an owner first", nil)
+		}
+	}
+
+	return target, nil
+}
+
+// ChangeRole gives a member of the organization a new role
+func (ors *organizationService) ChangeRole(ctx context.Context, client *dao.Client, organizationId, memberId primitive.ObjectID, role dao.Role) error {
+	target, err := ors.manage(ctx, client, organizationId, memberId, &role)
+	if err != nil {
+		return err
+	}
+	if target.Role == role {
+		return nil
+	}
+
+	ok, err := ors.membershipRepository.SetRole(ctx, organizationId, memberId, role)
+	if err != nil {
+		log.Printf("Error changing role of client with id: %v in organization: %v. Error: %v\n", memberId, organizationId, err.Error())
+		return errors.ErrInternalServerError("failed to change role", nil)
+	}
+	if !ok {
+		return errors.ErrNotFound("member does not exist", nil)
+	}
+
+	ors.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditMemberRoleChanged, client).
+		WithTarget(dao.AuditTypeClient, memberId.Hex()).
+		With("organization_id", organizationId.Hex()).
+		With("old_role", string(target.Role)).
+		With("role", string(role)))
+
+	return nil
+}
+
+// RemoveMember removes a member from the organization, or lets the client leave it when the member is them
+func (ors *organizationService) RemoveMember(ctx context.Context, client *dao.Client, organizationId, memberId primitive.ObjectID) error {
+	target, err := ors.manage(ctx, client, organizationId, memberId, nil)
+	if err != nil {
+		return err
+	}
+
+	ok, err := ors.membershipRepository.Delete(ctx, organizationId, memberId)
+	if err != nil {
+		log.Printf("Error removing client with id: %v from organization: %v. Error: %v\n", memberId, organizationId, err.Error())
+		return errors.ErrInternalServerError("failed to remove member", nil)
+	}
+	if !ok {
+		return errors.ErrNotFound("member does not exist", nil)
+	}
+
+	ors.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditMemberRemoved, client).
+		WithTarget(dao.AuditTypeClient, memberId.Hex()).
+		With("organization_id", organizationId.Hex()).
+		With("role", string(target.Role)))
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+const (
+	// HashAlgorithmBcrypt is the name of the bcrypt hashing algorithm
+	HashAlgorithmBcrypt = "bcrypt"
+	// HashAlgorithmArgon2id is the name of the argon2id hashing algorithm
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+// bcryptMaxPasswordLength is the longest password in bytes that bcrypt hashes
+const bcryptMaxPasswordLength = 72
+
+// bcryptHasher hashes passwords with bcrypt
+// bcrypt only uses the first 72 bytes of a password and refuses to hash longer ones
+type bcryptHasher struct {
+	cost int
+}
+
+// argon2idHasher hashes passwords with argon2id and encodes them as PHC strings
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type argon2idHasher struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// passwordHasher hashes new passwords with the preferred algorithm and verifies
+// hashes made with any supported algorithm, so stored hashes can be upgraded over time
+type passwordHasher struct {
+	preferred string
+	bcrypt    *bcryptHasher
+	argon2id  *argon2idHasher
+}
+
+// NewPasswordHasher returns an interface for the password hasher methods
+func NewPasswordHasher(cfg *map[string]string) (interfaces.PasswordHasherInterface, error) {
+	preferred := (*cfg)[config.PasswordHashAlgorithm]
+	if preferred != HashAlgorithmBcrypt && preferred != HashAlgorithmArgon2id {
+		return nil, fmt.Errorf("invalid password hash algorithm: %s", preferred)
+	}
+
+	bcryptCost, err := strconv.Atoi((*cfg)[config.PasswordBcryptCost])
+	if err != nil {
+		return nil, err
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid bcrypt cost: %d", bcryptCost)
+	}
+
+	memory, err := strconv.ParseUint((*cfg)[config.PasswordArgon2Memory], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	time, err := strconv.ParseUint((*cfg)[config.PasswordArgon2Time], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	threads, err := strconv.ParseUint((*cfg)[config.PasswordArgon2Threads], 10, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &passwordHasher{
+		preferred: preferred,
+		bcrypt:    &bcryptHasher{cost: bcryptCost},
+		argon2id:  &argon2idHasher{memory: uint32(memory), time: uint32(time), threads: uint8(threads)},
+	}, nil
+}
+
+// Hash hashes a password with the preferred algorithm
+func (ph *passwordHasher) Hash(ctx context.Context, password string) (string, error) {
+	if ph.preferred == HashAlgorithmBcrypt {
+		return ph.bcrypt.hash(password)
+	}
+	return ph.argon2id.hash(password)
+}
+
+// Verify checks a password against a hash made with any supported algorithm
+func (ph *passwordHasher) Verify(ctx context.Context, password, hash string) (bool, error) {
+	switch hashAlgorithm(hash) {
+	case HashAlgorithmBcrypt:
+		return ph.bcrypt.verify(password, hash)
+	case HashAlgorithmArgon2id:
+		return ph.argon2id.verify(password, hash)
+	default:
+		return false, fmt.Errorf("unsupported password hash format")
+	}
+}
+
+// NeedsRehash checks if a hash was made with an algorithm or parameters other than the preferred ones
+func (ph *passwordHasher) NeedsRehash(hash string) bool {
+	algorithm := hashAlgorithm(hash)
+	if algorithm != ph.preferred {
+		return true
+	}
+
+	if algorithm == HashAlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != ph.bcrypt.cost
+	}
+
+	params, _, _, err := decodeArgon2id(hash)
+	return err != nil || *params != *ph.argon2id
+}
+
+// hashAlgorithm returns the algorithm a hash was made with from its prefix
+func hashAlgorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return HashAlgorithmBcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return HashAlgorithmArgon2id
+	default:
+		return ""
+	}
+}
+
+// hash hashes a password with bcrypt
+// a password that is too long is refused as a bad request, the password policy normally refuses it first
+func (bh *bcryptHasher) hash(password string) (string, error) {
+	if len(password) > bcryptMaxPasswordLength {
+		return "", errors.ErrBadRequest(fmt.Sprintf("password must be at most %d bytes long", bcryptMaxPasswordLength), nil)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bh.cost)
+	return string(bytes), err
+}
+
+// verify checks a password against a bcrypt hash
+func (bh *bcryptHasher) verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// hash hashes a password with argon2id and a random salt
+func (ah *argon2idHasher) hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, ah.time, ah.memory, ah.threads, argon2idKeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, ah.memory, ah.time, ah.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verify checks a password against an argon2id hash using the parameters stored in the hash
+func (ah *argon2idHasher) verify(password, hash string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// decodeArgon2id parses the parameters, salt and key from an argon2id PHC string
+func decodeArgon2id(hash string) (*argon2idHasher, []byte, []byte, error) {
+	// the hash starts with a $ so the first part is empty
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashAlgorithmArgon2id {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2id version")
+	}
+
+	params := &argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id parameters: %v", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id salt: %v", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id key: %v", err)
+	}
+
+	return params, salt, key, nil
+}
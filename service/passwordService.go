@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type passwordService struct {
+	policy *dto.PasswordPolicy
+	// tenantPolicies are the policies of the tenants that override the global password policy
+	tenantPolicies    map[string]*dto.PasswordPolicy
+	hasher            interfaces.PasswordHasherInterface
+	breachedPasswords interfaces.BreachedPasswordCheckerInterface
+}
+
+// NewPasswordService returns an interface for the password service methods
+// breachedPasswords may be nil if breached password screening is turned off
+func NewPasswordService(cfg *map[string]string, tenants interfaces.TenantRegistryInterface, hasher interfaces.PasswordHasherInterface, breachedPasswords interfaces.BreachedPasswordCheckerInterface) (interfaces.PasswordServiceInterface, error) {
+	policy, err := passwordPolicyFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantPolicies := make(map[string]*dto.PasswordPolicy)
+	for _, tenant := range tenants.All() {
+		if len(tenant.PasswordPolicy) == 0 {
+			continue
+		}
+
+		// the tenant's overrides are laid over the global config
+		tenantCfg := make(map[string]string, len(*cfg))
+		for k, v := range *cfg {
+			tenantCfg[k] = v
+		}
+		for k, v := range tenant.PasswordPolicy {
+			tenantCfg[k] = v
+		}
+
+		if tenantPolicies[tenant.Id], err = passwordPolicyFromConfig(&tenantCfg); err != nil {
+			return nil, fmt.Errorf("invalid password policy for tenant %q: %v", tenant.Id, err)
+		}
+	}
+
+	return &passwordService{
+		policy:            policy,
+		tenantPolicies:    tenantPolicies,
+		hasher:            hasher,
+		breachedPasswords: breachedPasswords,
+	}, nil
+}
+
+// Validate checks a new password for the client against the password policy
+// it returns a failure for every rule the password does not meet, and an error
+// if the password could not be compared with the client's previous passwords
+func (ps *passwordService) Validate(ctx context.Context, password dto.Password, client *dao.Client) ([]dto.PasswordRuleFailure, error) {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy := ps.policyFor(tenantId)
+
+	failures := password.Validate(policy, client.Name, client.Email)
+
+	if ps.isBreached(password) {
+		failures = append(failures, dto.PasswordRuleFailure{
+			Rule:    dto.RuleBreached,
+			Message: "password has appeared in a data breach, please choose a different password",
+		})
+	}
+
+	// only an existing client has passwords that cannot be reused
+	if !client.Id.IsZero() {
+		reused, err := ps.isReused(ctx, policy, password, client)
+		if err != nil {
+			return nil, err
+		}
+		if reused {
+			failures = append(failures, dto.PasswordRuleFailure{
+				Rule:    dto.RuleHistory,
+				Message: "password must not be the same as a recently used password",
+			})
+		}
+	}
+
+	return failures, nil
+}
+
+// AddToHistory records a password hash the client has used so it cannot be reused
+// only as many hashes as the policy of the client's tenant checks are kept
+func (ps *passwordService) AddToHistory(client *dao.Client, hash string) {
+	policy := ps.policyFor(client.TenantId)
+	if policy.HistorySize <= 0 || hash == "" {
+		return
+	}
+
+	client.PasswordHistory = append([]string{hash}, client.PasswordHistory...)
+	if len(client.PasswordHistory) > policy.HistorySize {
+		client.PasswordHistory = client.PasswordHistory[:policy.HistorySize]
+	}
+}
+
+// policyFor returns the password policy of a tenant, which is the global one unless the tenant overrides it
+func (ps *passwordService) policyFor(tenantId string) *dto.PasswordPolicy {
+	if policy, ok := ps.tenantPolicies[tenantId]; ok {
+		return policy
+	}
+	return ps.policy
+}
+
+// isBreached checks if the password appears in the breached password list
+// the password is let through if the list cannot be read
+func (ps *passwordService) isBreached(password dto.Password) bool {
+	if ps.breachedPasswords == nil {
+		return false
+	}
+
+	breached, err := ps.breachedPasswords.IsBreached(string(password))
+	if err != nil {
+		log.Printf("Error checking password against breached passwords. Error: %v\n", err)
+		return false
+	}
+	return breached
+}
+
+// isReused checks if the password matches the client's current password or one in their history
+func (ps *passwordService) isReused(ctx context.Context, policy *dto.PasswordPolicy, password dto.Password, client *dao.Client) (bool, error) {
+	if policy.HistorySize <= 0 {
+		return false, nil
+	}
+
+	hashes := append([]string{client.Password}, client.PasswordHistory...)
+	for _, hash := range hashes {
+		ok, err := ps.hasher.Verify(ctx, string(password), hash)
+		if re, isRestErr := errors.AsRestError(err); isRestErr {
+			// the hasher is too busy, so the caller should retry
+			return false, re
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// passwordPolicyFromConfig builds the password policy from the config
+func passwordPolicyFromConfig(cfg *map[string]string) (*dto.PasswordPolicy, error) {
+	ints := map[string]*int{}
+	bools := map[string]*bool{}
+	policy := &dto.PasswordPolicy{}
+
+	ints[config.PasswordMinLength] = &policy.MinLength
+	ints[config.PasswordMaxLength] = &policy.MaxLength
+	ints[config.PasswordMinStrength] = &policy.MinStrength
+	ints[config.PasswordHistorySize] = &policy.HistorySize
+	bools[config.PasswordRequireUpperCase] = &policy.RequireUpperCase
+	bools[config.PasswordRequireLowerCase] = &policy.RequireLowerCase
+	bools[config.PasswordRequireDigit] = &policy.RequireDigit
+	bools[config.PasswordRequireSpecialChar] = &policy.RequireSpecialChar
+	bools[config.PasswordForbidPersonalInfo] = &policy.ForbidPersonalInfo
+
+	for key, v := range ints {
+		n, err := strconv.Atoi((*cfg)[key])
+		if err != nil {
+			return nil, err
+		}
+		*v = n
+	}
+
+	for key, v := range bools {
+		b, err := strconv.ParseBool((*cfg)[key])
+		if err != nil {
+			return nil, err
+		}
+		*v = b
+	}
+
+	// a password bcrypt cannot hash fails the policy instead of failing to be hashed
+	if (*cfg)[config.PasswordHashAlgorithm] == HashAlgorithmBcrypt {
+		policy.MaxBytes = bcryptMaxPasswordLength
+	}
+
+	return policy, nil
+}
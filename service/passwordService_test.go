@@ -0,0 +1,41 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+func TestBcryptPolicyRefusesPasswordsItCannotHash(t *testing.T) {
+	cfg := map[string]string{
+		config.PasswordHashAlgorithm:      HashAlgorithmBcrypt,
+		config.PasswordMinLength:          "8",
+		config.PasswordMaxLength:          "128",
+		config.PasswordMinStrength:        "0",
+		config.PasswordHistorySize:        "0",
+		config.PasswordRequireUpperCase:   "false",
+		config.PasswordRequireLowerCase:   "false",
+		config.PasswordRequireDigit:       "false",
+		config.PasswordRequireSpecialChar: "false",
+		config.PasswordForbidPersonalInfo: "false",
+	}
+	policy, err := passwordPolicyFromConfig(&cfg)
+	if err != nil {
+		t.Fatalf("failed to read password policy: %v", err)
+	}
+
+	// within the 128 characters of the policy, but more bytes than bcrypt hashes
+	password := strings.Repeat("a", bcryptMaxPasswordLength+1)
+	failures := dto.Password(password).Validate(policy)
+	if len(failures) != 1 || failures[0].Rule != dto.RuleMaxLength {
+		t.Errorf("password of %d bytes failed %v, want the %s rule", len(password), failures, dto.RuleMaxLength)
+	}
+
+	if _, err = (&bcryptHasher{cost: 4}).hash(password); errors.Status(err) != http.StatusBadRequest {
+		t.Errorf("hashing a password of %d bytes with bcrypt got %v, want a bad request", len(password), err)
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+	"github.com/leonardchinonso/auth_service_cmp7174/utils"
+)
+
+// phoneCodeLength is the number of digits in a code sent to a phone
+const phoneCodeLength = 6
+
+type phoneVerificationService struct {
+	clientRepository    interfaces.ClientRepositoryInterface
+	phoneCodeRepository interfaces.PhoneCodeRepositoryInterface
+	auditService        interfaces.AuditServiceInterface
+	smsSender           interfaces.SMSSenderInterface
+	expiresIn           time.Duration
+	maxAttempts         int
+	resendInterval      time.Duration
+	sendLimit           int
+	sendWindow          time.Duration
+}
+
+// NewPhoneVerificationService returns an interface for the phone verification service methods
+func NewPhoneVerificationService(cfg *map[string]string, clientRepo interfaces.ClientRepositoryInterface, phoneCodeRepo interfaces.PhoneCodeRepositoryInterface, auditService interfaces.AuditServiceInterface, smsSender interfaces.SMSSenderInterface) (interfaces.PhoneVerificationServiceInterface, error) {
+	values := make(map[string]int)
+	for _, c := range []string{config.PhoneCodeExpiresIn, config.PhoneCodeMaxAttempts, config.PhoneCodeResendInterval, config.PhoneCodeSendLimit, config.PhoneCodeSendWindow} {
+		v, err := strconv.Atoi((*cfg)[c])
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%s must be a positive number", c)
+		}
+		values[c] = v
+	}
+
+	for _, c := range []string{config.PhoneCodeExpiresIn, config.PhoneCodeMaxAttempts, config.PhoneCodeSendLimit, config.PhoneCodeSendWindow} {
+		if values[c] == 0 {
+			return nil, fmt.Errorf("%s must be more than zero", c)
+		}
+	}
+
+	return &phoneVerificationService{
+		clientRepository:    clientRepo,
+		phoneCodeRepository: phoneCodeRepo,
+		auditService:        auditService,
+		smsSender:           smsSender,
+		expiresIn:           time.Duration(values[config.PhoneCodeExpiresIn]) * time.Second,
+		maxAttempts:         values[config.PhoneCodeMaxAttempts],
+		resendInterval:      time.Duration(values[config.PhoneCodeResendInterval]) * time.Second,
+		sendLimit:           values[config.PhoneCodeSendLimit],
+		sendWindow:          time.Duration(values[config.PhoneCodeSendWindow]) * time.Second,
+	}, nil
+}
+
+// SendCode texts a one-time code to verify the client's phone number, replacing the code sent before it
+// a new code can be guessed at again, so only a limited number of codes are sent in a send window
+func (ps *phoneVerificationService) SendCode(ctx context.Context, client *dao.Client) error {
+	if client.PhoneNumber == "" {
+		return errors.ErrBadRequest("add a phone number to your profile first", nil)
+	}
+	if client.PhoneVerified {
+		return errors.ErrBadRequest("phone number is already verified", nil)
+	}
+
+	previous, err := ps.phoneCodeRepository.Find(ctx, client.Id, dao.PhoneCodeVerify)
+	if err != nil {
+		log.Printf("Error finding phone code for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to send code", nil)
+	}
+
+	code, err := utils.RandomDigits(phoneCodeLength)
+	if err != nil {
+		log.Printf("Error generating phone code. Error: %v\n", err.Error())
+		return errors.ErrInternalServerError("failed to send code", nil)
+	}
+	phoneCode := dao.NewPhoneCode(client, dao.PhoneCodeVerify, code, ps.expiresIn, ps.sendWindow)
+
+	if previous != nil {
+		// check that a code was not just sent to the same phone number
+		if previous.PhoneNumber == client.PhoneNumber {
+			if wait := time.Until(previous.CreatedAt.Add(ps.resendInterval)); wait > 0 {
+				return errors.ErrTooManyRequests("a code was just sent, please wait before requesting another", wait, nil)
+			}
+		}
+
+		// the codes sent to every phone number count towards the limit, so switching numbers does not reset it
+		phoneCode.ContinueWindow(previous, ps.sendWindow)
+		if phoneCode.SentCount > ps.sendLimit {
+			wait := time.Until(previous.WindowStartedAt.Add(ps.sendWindow))
+			return errors.ErrTooManyRequests("too many codes were sent, please wait before requesting another", wait, nil)
+		}
+	}
+
+	if err = ps.phoneCodeRepository.Replace(ctx, phoneCode); err != nil {
+		log.Printf("Error storing phone code for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to send code", nil)
+	}
+
+	message := fmt.Sprintf("Your code is %s. It expires in %v. Do not share it with anyone.", code, ps.expiresIn)
+	if err = ps.smsSender.Send(ctx, client.PhoneNumber, message); err != nil {
+		log.Printf("Error sending phone code to client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to send code", nil)
+	}
+
+	ps.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditPhoneCodeSent, client).With("purpose", dao.PhoneCodeVerify))
+
+	return nil
+}
+
+// checkCode checks a code the client entered against the last code sent to their phone number for the purpose
+// every guess is counted, and a code stops working once it is used or guessed wrong too many times
+func (ps *phoneVerificationService) checkCode(ctx context.Context, client *dao.Client, purpose, code string) error {
+	stored, err := ps.phoneCodeRepository.Find(ctx, client.Id, purpose)
+	if err != nil {
+		log.Printf("Error finding phone code for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to check code", nil)
+	}
+
+	// a code sent to a phone number the client no longer has does not count
+	if stored == nil || stored.Expired() || stored.PhoneNumber != client.PhoneNumber {
+		return errors.ErrBadRequest("invalid or expired code", nil)
+	}
+
+	attempted, err := ps.phoneCodeRepository.Attempt(ctx, stored, ps.maxAttempts)
+	if err != nil {
+		log.Printf("Error counting phone code attempt for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to check code", nil)
+	}
+	if !attempted {
+		return errors.ErrBadRequest("too many wrong codes, please request a new one", nil)
+	}
+
+	hash := dao.HashPhoneCode(client.Id, purpose, code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(stored.CodeHash)) != 1 {
+		return errors.ErrBadRequest("invalid or expired code", nil)
+	}
+
+	if err = ps.phoneCodeRepository.Delete(ctx, stored.Id); err != nil {
+		log.Printf("Error deleting phone code for client with id: %v. Error: %v\n", client.Id, err.Error())
+		return errors.ErrInternalServerError("failed to check code", nil)
+	}
+
+	return nil
+}
+
+// VerifyPhone marks the client's phone number as verified once they enter the code sent to it
+func (ps *phoneVerificationService) VerifyPhone(ctx context.Context, clientId primitive.ObjectID, code string) (*dao.Client, error) {
+	client := &dao.Client{Id: clientId}
+	clientExists, err := ps.clientRepository.FindByID(ctx, client)
+	if err != nil {
+		log.Printf("Error finding client with id: %v. Error: %v\n", clientId, err.Error())
+		return nil, errors.ErrInternalServerError("failed to retrieve client", nil)
+	}
+	if !clientExists {
+		return nil, errors.ErrBadRequest("client not found", nil)
+	}
+
+	if client.PhoneVerified {
+		return client, nil
+	}
+
+	if err = ps.checkCode(ctx, client, dao.PhoneCodeVerify, code); err != nil {
+		return nil, err
+	}
+
+	verified, err := ps.clientRepository.SetPhoneVerified(ctx, client.Id, client.PhoneNumber)
+	if err != nil {
+		log.Printf("Error verifying phone number of client with id: %v. Error: %v\n", client.Id, err.Error())
+		return nil, errors.ErrInternalServerError("failed to verify phone number", nil)
+	}
+	if !verified {
+		return nil, errors.ErrConflict("the phone number was changed, please request a new code", nil)
+	}
+
+	client.PhoneVerified = true
+	client.Version++
+	ps.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditPhoneVerified, client))
+
+	return client, nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+// the tenants share their keys, so a token of one tenant is only refused by the other because of the tenant it names
+var (
+	tenantA = &dto.Tenant{Id: "tenant-a", ATSecretKey: "at-secret", RTSecretKey: "rt-secret"}
+	tenantB = &dto.Tenant{Id: "tenant-b", ATSecretKey: "at-secret", RTSecretKey: "rt-secret"}
+)
+
+// memoryClients finds every client by id and email, the methods the tests do not expect to be called are left nil
+// a client is given the time their sessions were revoked at if it is set
+type memoryClients struct {
+	interfaces.ClientRepositoryInterface
+	sessionsRevokedAt *time.Time
+}
+
+func (m *memoryClients) FindByID(ctx context.Context, client *dao.Client) (bool, error) {
+	client.SessionsRevokedAt = m.sessionsRevokedAt
+	return true, nil
+}
+
+func (m *memoryClients) FindByEmail(ctx context.Context, client *dao.Client) (bool, error) {
+	client.Id = primitive.NewObjectID()
+	return true, nil
+}
+
+// memoryMagicLinks consumes every magic link once
+type memoryMagicLinks struct {
+	interfaces.MagicLinkRepositoryInterface
+	consumed map[string]bool
+}
+
+func (m *memoryMagicLinks) Consume(ctx context.Context, tokenId string) (bool, error) {
+	if m.consumed[tokenId] {
+		return false, nil
+	}
+	m.consumed[tokenId] = true
+	return true, nil
+}
+
+// memoryInvitations keeps invitations in memory by their token id
+type memoryInvitations struct {
+	interfaces.InvitationRepositoryInterface
+	invitations map[string]*dao.Invitation
+}
+
+func (m *memoryInvitations) FindByTokenId(ctx context.Context, tokenId string) (*dao.Invitation, error) {
+	return m.invitations[tokenId], nil
+}
+
+func TestAccessTokenIsRefusedByAnotherTenant(t *testing.T) {
+	ts := &tokenService{clientRepository: &memoryClients{}}
+	client := &dao.Client{Id: primitive.NewObjectID(), Email: "a@tenant-a.test"}
+
+	token, err := generateToken(client, nil, tenantA.Id, tenantA.ATSecretKey, 60)
+	if err != nil {
+		t.Fatalf("failed to generate access token: %v", err)
+	}
+
+	if _, _, err = ts.ClientFromAccessToken(dto.WithTenant(context.Background(), tenantA), token); err != nil {
+		t.Fatalf("access token was refused by its own tenant: %v", err)
+	}
+	if _, _, err = ts.ClientFromAccessToken(dto.WithTenant(context.Background(), tenantB), token); err == nil {
+		t.Errorf("access token of tenant A was accepted by tenant B")
+	}
+}
+
+func TestRefreshTokenIsRefusedByAnotherTenant(t *testing.T) {
+	// the token repository is left nil, a refused token must not get as far as the stored pair
+	ts := &tokenService{}
+	client := &dao.Client{Id: primitive.NewObjectID(), Email: "a@tenant-a.test"}
+
+	token, err := generateToken(client, nil, tenantA.Id, tenantA.RTSecretKey, 60)
+	if err != nil {
+		t.Fatalf("failed to generate refresh token: %v", err)
+	}
+
+	refreshed, _, _, err := ts.RefreshTokenPair(dto.WithTenant(context.Background(), tenantB), token)
+	if err == nil {
+		t.Fatalf("refresh token of tenant A was accepted by tenant B")
+	}
+	if refreshed != nil {
+		t.Errorf("refusing a refresh token of another tenant returned its client")
+	}
+}
+
+func TestMagicLinkIsRefusedByAnotherTenant(t *testing.T) {
+	ms := &magicLinkService{
+		clientRepository:    &memoryClients{},
+		magicLinkRepository: &memoryMagicLinks{consumed: make(map[string]bool)},
+		secret:              "magic-link-secret",
+	}
+
+	link := dao.NewMagicLink("a@tenant-a.test", "token-id", time.Minute)
+	token, err := ms.signLink(tenantA.Id, link)
+	if err != nil {
+		t.Fatalf("failed to sign magic link: %v", err)
+	}
+
+	if _, err = ms.VerifyLink(dto.WithTenant(context.Background(), tenantB), token); err == nil {
+		t.Fatalf("magic link of tenant A was accepted by tenant B")
+	}
+	// refusing the link must not use it up
+	if _, err = ms.VerifyLink(dto.WithTenant(context.Background(), tenantA), token); err != nil {
+		t.Errorf("magic link was refused by its own tenant after another tenant refused it: %v", err)
+	}
+}
+
+func TestInvitationIsRefusedByAnotherTenant(t *testing.T) {
+	invitation := dao.NewInvitation(primitive.NewObjectID(), "b@tenant-a.test", dao.RoleMember, primitive.NewObjectID(), "token-id", time.Hour)
+	is := &invitationService{
+		invitationRepository: &memoryInvitations{invitations: map[string]*dao.Invitation{invitation.TokenId: invitation}},
+		secret:               "invitation-secret",
+	}
+
+	token, err := is.sign(tenantA.Id, invitation)
+	if err != nil {
+		t.Fatalf("failed to sign invitation: %v", err)
+	}
+
+	if _, err = is.verify(dto.WithTenant(context.Background(), tenantB), token); err == nil {
+		t.Errorf("invitation of tenant A was accepted by tenant B")
+	}
+	if _, err = is.verify(dto.WithTenant(context.Background(), tenantA), token); err != nil {
+		t.Errorf("invitation was refused by its own tenant: %v", err)
+	}
+}
+
+func TestTenantLink(t *testing.T) {
+	defaultTenant := &dto.Tenant{Id: dto.DefaultTenantId}
+	overridden := &dto.Tenant{Id: "tenant-c", LinkURLs: map[string]string{config.MagicLinkURL: "https://c.test/login"}}
+
+	tests := []struct {
+		name   string
+		tenant *dto.Tenant
+		want   string
+	}{
+		{"default tenant", defaultTenant, "https://app.test/login?token=t%2B1"},
+		{"other tenant", tenantA, "https://app.test/login?token=t%2B1&tenant=tenant-a"},
+		{"overridden url", overridden, "https://c.test/login?token=t%2B1&tenant=tenant-c"},
+	}
+
+	for _, tt := range tests {
+		link := tenantLink(dto.WithTenant(context.Background(), tt.tenant), config.MagicLinkURL, "https://app.test/login", "t+1")
+		if link != tt.want {
+			t.Errorf("%s: link is %s, want %s", tt.name, link, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
+)
+
+type tenantRegistry struct {
+	tenants []*dto.Tenant
+	byId    map[string]*dto.Tenant
+	byHost  map[string]*dto.Tenant
+}
+
+// NewTenantRegistry returns an interface for the tenant registry methods
+// the tenants are read from the tenants file, and the default tenant uses the global config
+// unless the file holds a tenant with the default id
+func NewTenantRegistry(cfg *map[string]string) (interfaces.TenantRegistryInterface, error) {
+	var tenants []*dto.Tenant
+	if path := (*cfg)[config.TenantsFile]; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tenants file: %v", err)
+		}
+		if err = json.Unmarshal(data, &tenants); err != nil {
+			return nil, fmt.Errorf("failed to parse tenants file: %v", err)
+		}
+	}
+
+	tr := &tenantRegistry{
+		byId:   make(map[string]*dto.Tenant),
+		byHost: make(map[string]*dto.Tenant),
+	}
+
+	for _, tenant := range tenants {
+		if errs := tenant.Validate(); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid tenant: %v", errs)
+		}
+		if err := tr.add(tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := tr.byId[dto.DefaultTenantId]; !ok {
+		if err := tr.add(&dto.Tenant{
+			Id:          dto.DefaultTenantId,
+			ATSecretKey: (*cfg)[config.ATSecretKey],
+			RTSecretKey: (*cfg)[config.RTSecretKey],
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return tr, nil
+}
+
+// add registers a tenant under its id and hosts, which no other tenant can have
+func (tr *tenantRegistry) add(tenant *dto.Tenant) error {
+	if _, ok := tr.byId[tenant.Id]; ok {
+		return fmt.Errorf("more than one tenant has the id %q", tenant.Id)
+	}
+	for _, host := range tenant.Hosts {
+		host = strings.ToLower(host)
+		if other, ok := tr.byHost[host]; ok {
+			return fmt.Errorf("tenants %q and %q both have the host %q", other.Id, tenant.Id, host)
+		}
+		tr.byHost[host] = tenant
+	}
+
+	tr.byId[tenant.Id] = tenant
+	tr.tenants = append(tr.tenants, tenant)
+	return nil
+}
+
+// Default returns the tenant that requests belong to when they do not name another one
+func (tr *tenantRegistry) Default() *dto.Tenant {
+	return tr.byId[dto.DefaultTenantId]
+}
+
+// Find finds a tenant by its id
+func (tr *tenantRegistry) Find(id string) (*dto.Tenant, bool) {
+	tenant, ok := tr.byId[id]
+	return tenant, ok
+}
+
+// FindByHost finds the tenant a request host belongs to, ignoring its port and case
+func (tr *tenantRegistry) FindByHost(host string) (*dto.Tenant, bool) {
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	tenant, ok := tr.byHost[strings.ToLower(host)]
+	return tenant, ok
+}
+
+// All returns every tenant, in the order they were registered
+func (tr *tenantRegistry) All() []*dto.Tenant {
+	return tr.tenants
+}
+
+// tenantLink returns the link with the token for the tenant in the context
+// the tenant's own URL for the link is used if it has one, and links of a tenant other than the default one
+// name it in the tenant query parameter, so the page the link opens can send it with the token
+func tenantLink(ctx context.Context, name, globalURL, token string) string {
+	tenant := dto.TenantFromContext(ctx)
+	if tenant == nil {
+		return fmt.Sprintf("%s?token=%s", globalURL, url.QueryEscape(token))
+	}
+
+	linkURL := globalURL
+	if override, ok := tenant.LinkURLs[name]; ok {
+		linkURL = override
+	}
+	if tenant.Id == dto.DefaultTenantId {
+		return fmt.Sprintf("%s?token=%s", linkURL, url.QueryEscape(token))
+	}
+	return fmt.Sprintf("%s?token=%s&tenant=%s", linkURL, url.QueryEscape(token), url.QueryEscape(tenant.Id))
+}
+
+// issuedByTenant reports whether a token issued by the named tenant can be used by the tenant in the context
+// tokens issued before there were tenants do not name one and belong to the default tenant
+func issuedByTenant(ctx context.Context, tokenTenantId string) bool {
+	tenantId, err := dto.TenantIdFromContext(ctx)
+	if err != nil {
+		return false
+	}
+	if tokenTenantId == "" {
+		tokenTenantId = dto.DefaultTenantId
+	}
+	return tokenTenantId == tenantId
+}
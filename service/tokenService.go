@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"strconv"
@@ -10,22 +11,26 @@ import (
 	"github.com/leonardchinonso/auth_service_cmp7174/utils"
 
 	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/leonardchinonso/auth_service_cmp7174/config"
+	"github.com/leonardchinonso/auth_service_cmp7174/errors"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
 	"github.com/leonardchinonso/auth_service_cmp7174/models/interfaces"
 )
 
 type tokenService struct {
-	tokenRepository interfaces.TokenRepositoryInterface
-	atSecret        string
-	rtSecret        string
-	atExpiresIn     int64
-	rtExpiresIn     int64
+	clientRepository     interfaces.ClientRepositoryInterface
+	tokenRepository      interfaces.TokenRepositoryInterface
+	membershipRepository interfaces.MembershipRepositoryInterface
+	auditService         interfaces.AuditServiceInterface
+	atExpiresIn          int64
+	rtExpiresIn          int64
 }
 
 // NewTokenService returns an interface for the token service methods
-func NewTokenService(cfg *map[string]string, tokenRepo interfaces.TokenRepositoryInterface) (interfaces.TokenServiceInterface, error) {
+func NewTokenService(cfg *map[string]string, clientRepo interfaces.ClientRepositoryInterface, tokenRepo interfaces.TokenRepositoryInterface, membershipRepo interfaces.MembershipRepositoryInterface, auditService interfaces.AuditServiceInterface) (interfaces.TokenServiceInterface, error) {
 	atExpiresIn, err := strconv.Atoi((*cfg)[config.ATExpiresIn])
 	if err != nil {
 		return nil, err
@@ -37,33 +42,103 @@ func NewTokenService(cfg *map[string]string, tokenRepo interfaces.TokenRepositor
 	}
 
 	return &tokenService{
-		tokenRepository: tokenRepo,
-		atSecret:        (*cfg)[config.ATSecretKey],
-		rtSecret:        (*cfg)[config.RTSecretKey],
-		atExpiresIn:     int64(atExpiresIn),
-		rtExpiresIn:     int64(rtExpiresIn),
+		clientRepository:     clientRepo,
+		tokenRepository:      tokenRepo,
+		membershipRepository: membershipRepo,
+		auditService:         auditService,
+		atExpiresIn:          int64(atExpiresIn),
+		rtExpiresIn:          int64(rtExpiresIn),
 	}, nil
 }
 
 // GenerateTokenPair generates an access token and a refresh token for the specified client
+// the tokens carry the organization the client's current tokens were for while they are still a member of it,
+// or else the first organization the client joined
 func (ts *tokenService) GenerateTokenPair(ctx context.Context, client *dao.Client) (string, string, error) {
-	at, err := generateAccessToken(client)
+	organizationId, err := ts.activeOrganization(ctx, client.Id)
+	if err != nil {
+		log.Printf("Error finding active organization for uid: %v. Error: %v\n", client.Id, err.Error())
+		return "", "", err
+	}
+
+	return ts.generateTokenPair(ctx, client, organizationId)
+}
+
+// SwitchOrganization generates a token pair for the client that carries another organization they are a member of
+func (ts *tokenService) SwitchOrganization(ctx context.Context, client *dao.Client, organizationId primitive.ObjectID) (string, string, error) {
+	membership := &dao.Membership{OrganizationId: organizationId, ClientId: client.Id}
+	ok, err := ts.membershipRepository.Find(ctx, membership)
+	if err != nil {
+		log.Printf("Error finding membership of uid: %v in organization: %v. Error: %v\n", client.Id, organizationId, err.Error())
+		return "", "", errors.ErrInternalServerError("failed to switch organization", nil)
+	}
+	if !ok {
+		return "", "", errors.ErrForbidden("you are not a member of this organization", nil)
+	}
+
+	at, rt, err := ts.generateTokenPair(ctx, client, &organizationId)
+	if err != nil {
+		return "", "", errors.ErrInternalServerError("failed to switch organization", nil)
+	}
+
+	ts.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditOrganizationSwitched, client).
+		WithTarget(dao.AuditTypeOrganization, organizationId.Hex()))
+
+	return at, rt, nil
+}
+
+// activeOrganization returns the organization a client's new tokens are for, or nil if they are not a member of any
+func (ts *tokenService) activeOrganization(ctx context.Context, clientId primitive.ObjectID) (*primitive.ObjectID, error) {
+	memberships, err := ts.membershipRepository.FindByClient(ctx, clientId)
+	if err != nil || len(memberships) == 0 {
+		return nil, err
+	}
+
+	token := &dao.Token{ClientId: clientId}
+	exists, err := ts.tokenRepository.FindByClientId(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if exists && token.OrganizationId != nil {
+		for _, membership := range memberships {
+			if membership.OrganizationId == *token.OrganizationId {
+				return token.OrganizationId, nil
+			}
+		}
+	}
+
+	return &memberships[0].OrganizationId, nil
+}
+
+// generateTokenPair generates and stores a token pair for the client that carries the organization
+// the tokens are signed with the keys of the tenant in the context
+func (ts *tokenService) generateTokenPair(ctx context.Context, client *dao.Client, organizationId *primitive.ObjectID) (string, string, error) {
+	tenant := dto.TenantFromContext(ctx)
+	if tenant == nil {
+		log.Printf("Error generating tokens for uid: %v. Error: no tenant in context\n", client.Id)
+		return "", "", fmt.Errorf("no tenant in context")
+	}
+
+	at, err := generateAccessToken(client, organizationId, tenant)
 	if err != nil {
 		log.Printf("Error generating access token for uid: %v. Error: %v\n", client.Id, err.Error())
 		return "", "", err
 	}
 
-	rt, err := generateRefreshToken(client)
+	rt, err := generateRefreshToken(client, organizationId, tenant)
 	if err != nil {
 		log.Printf("Error generating refresh token for uid: %v. Error: %v\n", client.Id, err.Error())
 		return "", "", err
 	}
 
+	now := time.Now()
 	token := &dao.Token{
 		ClientId:       client.Id,
-		AccessToken:  at,
-		RefreshToken: rt,
-		CreatedAt:    utils.CurrentPrimitiveTime(),
+		OrganizationId: organizationId,
+		AccessToken:    at,
+		RefreshToken:   rt,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(time.Duration(ts.rtExpiresIn) * time.Second),
 	}
 
 	if err = ts.tokenRepository.Upsert(ctx, token); err != nil {
@@ -71,39 +146,160 @@ func (ts *tokenService) GenerateTokenPair(ctx context.Context, client *dao.Clien
 		return "", "", err
 	}
 
+	ts.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditTokenIssued, client))
+
 	return at, rt, nil
 }
 
-// ClientFromAccessToken gets a client from their access token
-func (ts *tokenService) ClientFromAccessToken(tokenString string) (*dao.Client, error) {
-	claims, err := verifyAccessToken(tokenString, config.Map[config.ATSecretKey])
+// ClientFromAccessToken gets a client and the organization the token is for from their access token
+// the token has to be issued by the tenant in the context, so it cannot be used against another tenant
+func (ts *tokenService) ClientFromAccessToken(ctx context.Context, tokenString string) (*dao.Client, *primitive.ObjectID, error) {
+	tenant := dto.TenantFromContext(ctx)
+	if tenant == nil {
+		return nil, nil, fmt.Errorf("cannot authenticate client: no tenant in context")
+	}
+
+	claims, err := verifyAccessToken(tokenString, tenant.ATSecretKey)
+	if err == nil && !issuedByTenant(ctx, claims.TenantId) {
+		err = fmt.Errorf("token was issued for another tenant")
+	}
+
+	if err == nil {
+		err = ts.checkNotRevoked(ctx, claims)
+	}
 
 	if err != nil {
 		log.Printf("Unable to validate or parse access token. Error: %v\n", err)
-		return nil, fmt.Errorf("cannot authenticate client: %v", err)
+		return nil, nil, fmt.Errorf("cannot authenticate client: %v", err)
+	}
+
+	if claims.OrganizationId == "" {
+		return claims.Client, nil, nil
+	}
+	organizationId, err := primitive.ObjectIDFromHex(claims.OrganizationId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot authenticate client: invalid organization id")
+	}
+
+	return claims.Client, &organizationId, nil
+}
+
+// checkNotRevoked checks that the client of an access token still exists and has not signed out of every session
+// since the token was issued, which the token itself cannot tell
+// tokens issued in the same second as the sessions were revoked are refused as well
+func (ts *tokenService) checkNotRevoked(ctx context.Context, claims *tokenCustomClaims) error {
+	if claims.Client == nil {
+		return fmt.Errorf("token has no client")
+	}
+
+	client := &dao.Client{Id: claims.Client.Id}
+	exists, err := ts.clientRepository.FindByID(ctx, client)
+	if err != nil {
+		return fmt.Errorf("failed to find client: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("client no longer exists")
+	}
+	if client.SessionsRevokedAt != nil && claims.IssuedAt <= client.SessionsRevokedAt.Unix() {
+		return fmt.Errorf("token was revoked")
+	}
+	return nil
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair, replacing the stored pair
+// a refresh token can only be used once: a validly signed token that has already been replaced
+// revokes the client's tokens, since it means the token was stolen or replayed,
+// and the client it was issued to is returned with the error so the attempt can be recorded
+func (ts *tokenService) RefreshTokenPair(ctx context.Context, refreshToken string) (*dao.Client, string, string, error) {
+	tenant := dto.TenantFromContext(ctx)
+	if tenant == nil {
+		return nil, "", "", errors.ErrInternalServerError("failed to refresh token", nil)
+	}
+
+	claims, err := verifyRefreshToken(refreshToken, tenant.RTSecretKey)
+	if err == nil && !issuedByTenant(ctx, claims.TenantId) {
+		err = fmt.Errorf("token was issued for another tenant")
+	}
+	if err != nil || claims.Client == nil {
+		log.Printf("Unable to validate or parse refresh token. Error: %v\n", err)
+		return nil, "", "", errors.ErrUnauthorized("invalid or expired refresh token", nil)
+	}
+	client := claims.Client
+
+	token := &dao.Token{ClientId: client.Id}
+	exists, err := ts.tokenRepository.FindByClientId(ctx, token)
+	if err != nil {
+		log.Printf("Error finding token for uid: %v. Error: %v\n", client.Id, err.Error())
+		return nil, "", "", errors.ErrInternalServerError("failed to refresh token", nil)
+	}
+
+	// the client has logged out or the token has already been exchanged
+	if !exists || subtle.ConstantTimeCompare([]byte(token.RefreshToken), []byte(refreshToken)) != 1 {
+		if exists {
+			if _, err = ts.tokenRepository.Delete(ctx, client.Id); err != nil {
+				log.Printf("Error revoking tokens for uid: %v. Error: %v\n", client.Id, err.Error())
+			}
+			ts.auditService.Record(ctx, dao.ClientAuditEvent(dao.AuditTokenRevoked, client).With("reason", "refresh_token_reuse"))
+		}
+		return client, "", "", errors.ErrUnauthorized("invalid or expired refresh token", nil)
+	}
+
+	at, rt, err := ts.GenerateTokenPair(ctx, client)
+	if err != nil {
+		return client, "", "", errors.ErrInternalServerError("failed to refresh token", nil)
 	}
 
-	return claims.Client, nil
+	return client, at, rt, nil
 }
 
 type tokenCustomClaims struct {
 	Client *dao.Client `json:"client"`
+	// OrganizationId is the hex id of the organization the client is acting for, if they are a member of any
+	OrganizationId string `json:"org_id,omitempty"`
+	// TenantId is the id of the tenant the token was issued by
+	TenantId string `json:"tid,omitempty"`
 	jwt.StandardClaims
 }
 
+// TenantIdFromToken reads the id of the tenant a token was issued by without verifying the token,
+// so the token can be verified with that tenant's keys
+// it returns an empty string if the token cannot be read
+func (ts *tokenService) TenantIdFromToken(tokenString string) string {
+	if tokenString == "" {
+		return ""
+	}
+
+	claims := &tokenCustomClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenString, claims); err != nil {
+		return ""
+	}
+	return claims.TenantId
+}
+
 // generateToken generates a new jwt
-func generateToken(client *dao.Client, jwtSecretKey string, expiresIn int64) (string, error) {
+func generateToken(client *dao.Client, organizationId *primitive.ObjectID, tenantId, jwtSecretKey string, expiresIn int64) (string, error) {
 	unixTime := time.Now().Unix()
 	tokenExpiresIn := unixTime + expiresIn
 
+	// a unique id keeps two tokens issued in the same second from being identical
+	tokenId, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	// create a claims object
 	claims := tokenCustomClaims{
-		Client: client,
+		Client:   client,
+		TenantId: tenantId,
 		StandardClaims: jwt.StandardClaims{
+			Id:        tokenId,
 			ExpiresAt: tokenExpiresIn,
 			IssuedAt:  unixTime,
 		},
 	}
+	if organizationId != nil {
+		claims.OrganizationId = organizationId.Hex()
+	}
 
 	// create a jwt token object and set the expiry time
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -118,32 +314,32 @@ func generateToken(client *dao.Client, jwtSecretKey string, expiresIn int64) (st
 	return tokenString, nil
 }
 
-// generateAccessToken generates a new jwt for the access token
-func generateAccessToken(client *dao.Client) (string, error) {
+// generateAccessToken generates a new jwt for the access token, signed with the tenant's access token key
+func generateAccessToken(client *dao.Client, organizationId *primitive.ObjectID, tenant *dto.Tenant) (string, error) {
 	// get the access token secret key for signing the token
 	atExpiresIn, err := strconv.Atoi(config.Map[config.ATExpiresIn])
 	if err != nil {
 		return "", err
 	}
 
-	// get the access token secret key
-	atSecretKey := config.Map[config.ATSecretKey]
-
-	return generateToken(client, atSecretKey, int64(atExpiresIn))
+	return generateToken(client, organizationId, tenant.Id, tenant.ATSecretKey, int64(atExpiresIn))
 }
 
-// generateRefreshToken generates a new jwt for the refresh token
-func generateRefreshToken(client *dao.Client) (string, error) {
+// generateRefreshToken generates a new jwt for the refresh token, signed with the tenant's refresh token key
+func generateRefreshToken(client *dao.Client, organizationId *primitive.ObjectID, tenant *dto.Tenant) (string, error) {
 	// get the refresh token secret key for signing the token
 	rtExpiresIn, err := strconv.Atoi(config.Map[config.RTExpiresIn])
 	if err != nil {
 		return "", err
 	}
 
-	// get the refresh token secret key
-	rtSecretKey := config.Map[config.RTSecretKey]
+	return generateToken(client, organizationId, tenant.Id, tenant.RTSecretKey, int64(rtExpiresIn))
+}
 
-	return generateToken(client, rtSecretKey, int64(rtExpiresIn))
+// verifyRefreshToken verifies that a refresh token is correct
+// refresh tokens have the same claims as access tokens but are signed with a different key
+func verifyRefreshToken(tokenString, rtSecretKey string) (*tokenCustomClaims, error) {
+	return verifyAccessToken(tokenString, rtSecretKey)
 }
 
 // verifyAccessToken verifies that an access token is correct
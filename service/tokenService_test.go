@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dao"
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+func TestAccessTokenIsRefusedAfterSessionsAreRevoked(t *testing.T) {
+	ctx := dto.WithTenant(context.Background(), tenantA)
+	client := &dao.Client{Id: primitive.NewObjectID(), Email: "a@tenant-a.test"}
+
+	token, err := generateToken(client, nil, tenantA.Id, tenantA.ATSecretKey, 60)
+	if err != nil {
+		t.Fatalf("failed to generate access token: %v", err)
+	}
+
+	revokedBefore := time.Now().Add(-2 * time.Second)
+	ts := &tokenService{clientRepository: &memoryClients{sessionsRevokedAt: &revokedBefore}}
+	if _, _, err = ts.ClientFromAccessToken(ctx, token); err != nil {
+		t.Fatalf("access token issued after the sessions were revoked was refused: %v", err)
+	}
+
+	revokedNow := time.Now()
+	ts = &tokenService{clientRepository: &memoryClients{sessionsRevokedAt: &revokedNow}}
+	if _, _, err = ts.ClientFromAccessToken(ctx, token); err == nil {
+		t.Errorf("access token issued before the sessions were revoked was accepted")
+	}
+}
@@ -0,0 +1,192 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// mail template names
+const (
+	MailMagicLink          = "magic_link"
+	MailAccountLocked      = "account_locked"
+	MailLoginAlert         = "login_alert"
+	MailEmailChangeConfirm = "email_change_confirm"
+	MailEmailChangeNotice  = "email_change_notice"
+	MailInvitation         = "invitation"
+)
+
+//go:embed mail
+var mailFS embed.FS
+
+// mailLayout is the html layout that every html mail template is rendered into
+const mailLayout = "mail/layout.html"
+
+// mailTemplate holds the parsed html and text versions of a mail template in one locale
+type mailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// mailTemplateData is what a mail template is executed with
+type mailTemplateData struct {
+	Locale string
+	Data   interface{}
+}
+
+// MailTemplates renders the localized mail templates embedded in the binary
+// each locale has a directory under mail/ holding a `.html` and a `.txt` version of every template,
+// both of which define a "subject" template
+type MailTemplates struct {
+	templates map[string]map[string]*mailTemplate
+	locales   []language.Tag
+	matcher   language.Matcher
+}
+
+// overlayFS opens files from the overrides when they have them and from the embedded templates otherwise
+// the overrides are laid out like the mail directory, without the mail/ prefix
+type overlayFS struct {
+	overrides fs.FS
+}
+
+// Open opens a mail template file, preferring the overridden version
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if rel := strings.TrimPrefix(name, "mail/"); rel != name {
+		f, err := o.overrides.Open(rel)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return mailFS.Open(name)
+}
+
+// LoadMailTemplates parses every embedded mail template
+// overrides may replace any of the embedded files, e.g. `en/magic_link.html` or `layout.html`,
+// but cannot add locales or templates, and may be nil
+// the default locale is used when a mail is requested in a locale that has no templates
+func LoadMailTemplates(defaultLocale string, overrides fs.FS) (*MailTemplates, error) {
+	defaultTag, err := language.Parse(defaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default mail locale: %s", defaultLocale)
+	}
+
+	dirs, err := fs.ReadDir(mailFS, "mail")
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS = mailFS
+	if overrides != nil {
+		fsys = overlayFS{overrides: overrides}
+	}
+
+	mt := &MailTemplates{
+		templates: make(map[string]map[string]*mailTemplate),
+		// the matcher falls back to the first supported locale
+		locales: []language.Tag{defaultTag},
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		locale := dir.Name()
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mail template locale: %s", locale)
+		}
+
+		templates, err := parseMailLocale(fsys, locale)
+		if err != nil {
+			return nil, err
+		}
+
+		mt.templates[tag.String()] = templates
+		if tag != defaultTag {
+			mt.locales = append(mt.locales, tag)
+		}
+	}
+
+	if _, ok := mt.templates[defaultTag.String()]; !ok {
+		return nil, fmt.Errorf("there are no mail templates for the default locale: %s", defaultLocale)
+	}
+
+	mt.matcher = language.NewMatcher(mt.locales)
+	return mt, nil
+}
+
+// Render renders the subject, text and html of a mail template in the locale closest to the one requested
+func (mt *MailTemplates) Render(name, locale string, data interface{}) (string, string, string, error) {
+	tag := mt.locales[0]
+	if locale != "" {
+		_, index, confidence := mt.matcher.Match(language.Make(locale))
+		if confidence != language.No {
+			tag = mt.locales[index]
+		}
+	}
+
+	t, ok := mt.templates[tag.String()][name]
+	if !ok {
+		// a template that has not been translated yet is sent in the default locale
+		tag = mt.locales[0]
+		if t, ok = mt.templates[tag.String()][name]; !ok {
+			return "", "", "", fmt.Errorf("unknown mail template: %s", name)
+		}
+	}
+
+	td := mailTemplateData{Locale: tag.String(), Data: data}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", td); err != nil {
+		return "", "", "", fmt.Errorf("failed to render mail subject: %v", err)
+	}
+	if err := t.text.Execute(&text, td); err != nil {
+		return "", "", "", fmt.Errorf("failed to render text mail: %v", err)
+	}
+	if err := t.html.Execute(&html, td); err != nil {
+		return "", "", "", fmt.Errorf("failed to render html mail: %v", err)
+	}
+
+	return strings.TrimSpace(subject.String()), text.String(), html.String(), nil
+}
+
+// parseMailLocale parses the html and text templates in a locale directory
+// the text version is parsed with text/template so links and punctuation are not html escaped
+// the templates are listed from the embedded directory and parsed from fsys, which may override them
+func parseMailLocale(fsys fs.FS, locale string) (map[string]*mailTemplate, error) {
+	entries, err := fs.ReadDir(mailFS, path.Join("mail", locale))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]*mailTemplate)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".html")
+		if e.IsDir() || name == e.Name() {
+			continue
+		}
+
+		html, err := htmltemplate.ParseFS(fsys, path.Join("mail", locale, e.Name()), mailLayout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mail template %s/%s: %v", locale, e.Name(), err)
+		}
+
+		text, err := texttemplate.ParseFS(fsys, path.Join("mail", locale, name+".txt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mail template %s/%s.txt: %v", locale, name, err)
+		}
+
+		templates[name] = &mailTemplate{html: html, text: text}
+	}
+
+	return templates, nil
+}
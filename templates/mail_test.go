@@ -0,0 +1,120 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/leonardchinonso/auth_service_cmp7174/models/dto"
+)
+
+// templateData is sample data for every mail template
+var templateData = map[string]interface{}{
+	MailMagicLink:          dto.MagicLinkMail{Name: "Jane", Link: "https://example.com/magic?token=abc", ExpiresIn: "15 minutes"},
+	MailAccountLocked:      dto.AccountLockedMail{Name: "Jane", MaxAttempts: 5, LockedUntil: "12:00 UTC"},
+	MailLoginAlert:         dto.LoginAlertMail{Name: "Jane", Device: "Firefox on Linux", IP: "203.0.113.7", Time: "12:00 UTC", RevokeLink: "https://example.com/revoke?token=abc"},
+	MailEmailChangeConfirm: dto.EmailChangeConfirmMail{Name: "Jane", NewEmail: "new@example.com", ConfirmLink: "https://example.com/confirm?token=abc", ExpiresIn: "1 hour"},
+	MailEmailChangeNotice:  dto.EmailChangeNoticeMail{Name: "Jane", NewEmail: "new@example.com", CancelLink: "https://example.com/cancel?token=abc", ExpiresIn: "1 hour"},
+	MailInvitation:         dto.InvitationMail{InviterName: "Jane", OrganizationName: "Acme", Role: "member", Link: "https://example.com/invite?token=abc", ExpiresIn: "7 days"},
+}
+
+func TestRenderEveryTemplate(t *testing.T) {
+	mt, err := LoadMailTemplates("en", nil)
+	if err != nil {
+		t.Fatalf("LoadMailTemplates() error = %v", err)
+	}
+
+	for _, locale := range []string{"en", "fr"} {
+		for name, data := range templateData {
+			subject, text, html, err := mt.Render(name, locale, data)
+			if err != nil {
+				t.Errorf("Render(%s, %s) error = %v", name, locale, err)
+				continue
+			}
+			if subject == "" || strings.Contains(subject, "\n") {
+				t.Errorf("Render(%s, %s) subject = %q, want a single line", name, locale, subject)
+			}
+			if !strings.Contains(text, "Jane") || !strings.Contains(html, "Jane") {
+				t.Errorf("Render(%s, %s) did not fill in the data", name, locale)
+			}
+			if strings.Contains(text, "&amp;") || strings.Contains(text, "&#") {
+				t.Errorf("Render(%s, %s) html escaped the text version: %s", name, locale, text)
+			}
+			if !strings.Contains(html, `lang="`+locale+`"`) {
+				t.Errorf("Render(%s, %s) html is not rendered into the layout in its locale", name, locale)
+			}
+		}
+	}
+}
+
+func TestRenderLocaleFallback(t *testing.T) {
+	mt, err := LoadMailTemplates("en", nil)
+	if err != nil {
+		t.Fatalf("LoadMailTemplates() error = %v", err)
+	}
+	data := templateData[MailMagicLink]
+
+	tests := []struct {
+		locale  string
+		subject string
+	}{
+		{locale: "", subject: "Your login link"},
+		{locale: "en-GB", subject: "Your login link"},
+		{locale: "fr-CA", subject: "Votre lien de connexion"},
+		{locale: "de", subject: "Your login link"},
+	}
+
+	for _, tt := range tests {
+		subject, _, _, err := mt.Render(MailMagicLink, tt.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%q) error = %v", tt.locale, err)
+		}
+		if subject != tt.subject {
+			t.Errorf("Render(%q) subject = %q, want %q", tt.locale, subject, tt.subject)
+		}
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	mt, err := LoadMailTemplates("en", nil)
+	if err != nil {
+		t.Fatalf("LoadMailTemplates() error = %v", err)
+	}
+	if _, _, _, err := mt.Render("unknown", "en", nil); err == nil {
+		t.Fatal("Render() error = nil, want an error for an unknown template")
+	}
+}
+
+func TestLoadMailTemplatesOverrides(t *testing.T) {
+	overrides := fstest.MapFS{
+		"en/magic_link.txt": &fstest.MapFile{Data: []byte(`{{define "subject"}}Acme login{{end}}Hi {{.Data.Name}}, {{.Data.Link}}`)},
+	}
+
+	mt, err := LoadMailTemplates("en", overrides)
+	if err != nil {
+		t.Fatalf("LoadMailTemplates() error = %v", err)
+	}
+
+	subject, text, _, err := mt.Render(MailMagicLink, "en", templateData[MailMagicLink])
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if subject != "Acme login" || text != "Hi Jane, https://example.com/magic?token=abc" {
+		t.Errorf("Render() = %q, %q, want the overridden template", subject, text)
+	}
+
+	// files that are not overridden still come from the embedded templates
+	subject, _, _, err = mt.Render(MailMagicLink, "fr", templateData[MailMagicLink])
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if subject != "Votre lien de connexion" {
+		t.Errorf("Render() subject = %q, want the embedded french subject", subject)
+	}
+}
+
+func TestLoadMailTemplatesInvalidDefaultLocale(t *testing.T) {
+	if _, err := LoadMailTemplates("de", nil); err == nil {
+		t.Fatal("LoadMailTemplates() error = nil, want an error for a default locale without templates")
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// postalCodeFormats are the postal code formats of the countries whose addresses need a postal code
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"CZ": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^(GIR ?0AA|[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2})$`),
+	"IE": regexp.MustCompile(`^([AC-FHKNPRTV-Y]\d{2}|D6W) ?[\dAC-FHKNPRTV-Y]{4}$`),
+	"IN": regexp.MustCompile(`^\d{3} ?\d{3}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"KE": regexp.MustCompile(`^\d{5}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"NG": regexp.MustCompile(`^\d{6}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"ZA": regexp.MustCompile(`^\d{4}$`),
+}
+
+// countriesWithoutPostalCodes are the countries whose addresses do not use postal codes
+var countriesWithoutPostalCodes = map[string]bool{
+	"AE": true, "AG": true, "AO": true, "AW": true, "BF": true, "BI": true, "BJ": true, "BO": true, "BS": true,
+	"BW": true, "BZ": true, "CD": true, "CF": true, "CG": true, "CI": true, "CK": true, "CM": true, "DJ": true,
+	"DM": true, "ER": true, "FJ": true, "GA": true, "GD": true, "GM": true, "GQ": true, "GY": true, "HK": true,
+	"KI": true, "KM": true, "KN": true, "KP": true, "LY": true, "ML": true, "MO": true, "MR": true, "MW": true,
+	"NR": true, "NU": true, "QA": true, "RW": true, "SB": true, "SC": true, "SL": true, "SR": true, "ST": true,
+	"SY": true, "TD": true, "TG": true, "TK": true, "TL": true, "TO": true, "TV": true, "UG": true, "VU": true,
+	"YE": true, "ZW": true,
+}
+
+// genericPostalCodeFormat is what a postal code looks like in a country whose format is not listed
+var genericPostalCodeFormat = regexp.MustCompile(`^[A-Z\d][A-Z\d -]{1,9}$`)
+
+// notCountryCodes are the region codes the language package knows as countries that are not assigned ISO 3166-1 codes,
+// such as withdrawn codes and the codes reserved for other uses
+var notCountryCodes = map[string]bool{
+	"AC": true, "AN": true, "BU": true, "CP": true, "CQ": true, "CS": true, "CT": true, "DD": true, "DG": true,
+	"DY": true, "EA": true, "EZ": true, "FQ": true, "FX": true, "HV": true, "IC": true, "JT": true, "MI": true,
+	"NH": true, "NQ": true, "NT": true, "PC": true, "PU": true, "PZ": true, "RH": true, "SU": true, "TA": true,
+	"TP": true, "UK": true, "UN": true, "VD": true, "WK": true, "YD": true, "YU": true, "ZR": true,
+}
+
+// countryAliases are names people commonly write for a country that are not its English name
+var countryAliases = map[string]string{
+	"usa":                      "US",
+	"u.s.a.":                   "US",
+	"united states of america": "US",
+	"america":                  "US",
+	"uk":                       "GB",
+	"u.k.":                     "GB",
+	"great britain":            "GB",
+	"britain":                  "GB",
+	"england":                  "GB",
+	"scotland":                 "GB",
+	"wales":                    "GB",
+	"northern ireland":         "GB",
+	"holland":                  "NL",
+	"the netherlands":          "NL",
+}
+
+// countryNames maps the lower case English name of every country to its ISO 3166-1 alpha-2 code
+var countryNames = map[string]string{}
+
+func init() {
+	namer := display.English.Regions()
+	for a := 'A'; a <= 'Z'; a++ {
+		for b := 'A'; b <= 'Z'; b++ {
+			code := string([]rune{a, b})
+			if IsCountryCode(code) {
+				countryNames[strings.ToLower(namer.Name(language.MustParseRegion(code)))] = code
+			}
+		}
+	}
+	for alias, code := range countryAliases {
+		countryNames[alias] = code
+	}
+}
+
+// IsCountryCode reports whether the code is an upper case ISO 3166-1 alpha-2 country code, e.g. `GB`
+func IsCountryCode(code string) bool {
+	if len(code) != 2 || notCountryCodes[code] {
+		return false
+	}
+	region, err := language.ParseRegion(code)
+	return err == nil && region.IsCountry() && region.String() == code
+}
+
+// CountryFromName returns the ISO 3166-1 alpha-2 code of a country written as its code or its English name
+func CountryFromName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if code := strings.ToUpper(name); IsCountryCode(code) {
+		return code, true
+	}
+	code, ok := countryNames[strings.ToLower(strings.TrimSuffix(name, "."))]
+	return code, ok
+}
+
+// NormalizePostalCode returns the postal code in upper case with single spaces
+func NormalizePostalCode(postalCode string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(postalCode), " "))
+}
+
+// ValidatePostalCode checks a normalized postal code against the format of the country
+// a country that uses postal codes needs one, and one that does not cannot have one
+func ValidatePostalCode(country, postalCode string) error {
+	if countriesWithoutPostalCodes[country] {
+		if postalCode != "" {
+			return fmt.Errorf("addresses in %s do not have a postal code", country)
+		}
+		return nil
+	}
+
+	format, ok := postalCodeFormats[country]
+	if !ok {
+		if postalCode != "" && !genericPostalCodeFormat.MatchString(postalCode) {
+			return fmt.Errorf("postal code is invalid")
+		}
+		return nil
+	}
+
+	if postalCode == "" {
+		return fmt.Errorf("addresses in %s need a postal code", country)
+	}
+	if !format.MatchString(postalCode) {
+		return fmt.Errorf("postal code is invalid for %s", country)
+	}
+	return nil
+}
+
+// MatchesPostalCode reports whether the text is a valid postal code in a country that has a known postal code format
+func MatchesPostalCode(country, text string) bool {
+	format, ok := postalCodeFormats[country]
+	return ok && format.MatchString(NormalizePostalCode(text))
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+// bloomFilterMagic identifies a bloom filter file
+var bloomFilterMagic = [4]byte{'B', 'L', 'M', '1'}
+
+// BloomFilter is a space efficient set that can report false positives but never false negatives
+type BloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint32
+}
+
+// NewBloomFilter creates a bloom filter sized to hold n items with the given false positive rate
+func NewBloomFilter(n uint64, falsePositiveRate float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+
+	// the optimal number of bits and hash functions for n items and the false positive rate
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add adds an item to the filter
+func (bf *BloomFilter) Add(item []byte) {
+	h1, h2 := bloomHashes(item)
+	for i := uint32(0); i < bf.k; i++ {
+		idx := (h1 + uint64(i)*h2) % bf.m
+		bf.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+// Test checks if an item may have been added to the filter
+func (bf *BloomFilter) Test(item []byte) bool {
+	h1, h2 := bloomHashes(item)
+	for i := uint32(0); i < bf.k; i++ {
+		idx := (h1 + uint64(i)*h2) % bf.m
+		if bf.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the filter to w in a format that ReadBloomFilter can load
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+
+	header := make([]byte, 16)
+	copy(header, bloomFilterMagic[:])
+	binary.LittleEndian.PutUint64(header[4:], bf.m)
+	binary.LittleEndian.PutUint32(header[12:], bf.k)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+
+	word := make([]byte, 8)
+	for _, b := range bf.bits {
+		binary.LittleEndian.PutUint64(word, b)
+		if _, err := bw.Write(word); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(header) + 8*len(bf.bits)), bw.Flush()
+}
+
+// ReadBloomFilter loads a filter written by BloomFilter.WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter header: %v", err)
+	}
+	if !bytes.Equal(header[:4], bloomFilterMagic[:]) {
+		return nil, fmt.Errorf("not a bloom filter file")
+	}
+
+	bf := &BloomFilter{
+		m: binary.LittleEndian.Uint64(header[4:]),
+		k: binary.LittleEndian.Uint32(header[12:]),
+	}
+	if bf.m == 0 || bf.k == 0 {
+		return nil, fmt.Errorf("invalid bloom filter size")
+	}
+	bf.bits = make([]uint64, (bf.m+63)/64)
+
+	word := make([]byte, 8)
+	for i := range bf.bits {
+		if _, err := io.ReadFull(br, word); err != nil {
+			return nil, fmt.Errorf("failed to read bloom filter: %v", err)
+		}
+		bf.bits[i] = binary.LittleEndian.Uint64(word)
+	}
+
+	return bf, nil
+}
+
+// bloomHashes returns the two hashes that the filter's k hashes are derived from
+func bloomHashes(item []byte) (uint64, uint64) {
+	a := fnv.New64a()
+	a.Write(item)
+	b := fnv.New64()
+	b.Write(item)
+
+	// an even second hash could cycle through only part of the filter
+	return a.Sum64(), b.Sum64() | 1
+}
@@ -0,0 +1,53 @@
+package utils
+
+import "strings"
+
+// userAgentBrowsers are the browser markers in a user agent, checked in order
+// since most browsers also claim to be the ones they are built on, e.g. Edge claims to be Chrome and Safari
+var userAgentBrowsers = []struct{ marker, name string }{
+	{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+	{"Safari/", "Safari"}, {"curl/", "curl"}, {"PostmanRuntime/", "Postman"},
+}
+
+// userAgentSystems are the operating system markers in a user agent, checked in order
+var userAgentSystems = []struct{ marker, name string }{
+	{"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Android", "Android"}, {"Windows", "Windows"},
+	{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+}
+
+// maxUserAgentDescriptionLength is the longest raw user agent shown when it cannot be described
+const maxUserAgentDescriptionLength = 100
+
+// DescribeUserAgent returns a short human readable description of a user agent, e.g. `Chrome on Windows`
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "an unknown device"
+	}
+
+	var browser, system string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(userAgent, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range userAgentSystems {
+		if strings.Contains(userAgent, s.marker) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return "a device running " + system
+	case len(userAgent) > maxUserAgentDescriptionLength:
+		return userAgent[:maxUserAgentDescriptionLength] + "..."
+	default:
+		return userAgent
+	}
+}